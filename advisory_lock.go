@@ -0,0 +1,84 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"hash/fnv"
+)
+
+// WithAdvisoryLock executa fn segurando um advisory lock do Postgres no banco
+// do tenant, aguardando enquanto outra instância o possui. Útil para que jobs
+// em várias réplicas não rodem em paralelo para o mesmo tenant.
+func WithAdvisoryLock(ctx context.Context, tenant, key string, fn func() error) error {
+	return defaultManager.WithAdvisoryLock(ctx, tenant, key, fn)
+}
+
+// TryWithAdvisoryLock é como WithAdvisoryLock, mas não aguarda: quando o lock
+// já está com outra sessão, retorna false sem executar fn.
+func TryWithAdvisoryLock(ctx context.Context, tenant, key string, fn func() error) (bool, error) {
+	return defaultManager.TryWithAdvisoryLock(ctx, tenant, key, fn)
+}
+
+// WithAdvisoryLock equivale ao do pacote, no banco do tenant deste Manager.
+func (m *Manager) WithAdvisoryLock(ctx context.Context, tenant, key string, fn func() error) error {
+	_, err := m.withAdvisoryLock(ctx, tenant, key, false, fn)
+	return err
+}
+
+// TryWithAdvisoryLock equivale ao do pacote, no banco do tenant deste Manager.
+func (m *Manager) TryWithAdvisoryLock(ctx context.Context, tenant, key string, fn func() error) (bool, error) {
+	return m.withAdvisoryLock(ctx, tenant, key, true, fn)
+}
+
+// advisoryLockID converte tenant+key no identificador bigint do lock
+func advisoryLockID(tenant, key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(tenant))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return int64(h.Sum64())
+}
+
+func (m *Manager) withAdvisoryLock(ctx context.Context, tenant, key string, try bool, fn func() error) (bool, error) {
+	tenantConn, err := m.GetTenantConnection(ctx, tenant, TenantConnectOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	// Advisory locks de sessão pertencem à conexão, então lock e unlock
+	// precisam usar a mesma conexão do pool
+	conn, err := tenantConn.DB.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	id := advisoryLockID(tenant, key)
+
+	acquired := true
+	if try {
+		err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, id).Scan(&acquired)
+	} else {
+		_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, id)
+	}
+	if err != nil || !acquired {
+		return false, err
+	}
+
+	defer unlockAdvisory(conn, tenant, key, id)
+
+	return true, fn()
+}
+
+func unlockAdvisory(conn *sql.Conn, tenant, key string, id int64) {
+	// O contexto do chamador pode já ter sido cancelado
+	_, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, id)
+	if err == nil {
+		return
+	}
+
+	logError("Advisory unlock failed for tenant ", tenant, " key ", key, ": ", err)
+	// Descarta a conexão para que o lock seja liberado com o fim da sessão
+	conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+}
@@ -0,0 +1,81 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const prefixAlias = "alias-"
+
+// Tempo em que a resolução de um alias fica em cache. É curto para que chaves
+// de API revogadas deixem de funcionar rapidamente.
+const aliasTTL = 5 * time.Minute
+
+type LookupKind string
+
+const (
+	LookupSchemaName LookupKind = "schema_name"
+	LookupAPIKey     LookupKind = "api_key"
+	LookupExternalID LookupKind = "external_id"
+	LookupDomain     LookupKind = "domain"
+)
+
+type LookupKey struct {
+	Kind  LookupKind
+	Value string
+}
+
+// GetTenantBy busca o tenant por uma chave alternativa cadastrada na tabela
+// catalog_alias (chave de API, ID legado, domínio...).
+func GetTenantBy(ctx context.Context, key LookupKey) (*Catalog, error) {
+	return defaultManager.GetTenantBy(ctx, key)
+}
+
+func (m *Manager) GetTenantBy(ctx context.Context, key LookupKey) (*Catalog, error) {
+	if key.Kind == LookupSchemaName {
+		return m.GetTenant(ctx, key.Value)
+	}
+
+	schemaName, err := m.resolveAlias(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.GetTenant(ctx, schemaName)
+}
+
+func (m *Manager) resolveAlias(ctx context.Context, key LookupKey) (string, error) {
+	cacheKey := prefixAlias + string(key.Kind) + ":" + key.Value
+	if schemaName, found := m.cache.Get(cacheKey); found {
+		return schemaName.(string), nil
+	}
+
+	query := `
+        SELECT schema_name
+        FROM catalog_alias
+		WHERE kind = $1 AND value = $2`
+
+	var schemaName string
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+
+	defer cancel()
+
+	err := m.readCatalog(ctx, func(ctx context.Context, db *sql.DB) error {
+		return db.QueryRowContext(ctx, query, string(key.Kind), key.Value).Scan(&schemaName)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+
+	m.cache.Set(cacheKey, schemaName, aliasTTL)
+
+	return schemaName, nil
+}
@@ -0,0 +1,80 @@
+package connection
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var ErrQueryNotAllowed = errors.New("query not allowed")
+
+// QueryNotAllowedError indica uma query fora de AllowedQueries. errors.Is
+// reconhece ErrQueryNotAllowed.
+type QueryNotAllowedError struct {
+	Tenant      string
+	Fingerprint string
+	Query       string
+}
+
+func (e *QueryNotAllowedError) Error() string {
+	return fmt.Sprintf("%v: tenant %s: fingerprint %s", ErrQueryNotAllowed, e.Tenant, e.Fingerprint)
+}
+
+func (e *QueryNotAllowedError) Is(target error) bool {
+	return target == ErrQueryNotAllowed
+}
+
+// QueryAllowlist é o conjunto de queries permitidas em uma conexão com
+// AllowedQueries, identificadas pela impressão digital.
+type QueryAllowlist struct {
+	fingerprints map[string]struct{}
+}
+
+// NewQueryAllowlist cria a lista com as queries informadas. Queries que
+// diferem apenas nos literais, comentários, espaços ou maiúsculas têm a
+// mesma impressão digital.
+func NewQueryAllowlist(queries ...string) *QueryAllowlist {
+	l := &QueryAllowlist{fingerprints: make(map[string]struct{}, len(queries))}
+	for _, query := range queries {
+		l.fingerprints[QueryFingerprint(query)] = struct{}{}
+	}
+	return l
+}
+
+// AddFingerprints permite as impressões digitais já calculadas, como as
+// registradas no log das queries recusadas.
+func (l *QueryAllowlist) AddFingerprints(fingerprints ...string) *QueryAllowlist {
+	for _, fingerprint := range fingerprints {
+		l.fingerprints[fingerprint] = struct{}{}
+	}
+	return l
+}
+
+func (l *QueryAllowlist) Allows(query string) bool {
+	_, found := l.fingerprints[QueryFingerprint(query)]
+	return found
+}
+
+var numericLiteral = regexp.MustCompile(`\b\d+(\.\d+)?\b`)
+
+// QueryFingerprint devolve a impressão digital da query: o hash do comando
+// sem comentários e com os literais de texto e números trocados por '?'.
+func QueryFingerprint(query string) string {
+	statement := numericLiteral.ReplaceAllString(normalizeStatement(query), "?")
+	sum := sha256.Sum256([]byte(statement))
+	return hex.EncodeToString(sum[:8])
+}
+
+// checkAllowed recusa a query quando a conexão tem AllowedQueries e ela não
+// está na lista
+func (c Connection) checkAllowed(query string) error {
+	if c.allowedQueries == nil || c.allowedQueries.Allows(query) {
+		return nil
+	}
+
+	fingerprint := QueryFingerprint(query)
+	logError("Query not allowed for tenant ", c.SearchPath, " (fingerprint ", fingerprint, "): ", scrubSecrets(query))
+	return &QueryNotAllowedError{Tenant: c.SearchPath, Fingerprint: fingerprint, Query: query}
+}
@@ -0,0 +1,30 @@
+package connection
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+func TestQueryRowContextNotAllowed(t *testing.T) {
+	db, stub := stubDB(map[string]stubResult{
+		"SELECT id": {columns: []string{"id"}, rows: [][]driver.Value{{int64(7)}}},
+	})
+	defer db.Close()
+	conn := Connection{DB: db, SearchPath: "acme", allowedQueries: NewQueryAllowlist("SELECT id FROM products WHERE id = $1")}
+
+	var id int64
+	err := conn.QueryRowContext(context.Background(), "SELECT id FROM products WHERE id = 1 OR true").Scan(&id)
+	var notAllowed *QueryNotAllowedError
+	if !errors.As(err, &notAllowed) || !errors.Is(err, ErrQueryNotAllowed) {
+		t.Fatalf("err = %v, want *QueryNotAllowedError", err)
+	}
+	if len(stub.queries) != 0 {
+		t.Fatalf("denied query reached the driver: %q", stub.queries)
+	}
+
+	if err := conn.QueryRowContext(context.Background(), "SELECT id FROM products WHERE id = $1", 7).Scan(&id); err != nil || id != 7 {
+		t.Fatalf("allowed query = %d, %v", id, err)
+	}
+}
@@ -0,0 +1,167 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var ErrUnknownMaskColumn = errors.New("anonymization rule for unknown column")
+
+// MaskRule devolve a expressão SQL que substitui o valor da coluna, recebida
+// já entre aspas. O resultado é convertido para o tipo da coluna.
+type MaskRule func(column string) string
+
+// AnonymizationRules são as regras de mascaramento por tabela e coluna. As
+// colunas sem regra são copiadas sem alteração.
+type AnonymizationRules map[string]map[string]MaskRule
+
+// MaskNull troca o valor por NULL.
+func MaskNull(string) string {
+	return "NULL"
+}
+
+// MaskHash troca o valor pelo md5 do texto, mantendo NULL e a igualdade entre
+// valores iguais (útil em colunas usadas em junções ou índices únicos).
+func MaskHash(column string) string {
+	return "md5(" + column + "::text)"
+}
+
+// MaskEmail troca o e-mail por um endereço único em example.com.
+func MaskEmail(column string) string {
+	return "CASE WHEN " + column + " IS NULL THEN NULL ELSE left(md5(" + column + "::text), 16) || '@example.com' END"
+}
+
+// MaskValue troca os valores não nulos pelo valor fixo informado.
+func MaskValue(value string) MaskRule {
+	return func(column string) string {
+		return "CASE WHEN " + column + " IS NULL THEN NULL ELSE " + pq.QuoteLiteral(value) + " END"
+	}
+}
+
+// AnonymizeTenant aplica as regras de mascaramento aos dados do próprio
+// tenant, em uma única transação. Destina-se a cópias já criadas (staging,
+// sandbox); para anonimizar durante a cópia, use Anonymize em CloneOptions ou
+// ExportOptions.
+func AnonymizeTenant(ctx context.Context, tenant string, rules AnonymizationRules) error {
+	conn, err := GetTenantConnectionWithOptions(ctx, tenant, TenantConnectOptions{})
+	if err != nil {
+		return err
+	}
+	if err := rules.check(ctx, conn); err != nil {
+		return err
+	}
+
+	tx, err := conn.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range rules.tables() {
+		columns := make([]string, 0, len(rules[table]))
+		for column := range rules[table] {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+
+		expressions, err := rules.selectList(ctx, conn, table, columns)
+		if err != nil {
+			return err
+		}
+		assignments := make([]string, len(columns))
+		for i, column := range columns {
+			assignments[i] = pq.QuoteIdentifier(column) + " = " + expressions[i]
+		}
+
+		query := fmt.Sprintf("UPDATE %s SET %s", conn.qualified(table), strings.Join(assignments, ", "))
+		result, err := tx.ExecContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("anonymizing %s: %w", table, err)
+		}
+		rows, _ := result.RowsAffected()
+		logInfo("Anonymized ", rows, " rows of ", tenant, ".", table)
+	}
+
+	return tx.Commit()
+}
+
+func (r AnonymizationRules) tables() []string {
+	tables := make([]string, 0, len(r))
+	for table := range r {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// check recusa regras para tabelas ou colunas inexistentes no schema, para
+// que um nome errado não deixe dados reais passarem sem máscara
+func (r AnonymizationRules) check(ctx context.Context, conn Connection) error {
+	for _, table := range r.tables() {
+		columns, err := conn.insertableColumns(ctx, table)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrUnknownMaskColumn, err)
+		}
+
+		known := make(map[string]bool, len(columns))
+		for _, column := range columns {
+			known[column] = true
+		}
+		for column := range r[table] {
+			if !known[column] {
+				return fmt.Errorf("%w: %s.%s", ErrUnknownMaskColumn, table, column)
+			}
+		}
+	}
+	return nil
+}
+
+// selectList devolve as expressões que leem as colunas da tabela de conn, com
+// as mascaradas convertidas para o tipo original da coluna
+func (r AnonymizationRules) selectList(ctx context.Context, conn Connection, table string, columns []string) ([]string, error) {
+	list := make([]string, len(columns))
+	for i, column := range columns {
+		list[i] = pq.QuoteIdentifier(column)
+	}
+	if len(r[table]) == 0 {
+		return list, nil
+	}
+
+	types, err := conn.columnTypes(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	for i, column := range columns {
+		if rule := r[table][column]; rule != nil {
+			list[i] = fmt.Sprintf("CAST((%s) AS %s)", rule(list[i]), types[column])
+		}
+	}
+	return list, nil
+}
+
+// columnTypes devolve o tipo de cada coluna da tabela, como escrito na DDL
+func (c Connection) columnTypes(ctx context.Context, table string) (map[string]string, error) {
+	rows, err := c.QueryContext(ctx, `
+        SELECT attname, format_type(atttypid, atttypmod)
+        FROM pg_attribute
+        WHERE attrelid = $1::regclass AND attnum > 0 AND NOT attisdropped`, c.qualified(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := make(map[string]string)
+	for rows.Next() {
+		var column, dataType string
+		if err := rows.Scan(&column, &dataType); err != nil {
+			return nil, err
+		}
+		types[column] = dataType
+	}
+	return types, rows.Err()
+}
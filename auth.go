@@ -0,0 +1,223 @@
+package connection
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrUnknownAuthType = errors.New("unknown auth type")
+
+// TokenSource obtém o token usado como senha do usuário do tenant e o
+// momento em que ele expira.
+type TokenSource func(ctx context.Context, catalog *Catalog) (token string, expiry time.Time, err error)
+
+// Antecedência com que o token é renovado antes de expirar
+var TokenRefreshMargin = 5 * time.Minute
+
+var (
+	authTypesMutex sync.RWMutex
+	authTypes      = map[string]TokenSource{
+		"azure-ad": AzureManagedIdentityToken,
+	}
+)
+
+// RegisterAuthType registra a origem dos tokens dos tenants com o nome usado
+// na coluna auth_type do catálogo. "azure-ad" já vem registrado com
+// AzureManagedIdentityToken.
+func RegisterAuthType(name string, source TokenSource) {
+	authTypesMutex.Lock()
+	defer authTypesMutex.Unlock()
+
+	authTypes[name] = source
+}
+
+func registeredAuthType(name string) (TokenSource, error) {
+	authTypesMutex.RLock()
+	defer authTypesMutex.RUnlock()
+
+	source, found := authTypes[name]
+	if !found {
+		return nil, fmt.Errorf("%w %q", ErrUnknownAuthType, name)
+	}
+	return source, nil
+}
+
+type authToken struct {
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+var (
+	tokensMutex sync.Mutex
+	// Tokens em cache por tipo de autenticação, usuário e servidor
+	tokens = make(map[string]*authToken)
+)
+
+// authTokenFor devolve o token em cache do usuário do tenant, obtendo um novo
+// quando ele está a menos de TokenRefreshMargin de expirar
+func authTokenFor(ctx context.Context, catalog *Catalog) (string, error) {
+	source, err := registeredAuthType(catalog.AuthType)
+	if err != nil {
+		return "", err
+	}
+
+	key := catalog.AuthType + "\x00" + catalog.UserName + "\x00" + catalog.Server
+	tokensMutex.Lock()
+	cached, found := tokens[key]
+	if !found {
+		cached = &authToken{}
+		tokens[key] = cached
+	}
+	tokensMutex.Unlock()
+
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+
+	if cached.token != "" && clock.Now().Add(TokenRefreshMargin).Before(cached.expiry) {
+		return cached.token, nil
+	}
+
+	token, expiry, err := source(ctx, catalog)
+	if err != nil {
+		return "", fmt.Errorf("auth token for tenant %s: %w", catalog.SchemaName, err)
+	}
+	cached.token, cached.expiry = token, expiry
+	return token, nil
+}
+
+// forgetAuthToken descarta o token em cache, como após uma falha de
+// autenticação
+func forgetAuthToken(catalog *Catalog) {
+	tokensMutex.Lock()
+	defer tokensMutex.Unlock()
+
+	delete(tokens, catalog.AuthType+"\x00"+catalog.UserName+"\x00"+catalog.Server)
+}
+
+// withAuthToken devolve uma cópia do catálogo com o token como senha quando
+// o tenant usa auth_type
+func withAuthToken(ctx context.Context, catalog *Catalog) (*Catalog, error) {
+	if catalog.AuthType == "" {
+		return catalog, nil
+	}
+
+	token, err := authTokenFor(ctx, catalog)
+	if err != nil {
+		return nil, err
+	}
+	copied := *catalog
+	copied.Password = token
+	return &copied, nil
+}
+
+// tokenConnector abre cada conexão com o token atual, já que a senha da DSN
+// do lib/pq é fixa e os tokens expiram enquanto o pool continua aberto
+type tokenConnector struct {
+	catalog *Catalog
+	opts    TenantConnectOptions
+}
+
+func newTokenConnector(catalog *Catalog, opts TenantConnectOptions) (*tokenConnector, error) {
+	if _, err := registeredAuthType(catalog.AuthType); err != nil {
+		return nil, err
+	}
+	return &tokenConnector{catalog: catalog, opts: opts}, nil
+}
+
+func (c *tokenConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connect(ctx)
+	if isAuthFailure(err) {
+		// O token pode ter sido revogado antes de expirar
+		forgetAuthToken(c.catalog)
+		conn, err = c.connect(ctx)
+	}
+	return conn, err
+}
+
+func (c *tokenConnector) connect(ctx context.Context) (driver.Conn, error) {
+	catalog, err := withAuthToken(ctx, c.catalog)
+	if err != nil {
+		return nil, err
+	}
+
+	connector, err := driverConnector(catalog, c.opts)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *tokenConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// Recurso do Azure Database for PostgreSQL para o qual o token é emitido
+const azureDatabaseResource = "https://ossrdbms-aad.database.windows.net"
+
+// Endpoint de tokens da managed identity (IMDS) nas VMs e no AKS
+var AzureTokenEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// AzureManagedIdentityToken obtém um token do Azure AD para o Azure Database
+// for PostgreSQL usando a managed identity do ambiente: o IMDS nas VMs e no
+// AKS ou IDENTITY_ENDPOINT no App Service e no Container Apps. Com
+// AZURE_CLIENT_ID, usa a identidade atribuída pelo usuário. O user_name do
+// catálogo deve ser o nome da identidade no servidor.
+func AzureManagedIdentityToken(ctx context.Context, _ *Catalog) (string, time.Time, error) {
+	endpoint, apiVersion := AzureTokenEndpoint, "2018-02-01"
+	header, headerValue := "Metadata", "true"
+	if identityEndpoint := os.Getenv("IDENTITY_ENDPOINT"); identityEndpoint != "" {
+		endpoint, apiVersion = identityEndpoint, "2019-08-01"
+		header, headerValue = "X-IDENTITY-HEADER", os.Getenv("IDENTITY_HEADER")
+	}
+
+	query := url.Values{}
+	query.Set("api-version", apiVersion)
+	query.Set("resource", azureDatabaseResource)
+	if clientID := os.Getenv("AZURE_CLIENT_ID"); clientID != "" {
+		query.Set("client_id", clientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set(header, headerValue)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("managed identity token: %s", resp.Status)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		// Segundos desde a época, como texto ou número
+		ExpiresOn json.RawMessage `json:"expires_on"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresOn, err := strconv.ParseInt(strings.Trim(string(body.ExpiresOn), `"`), 10, 64)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("managed identity token expires_on: %w", err)
+	}
+	return body.AccessToken, time.Unix(expiresOn, 0), nil
+}
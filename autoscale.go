@@ -0,0 +1,108 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type PoolAutoscaleOptions struct {
+	// Limites de MaxOpenConns de cada pool
+	MinOpenConns int
+	MaxOpenConns int
+	// Intervalo entre as avaliações; padrão de 10s
+	Interval time.Duration
+	// Quantidade de conexões adicionadas ou removidas por avaliação; padrão 2
+	Step int
+	// Abaixo desta utilização (InUse/MaxOpenConns), sem esperas no intervalo,
+	// o pool é reduzido; padrão 0.5
+	LowUtilization float64
+}
+
+type autoscaleState struct {
+	waitCount int64
+}
+
+// AutoscalePools ajusta periodicamente o MaxOpenConns de cada pool aberto,
+// dentro dos limites das opções, até o contexto ser cancelado. O pool cresce
+// quando houve espera por conexões no último intervalo (WaitCount do
+// sql.DBStats) e diminui quando a utilização fica abaixo de LowUtilization.
+// Pools sem limite (MaxOpenConns zero) começam em MinOpenConns.
+func AutoscalePools(ctx context.Context, opts PoolAutoscaleOptions) {
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Second
+	}
+	if opts.Step <= 0 {
+		opts.Step = 2
+	}
+	if opts.LowUtilization <= 0 {
+		opts.LowUtilization = 0.5
+	}
+	if opts.MinOpenConns <= 0 {
+		opts.MinOpenConns = 1
+	}
+	if opts.MaxOpenConns < opts.MinOpenConns {
+		opts.MaxOpenConns = opts.MinOpenConns
+	}
+
+	ticker := clock.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	states := make(map[*sql.DB]*autoscaleState)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+
+		seen := make(map[*sql.DB]bool)
+		for _, conn := range defaultManager.openConnections() {
+			seen[conn.DB] = true
+
+			state, found := states[conn.DB]
+			if !found {
+				state = &autoscaleState{}
+				states[conn.DB] = state
+			}
+			autoscalePool(conn, state, opts)
+		}
+
+		// Descarta o estado dos pools que já foram fechados
+		for db := range states {
+			if !seen[db] {
+				delete(states, db)
+			}
+		}
+	}
+}
+
+func autoscalePool(conn Connection, state *autoscaleState, opts PoolAutoscaleOptions) {
+	stats := conn.DB.Stats()
+	waits := stats.WaitCount - state.waitCount
+	state.waitCount = stats.WaitCount
+
+	current := stats.MaxOpenConnections
+	target := current
+	switch {
+	case current == 0 || current < opts.MinOpenConns:
+		target = opts.MinOpenConns
+	case current > opts.MaxOpenConns:
+		target = opts.MaxOpenConns
+	case waits > 0:
+		target = current + opts.Step
+		if target > opts.MaxOpenConns {
+			target = opts.MaxOpenConns
+		}
+	case float64(stats.InUse)/float64(current) < opts.LowUtilization:
+		target = current - opts.Step
+		if target < opts.MinOpenConns {
+			target = opts.MinOpenConns
+		}
+	}
+
+	if target != current {
+		logInfo("Autoscale pool for tenant ", conn.SearchPath, ": ", current, " -> ", target, " (waits ", waits, ", in use ", stats.InUse, ")")
+		conn.DB.SetMaxOpenConns(target)
+	}
+}
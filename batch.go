@@ -0,0 +1,80 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"runtime/pprof"
+)
+
+type BatchStatement struct {
+	// Nome usado nos logs; quando vazio, é usada a posição do comando
+	Name  string
+	Query string
+	Args  []interface{}
+}
+
+// ExecBatch executa os comandos em uma única transação, na ordem recebida.
+// Se algum comando falhar, a transação é desfeita e o erro indica qual foi.
+func (c Connection) ExecBatch(ctx context.Context, stmts []BatchStatement) (_ []sql.Result, err error) {
+	defer scrubPanic(&err)
+
+	if err := c.checkCanceled(ctx); err != nil {
+		return nil, err
+	}
+	// Nenhum comando é executado quando algum deles é recusado
+	for _, stmt := range stmts {
+		if err := c.guardStatement(stmt.Query); err != nil {
+			return nil, err
+		}
+		if err := c.checkInjection(stmt.Query, stmt.Args); err != nil {
+			return nil, err
+		}
+		if err := c.checkAllowed(stmt.Query); err != nil {
+			return nil, err
+		}
+	}
+	c.touch()
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+
+	var results []sql.Result
+	pprof.Do(ctx, c.labels(), func(ctx context.Context) {
+		results, err = c.execBatch(ctx, stmts)
+	})
+	return results, err
+}
+
+func (c Connection) execBatch(ctx context.Context, stmts []BatchStatement) ([]sql.Result, error) {
+	start := clock.Now()
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, c.queryError(start, err)
+	}
+	defer tx.Rollback()
+
+	results := make([]sql.Result, 0, len(stmts))
+	for i, stmt := range stmts {
+		name := stmt.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+
+		start := clock.Now()
+		result, err := tx.ExecContext(ctx, stmt.Query, stmt.Args...)
+		c.recordQuery(stmt.Query, stmt.Args, err)
+		if err != nil {
+			logError("Batch statement ", name, " failed for tenant ", c.SearchPath, ": ", err, requestLogFields(ctx))
+			return nil, fmt.Errorf("batch statement %s: %w", name, err)
+		}
+
+		rows, _ := result.RowsAffected()
+		logInfo("Batch statement ", name, " for tenant ", c.SearchPath, ": ", rows, " rows in ", clock.Now().Sub(start), requestLogFields(ctx))
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
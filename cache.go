@@ -1,24 +1,127 @@
 package connection
 
 import (
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/dgraph-io/ristretto"
 )
 
+// Tempo que um pool removido do cache continua aberto, para que quem ainda
+// possui a Connection consiga terminar as queries em andamento
+const retiredPoolGracePeriod = time.Minute
+
+// Lock da gravação no cache e cache do Manager padrão
 var (
 	Mutex       sync.Mutex
 	Connections *ristretto.Cache
 )
 
 func init() {
-	var err error
-	Connections, err = ristretto.NewCache(&ristretto.Config{
-		NumCounters: 1e7,     // número de contadores de bits
-		MaxCost:     1 << 30, // tamanho máximo do cache em bytes
-		BufferItems: 64,      // tamanho do buffer interno
-	})
+	cache, err := newRistrettoCache()
 	if err != nil {
 		panic(err)
 	}
+	Connections = cache.cache
+	defaultManager.setCache(cache)
+}
+
+func (m *Manager) setCache(cache ConnCache) {
+	m.cache = cache
+	m.cache.OnEvict(m.onEvict)
+}
+
+func (m *Manager) onEvict(key string, value interface{}, expired bool) {
+	if conn, ok := value.(Connection); ok {
+		m.untrackConnection(conn)
+
+		reason := "evicted"
+		if expired {
+			reason = "expired"
+		}
+		m.emit(EventEvicted, conn.SearchPath, reason)
+	}
+}
+
+func (m *Manager) trackConnection(conn Connection) {
+	m.poolsMutex.Lock()
+	defer m.poolsMutex.Unlock()
+
+	m.pools[conn.poolKey()] = conn
+}
+
+func (m *Manager) untrackConnection(conn Connection) {
+	m.poolsMutex.Lock()
+	defer m.poolsMutex.Unlock()
+
+	// A conexão pode já ter sido substituída por uma nova para o mesmo tenant
+	if current, found := m.pools[conn.poolKey()]; found && current.DB == conn.DB {
+		delete(m.pools, conn.poolKey())
+	}
+}
+
+// trackedConnection retorna o pool aberto com a chave de poolKey
+func (m *Manager) trackedConnection(key string) (Connection, bool) {
+	m.poolsMutex.RLock()
+	defer m.poolsMutex.RUnlock()
+
+	conn, found := m.pools[key]
+	return conn, found
+}
+
+// tenantConnections retorna os pools abertos do tenant, o principal e os
+// nomeados
+func (m *Manager) tenantConnections(tenant string) []Connection {
+	var conns []Connection
+	for _, conn := range m.openConnections() {
+		if conn.SearchPath == tenant {
+			conns = append(conns, conn)
+		}
+	}
+	return conns
+}
+
+func (m *Manager) openConnections() []Connection {
+	m.poolsMutex.RLock()
+	defer m.poolsMutex.RUnlock()
+
+	conns := make([]Connection, 0, len(m.pools))
+	for _, conn := range m.pools {
+		conns = append(conns, conn)
+	}
+	sort.Slice(conns, func(i, j int) bool { return conns[i].poolKey() < conns[j].poolKey() })
+	return conns
+}
+
+// invalidateConnection remove os pools do tenant (o principal e os nomeados)
+// do cache, forçando a criação de novos na próxima chamada, e fecha os
+// antigos após o período de carência.
+func (m *Manager) invalidateConnection(tenant string) {
+	m.cache.Del(prefixConnection + tenant)
+	m.invalidatePools(tenant, func(Connection) bool { return true })
+}
+
+// invalidatePools remove do cache os pools do tenant selecionados por match,
+// como invalidateConnection
+func (m *Manager) invalidatePools(tenant string, match func(Connection) bool) {
+	m.poolsMutex.Lock()
+	var retired []Connection
+	for key, conn := range m.pools {
+		if conn.SearchPath == tenant && match(conn) {
+			retired = append(retired, conn)
+			delete(m.pools, key)
+		}
+	}
+	m.poolsMutex.Unlock()
+
+	for _, conn := range retired {
+		conn := conn
+		m.cache.Del(prefixConnection + conn.poolKey())
+		m.emit(EventEvicted, tenant, "invalidated")
+		clock.AfterFunc(retiredPoolGracePeriod, func() {
+			conn.DB.Close()
+			m.emit(EventClosed, tenant, "invalidated")
+		})
+	}
 }
@@ -0,0 +1,245 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+)
+
+// O timeout de QueryContext e QueryRowContext precisa valer até as linhas
+// serem lidas, depois do retorno do método. Para que ele não fique pendente
+// até expirar, os pools do pacote usam cancelConnector: a função de
+// cancelamento vai no contexto da query e é chamada quando o database/sql
+// fecha as linhas (rows.Close, fim do rows.Next ou Scan do *sql.Row).
+
+type rowsCancelKey struct{}
+
+// withRowsCancel guarda cancel no contexto para ser chamada quando as linhas
+// da query forem fechadas
+func withRowsCancel(ctx context.Context, cancel context.CancelFunc) context.Context {
+	return context.WithValue(ctx, rowsCancelKey{}, cancel)
+}
+
+// cancelsOnClose indica se o pool chama o cancelamento guardado por
+// withRowsCancel; pools de fora do pacote (connectiontest, sqlmock) não chamam
+func cancelsOnClose(db *sql.DB) bool {
+	_, ok := db.Driver().(cancelDriver)
+	return ok
+}
+
+type cancelConnector struct {
+	driver.Connector
+}
+
+func (c cancelConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return cancelConn{conn}, nil
+}
+
+func (c cancelConnector) Driver() driver.Driver {
+	return cancelDriver{c.Connector.Driver()}
+}
+
+type cancelDriver struct {
+	driver.Driver
+}
+
+func (d cancelDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return cancelConn{conn}, nil
+}
+
+// cancelConn repassa ao driver as interfaces opcionais implementadas pelo
+// lib/pq, envolvendo as linhas e os statements
+type cancelConn struct {
+	driver.Conn
+}
+
+func (c cancelConn) Prepare(query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return cancelStmt{stmt}, nil
+}
+
+func (c cancelConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	preparer, ok := c.Conn.(driver.ConnPrepareContext)
+	if !ok {
+		return c.Prepare(query)
+	}
+	stmt, err := preparer.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return cancelStmt{stmt}, nil
+}
+
+func (c cancelConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errors.New("driver does not support transaction options")
+	}
+	return c.Conn.Begin() //nolint:staticcheck
+}
+
+func (c cancelConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c cancelConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	rows, err := queryer.QueryContext(ctx, query, args)
+	return wrapRows(ctx, rows, err)
+}
+
+func (c cancelConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c cancelConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c cancelConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+type cancelStmt struct {
+	driver.Stmt
+}
+
+func (s cancelStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	// O COPY do lib/pq (pq.CopyIn) só implementa Exec
+	values, err := driverValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(values) //nolint:staticcheck
+}
+
+func (s cancelStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err := queryer.QueryContext(ctx, args)
+		return wrapRows(ctx, rows, err)
+	}
+	values, err := driverValues(args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.Stmt.Query(values) //nolint:staticcheck
+	return wrapRows(ctx, rows, err)
+}
+
+func driverValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("driver does not support named parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+func wrapRows(ctx context.Context, rows driver.Rows, err error) (driver.Rows, error) {
+	if err != nil {
+		return nil, err
+	}
+	cancel, ok := ctx.Value(rowsCancelKey{}).(context.CancelFunc)
+	if !ok {
+		return rows, nil
+	}
+	return &cancelRows{Rows: rows, cancel: cancel}, nil
+}
+
+// cancelRows chama o cancelamento da query ao ser fechada
+type cancelRows struct {
+	driver.Rows
+	cancel context.CancelFunc
+}
+
+func (r *cancelRows) Close() error {
+	err := r.Rows.Close()
+	r.cancel()
+	return err
+}
+
+func (r *cancelRows) HasNextResultSet() bool {
+	if next, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return next.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *cancelRows) NextResultSet() error {
+	if next, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return next.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (r *cancelRows) ColumnTypeScanType(index int) reflect.Type {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return rows.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+func (r *cancelRows) ColumnTypeDatabaseTypeName(index int) string {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return rows.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *cancelRows) ColumnTypeLength(index int) (int64, bool) {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return rows.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *cancelRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if rows, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return rows.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+func (r *cancelRows) ColumnTypeNullable(index int) (bool, bool) {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return rows.ColumnTypeNullable(index)
+	}
+	return false, false
+}
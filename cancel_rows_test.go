@@ -0,0 +1,98 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+)
+
+func cancelTestConnection(t *testing.T, wrap bool) (Connection, *stubConnector) {
+	t.Helper()
+	_, stub := stubDB(map[string]stubResult{
+		"SELECT id": {columns: []string{"id"}, rows: [][]driver.Value{{int64(1)}, {int64(2)}}},
+	})
+	var db *sql.DB
+	if wrap {
+		db = sql.OpenDB(cancelConnector{stub})
+	} else {
+		db = sql.OpenDB(stub)
+	}
+	t.Cleanup(func() { db.Close() })
+	return Connection{DB: db, SearchPath: "cancel-rows-test", usage: defaultManager.newPoolUsage("cancel-rows-test"), queryTimeout: time.Hour}, stub
+}
+
+func TestQueryContextCancelsOnClose(t *testing.T) {
+	conn, stub := cancelTestConnection(t, true)
+
+	rows, err := conn.QueryContext(context.Background(), "SELECT id FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queryCtx := stub.lastContext
+	if queryCtx.Err() != nil {
+		t.Fatalf("query context canceled before the rows were read: %v", queryCtx.Err())
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) != 2 {
+		t.Fatalf("ids = %v, want 2 rows", ids)
+	}
+	rows.Close()
+	if queryCtx.Err() != context.Canceled {
+		t.Fatalf("query context after Close = %v, want context.Canceled", queryCtx.Err())
+	}
+}
+
+func TestQueryRowContextCancelsAfterScan(t *testing.T) {
+	conn, stub := cancelTestConnection(t, true)
+
+	row := conn.QueryRowContext(context.Background(), "SELECT id FROM t")
+	queryCtx := stub.lastContext
+	if queryCtx.Err() != nil {
+		t.Fatalf("query context canceled before Scan: %v", queryCtx.Err())
+	}
+	var id int64
+	if err := row.Scan(&id); err != nil || id != 1 {
+		t.Fatalf("Scan = %d, %v; want 1", id, err)
+	}
+	if queryCtx.Err() != context.Canceled {
+		t.Fatalf("query context after Scan = %v, want context.Canceled", queryCtx.Err())
+	}
+}
+
+func TestQueryWithoutCancelConnector(t *testing.T) {
+	// Pools de fora do pacote continuam dependendo do timeout
+	conn, stub := cancelTestConnection(t, false)
+
+	rows, err := conn.QueryContext(context.Background(), "SELECT id FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	if err := stub.lastContext.Err(); err != nil {
+		t.Fatalf("query context = %v, want it to live until the timeout", err)
+	}
+}
+
+func TestCancelsOnClose(t *testing.T) {
+	_, stub := stubDB(nil)
+	wrapped := sql.OpenDB(cancelConnector{stub})
+	defer wrapped.Close()
+	plain := sql.OpenDB(stub)
+	defer plain.Close()
+
+	if !cancelsOnClose(wrapped) {
+		t.Error("cancelsOnClose(cancelConnector) = false")
+	}
+	if cancelsOnClose(plain) {
+		t.Error("cancelsOnClose(plain connector) = true")
+	}
+}
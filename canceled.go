@@ -0,0 +1,59 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Código do Postgres para comando cancelado (query_canceled)
+const pqQueryCanceled = "57014"
+
+var ErrQueryCanceled = errors.New("query canceled before execution")
+
+// QueryCanceledError é retornado pelos métodos da Connection quando o
+// contexto já estava cancelado ou expirado antes de obter uma conexão do
+// pool. errors.Is reconhece ErrQueryCanceled e também o erro do contexto
+// (context.Canceled ou context.DeadlineExceeded).
+type QueryCanceledError struct {
+	Tenant string
+	Err    error
+}
+
+func (e *QueryCanceledError) Error() string {
+	return fmt.Sprintf("%v: tenant %s: %v", ErrQueryCanceled, e.Tenant, e.Err)
+}
+
+func (e *QueryCanceledError) Is(target error) bool {
+	return target == ErrQueryCanceled
+}
+
+func (e *QueryCanceledError) Unwrap() error {
+	return e.Err
+}
+
+// checkCanceled falha rápido quando o contexto já terminou, sem ocupar uma
+// conexão do pool, e contabiliza o cancelamento no uso do tenant
+func (c Connection) checkCanceled(ctx context.Context) error {
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+
+	if c.usage != nil {
+		c.usage.counters.canceled.Add(1)
+	}
+	return &QueryCanceledError{Tenant: c.SearchPath, Err: err}
+}
+
+// isCancellation indica que a query foi interrompida pelo contexto do
+// chamador, e não por uma falha do banco
+func isCancellation(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pqQueryCanceled
+}
@@ -0,0 +1,34 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCanceledBeforeExecution(t *testing.T) {
+	db, stub := stubDB(nil)
+	defer db.Close()
+	conn := Connection{DB: db, SearchPath: "canceled-test", usage: defaultManager.newPoolUsage("canceled-test")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, execErr := conn.ExecContext(ctx, "UPDATE t SET x = 1 WHERE id = 1")
+	_, queryErr := conn.QueryContext(ctx, "SELECT 1")
+	var n int
+	rowErr := conn.QueryRowContext(ctx, "SELECT 1").Scan(&n)
+
+	for name, err := range map[string]error{"ExecContext": execErr, "QueryContext": queryErr, "QueryRowContext": rowErr} {
+		var canceled *QueryCanceledError
+		if !errors.As(err, &canceled) || !errors.Is(err, context.Canceled) {
+			t.Errorf("%s error = %v, want *QueryCanceledError", name, err)
+		}
+	}
+	if len(stub.queries) != 0 {
+		t.Fatalf("canceled queries reached the driver: %q", stub.queries)
+	}
+	if got := conn.usage.counters.canceled.Load(); got != 3 {
+		t.Fatalf("canceled counter = %d, want 3", got)
+	}
+}
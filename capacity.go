@@ -0,0 +1,167 @@
+package connection
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type CapacityReportOptions struct {
+	// Inclui o tamanho dos schemas dos tenants e dos bancos, consultando cada
+	// banco dos servidores
+	IncludeSizes bool
+	// Tempo máximo da consulta de tamanhos em cada banco; padrão de 30s
+	SizeTimeout time.Duration
+}
+
+// ServerCapacity reúne os tenants de um servidor do catálogo.
+type ServerCapacity struct {
+	Server  string   `json:"server"`
+	Tenants []string `json:"tenants"`
+	// Conexões dos pools abertos nesta instância
+	OpenConnections int `json:"open_connections"`
+	InUse           int `json:"in_use"`
+	// Com IncludeSizes: tamanho de cada banco e do schema de cada tenant, em
+	// bytes
+	DatabaseBytes map[string]int64 `json:"database_bytes,omitempty"`
+	TenantBytes   map[string]int64 `json:"tenant_bytes,omitempty"`
+	// Erros da consulta de tamanhos, por banco
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// ServerCapacityReport agrupa os tenants do catálogo do Manager padrão por
+// servidor.
+func ServerCapacityReport(ctx context.Context, opts CapacityReportOptions) ([]ServerCapacity, error) {
+	return defaultManager.ServerCapacityReport(ctx, opts)
+}
+
+// ServerCapacityReport agrupa os tenants do catálogo por servidor, com as
+// conexões abertas pelos pools desta instância e, com IncludeSizes, o
+// tamanho de cada banco (pg_database_size) e do schema de cada tenant. Uma
+// falha ao consultar um banco fica em Errors e não interrompe o relatório.
+func (m *Manager) ServerCapacityReport(ctx context.Context, opts CapacityReportOptions) ([]ServerCapacity, error) {
+	if opts.SizeTimeout <= 0 {
+		opts.SizeTimeout = 30 * time.Second
+	}
+
+	catalogs, err := m.ListTenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	servers := make(map[string]*ServerCapacity)
+	tenantServer := make(map[string]string, len(catalogs))
+	// Tenants de cada banco, para consultar os tamanhos uma vez por banco
+	databases := make(map[string][]*Catalog)
+	for _, catalog := range catalogs {
+		server, found := servers[catalog.Server]
+		if !found {
+			server = &ServerCapacity{Server: catalog.Server}
+			servers[catalog.Server] = server
+		}
+		server.Tenants = append(server.Tenants, catalog.SchemaName)
+		tenantServer[catalog.SchemaName] = catalog.Server
+
+		key := catalog.Server + "/" + catalog.DatabaseName
+		databases[key] = append(databases[key], catalog)
+	}
+
+	for _, conn := range m.openConnections() {
+		server, found := servers[tenantServer[conn.SearchPath]]
+		if !found {
+			continue
+		}
+		stats := conn.DB.Stats()
+		server.OpenConnections += stats.OpenConnections
+		server.InUse += stats.InUse
+	}
+
+	if opts.IncludeSizes {
+		for _, tenants := range databases {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			m.collectSizes(ctx, servers[tenants[0].Server], tenants, opts.SizeTimeout)
+		}
+	}
+
+	report := make([]ServerCapacity, 0, len(servers))
+	for _, server := range servers {
+		sort.Strings(server.Tenants)
+		report = append(report, *server)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Server < report[j].Server })
+	return report, nil
+}
+
+// collectSizes consulta o tamanho do banco e dos schemas dos tenants com as
+// credenciais do primeiro tenant do banco
+func (m *Manager) collectSizes(ctx context.Context, server *ServerCapacity, tenants []*Catalog, timeout time.Duration) {
+	database := tenants[0].DatabaseName
+	fail := func(err error) {
+		logError("Capacity report for database ", database, " on ", server.Server, " failed: ", err)
+		if server.Errors == nil {
+			server.Errors = make(map[string]string)
+		}
+		server.Errors[database] = err.Error()
+	}
+
+	catalog, err := withAuthToken(ctx, tenants[0])
+	if err != nil {
+		fail(err)
+		return
+	}
+	db, err := openTenantDB(catalog, TenantConnectOptions{})
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var databaseBytes int64
+	if err := db.QueryRowContext(ctx, `SELECT pg_database_size(current_database())`).Scan(&databaseBytes); err != nil {
+		fail(err)
+		return
+	}
+
+	schemas := make([]string, len(tenants))
+	for i, tenant := range tenants {
+		schemas[i] = tenant.SchemaName
+	}
+	rows, err := db.QueryContext(ctx, `
+        SELECT n.nspname, COALESCE(SUM(pg_total_relation_size(c.oid)), 0)::bigint
+        FROM pg_namespace n
+        LEFT JOIN pg_class c ON c.relnamespace = n.oid AND c.relkind IN ('r', 'm')
+        WHERE n.nspname = ANY($1)
+        GROUP BY n.nspname`, pq.Array(schemas))
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer rows.Close()
+
+	if server.DatabaseBytes == nil {
+		server.DatabaseBytes = make(map[string]int64)
+		server.TenantBytes = make(map[string]int64)
+	}
+	server.DatabaseBytes[database] = databaseBytes
+	for rows.Next() {
+		var (
+			schema string
+			bytes  int64
+		)
+		if err := rows.Scan(&schema, &bytes); err != nil {
+			fail(err)
+			return
+		}
+		server.TenantBytes[schema] = bytes
+	}
+	if err := rows.Err(); err != nil {
+		fail(err)
+	}
+}
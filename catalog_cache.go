@@ -0,0 +1,83 @@
+package connection
+
+import (
+	"context"
+	"time"
+)
+
+const prefixCatalog = "catalog-"
+
+// Sem refresh periódico os registros pré-carregados expiram após este tempo
+const defaultCatalogTTL = 55 * time.Minute
+
+// PreloadCatalog carrega todos os tenants do catálogo no cache, eliminando a
+// consulta ao catálogo na criação das conexões. Quando refreshInterval é maior
+// que zero, o cache é recarregado periodicamente até o contexto ser cancelado.
+func PreloadCatalog(ctx context.Context, refreshInterval time.Duration) error {
+	return defaultManager.PreloadCatalog(ctx, refreshInterval)
+}
+
+func (m *Manager) PreloadCatalog(ctx context.Context, refreshInterval time.Duration) error {
+	ttl := defaultCatalogTTL
+	if refreshInterval > 0 {
+		// Tenants removidos do catálogo expiram após duas atualizações
+		ttl = 2 * refreshInterval
+	}
+
+	if err := m.loadCatalog(ctx, ttl); err != nil {
+		return err
+	}
+
+	if refreshInterval > 0 {
+		go m.refreshCatalog(ctx, refreshInterval, ttl)
+	}
+
+	return nil
+}
+
+func (m *Manager) refreshCatalog(ctx context.Context, interval, ttl time.Duration) {
+	ticker := clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if err := m.loadCatalog(ctx, ttl); err != nil {
+				logError("Catalog refresh failed: ", err)
+			}
+		}
+	}
+}
+
+func (m *Manager) loadCatalog(ctx context.Context, ttl time.Duration) error {
+	catalogs, err := m.ListTenants(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, catalog := range catalogs {
+		m.cache.Set(prefixCatalog+catalog.SchemaName, catalog, ttl)
+	}
+	m.snapshot.remember(catalogs...)
+
+	// Garante que os registros estejam visíveis antes de retornar
+	if cache, ok := m.cache.(interface{ Wait() }); ok {
+		cache.Wait()
+	}
+	logInfo("Catalog loaded: ", len(catalogs), " tenants")
+
+	return nil
+}
+
+func (m *Manager) cachedCatalog(tenant string) (*Catalog, bool) {
+	value, found := m.cache.Get(prefixCatalog + tenant)
+	if !found {
+		return nil, false
+	}
+
+	// Devolve uma cópia para que o chamador não altere o registro em cache
+	catalog := *value.(*Catalog)
+	return &catalog, true
+}
@@ -3,12 +3,14 @@ package connection
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 var ErrRecordNotFound = errors.New("record not found")
@@ -20,58 +22,329 @@ type Catalog struct {
 	Server       string
 	DatabaseName string
 	SchemaName   string
+	// Região do servidor principal do tenant
+	Region string
+	// Parâmetros adicionais da DSN (port, sslmode, connect_timeout, ...)
+	// vindos da coluna options (jsonb) do catálogo
+	Options map[string]string
+	// Tenant em manutenção não recebe novas conexões
+	Maintenance bool
+	// Opções padrão de conexão do tenant, da coluna connect_options (jsonb)
+	Defaults TenantDefaults
+	// Nome do dialer registrado com RegisterDialer usado para alcançar o
+	// servidor do tenant, como um túnel SSH; vazio usa a conexão TCP direta
+	Dialer string
+	// Origem do token usado como senha, registrada com RegisterAuthType
+	// (azure-ad, ...); vazio usa a senha do catálogo
+	AuthType string
+
+	// Catálogo de uma réplica devolvido por regionalCatalog; os hosts são
+	// escolhidos pela saúde de cada um
+	readReplica bool
 }
 
-var (
-	dbCatalog *sql.DB
-	once      sync.Once
-)
+var once sync.Once
 
+// Connect abre o banco do catálogo do Manager padrão.
 func Connect(url string) {
 	var err error
 
-	dbCatalog, err = sql.Open("postgres", url+"?sslmode=disable")
+	defaultManager.dsn = url + "?sslmode=disable"
+	defaultManager.catalog, err = sql.Open("postgres", defaultManager.dsn)
 	if err != nil {
 		panic(err)
 	}
-	log.Println("Catalog database connection estabilished:", url)
+	logInfo("Catalog database connection estabilished:", scrubSecrets(url))
 }
 
 func GetCatalogConnection(url string) *sql.DB {
 	once.Do(func() { Connect(url) })
-	return dbCatalog
+	return defaultManager.catalog
 }
 
 func GetTenant(tenant string) (*Catalog, error) {
-	query := `
-        SELECT driver, user_name, password, server, database_name, schema_name
-        FROM catalog
-		WHERE schema_name = $1
-        LIMIT 1`
+	return GetTenantContext(context.Background(), tenant)
+}
 
-	var catalog Catalog
+func GetTenantContext(ctx context.Context, tenant string) (*Catalog, error) {
+	return defaultManager.GetTenant(ctx, tenant)
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// GetTenant busca o tenant no catálogo do Manager.
+func (m *Manager) GetTenant(ctx context.Context, tenant string) (*Catalog, error) {
+	// Tenants pré-carregados por PreloadCatalog não precisam de consulta
+	if catalog, found := m.cachedCatalog(tenant); found {
+		return catalog, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 
 	defer cancel()
 
-	err := dbCatalog.QueryRowContext(ctx, query, tenant).Scan(
+	if catalog, found := m.sharedCatalogGet(ctx, tenant); found {
+		return catalog, nil
+	}
+
+	catalog, err := m.queryTenant(ctx, tenant)
+	if err != nil {
+		// Com o catálogo fora do ar, usa o último registro lido do tenant
+		if !errors.Is(err, ErrRecordNotFound) {
+			if snapshot, loadedAt, found := m.snapshot.lookup(tenant); found {
+				logError("Catalog unavailable, using snapshot of tenant ", tenant, " loaded at ", loadedAt.Format(time.RFC3339),
+					" (", clock.Now().Sub(loadedAt).Round(time.Second), " old): ", err)
+				m.metrics.snapshots.Add(1)
+				return snapshot, nil
+			}
+		}
+		return nil, err
+	}
+	m.snapshot.remember(catalog)
+
+	m.sharedCatalogSet(ctx, catalog)
+
+	return catalog, nil
+}
+
+func queryTenant(ctx context.Context, tenant string) (*Catalog, error) {
+	return defaultManager.queryTenant(ctx, tenant)
+}
+
+// queryTenant consulta o catálogo diretamente, sem passar pelos caches
+func (m *Manager) queryTenant(ctx context.Context, tenant string) (catalog *Catalog, err error) {
+	start := clock.Now()
+	defer func() { m.metrics.record(clock.Now().Sub(start), err) }()
+
+	err = m.readCatalog(ctx, func(ctx context.Context, db *sql.DB) error {
+		columns, err := m.catalogColumns(ctx, db)
+		if err != nil {
+			return err
+		}
+		query := `
+            SELECT ` + columns + `
+            FROM catalog
+            WHERE schema_name = $1
+            LIMIT 1`
+
+		catalog, err = scanCatalog(db.QueryRowContext(ctx, query, tenant))
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return catalog, nil
+}
+
+// GetTenants busca vários tenants no catálogo em uma única consulta. Tenants
+// que não existem no catálogo ficam de fora do mapa retornado.
+func GetTenants(ctx context.Context, names []string) (map[string]*Catalog, error) {
+	return defaultManager.GetTenants(ctx, names)
+}
+
+func (m *Manager) GetTenants(ctx context.Context, names []string) (map[string]*Catalog, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+
+	defer cancel()
+
+	var catalogs map[string]*Catalog
+	err := m.readCatalog(ctx, func(ctx context.Context, db *sql.DB) error {
+		columns, err := m.catalogColumns(ctx, db)
+		if err != nil {
+			return err
+		}
+		query := `
+            SELECT ` + columns + `
+            FROM catalog
+            WHERE schema_name = ANY($1)`
+
+		rows, err := db.QueryContext(ctx, query, pq.Array(names))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		catalogs = make(map[string]*Catalog, len(names))
+		for rows.Next() {
+			catalog, err := scanCatalog(rows)
+			if err != nil {
+				return err
+			}
+			catalogs[catalog.SchemaName] = catalog
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return catalogs, nil
+}
+
+// ListTenants retorna todos os tenants do catálogo, ordenados pelo schema.
+func ListTenants(ctx context.Context) ([]*Catalog, error) {
+	return defaultManager.ListTenants(ctx)
+}
+
+// ListTenants retorna todos os tenants do catálogo do Manager.
+func (m *Manager) ListTenants(ctx context.Context) ([]*Catalog, error) {
+	var catalogs []*Catalog
+	err := m.readCatalog(ctx, func(ctx context.Context, db *sql.DB) error {
+		columns, err := m.catalogColumns(ctx, db)
+		if err != nil {
+			return err
+		}
+		query := `
+            SELECT ` + columns + `
+            FROM catalog
+            ORDER BY schema_name`
+
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		catalogs = nil
+		for rows.Next() {
+			catalog, err := scanCatalog(rows)
+			if err != nil {
+				return err
+			}
+			catalogs = append(catalogs, catalog)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return catalogs, nil
+}
+
+// Colunas acrescentadas à tabela catalog por EnsureCatalogSchema, na ordem de
+// scanCatalog, com o valor lido quando a coluna não existe
+var optionalCatalogColumns = []struct {
+	name, fallback string
+}{
+	{"region", "''"},
+	{"options", "'{}'"},
+	{"maintenance", "false"},
+	{"connect_options", "'{}'"},
+	{"dialer", "''"},
+	{"auth_type", "''"},
+}
+
+// catalogColumns devolve a lista de colunas lida por scanCatalog. As colunas
+// opcionais que não existem no catálogo, criado antes de EnsureCatalogSchema
+// acrescentá-las, são lidas com o valor padrão. A lista é montada na primeira
+// consulta e novamente após EnsureCatalogSchema.
+func (m *Manager) catalogColumns(ctx context.Context, db *sql.DB) (string, error) {
+	m.catalogColumnsMutex.Lock()
+	defer m.catalogColumnsMutex.Unlock()
+
+	if m.catalogColumnList != "" {
+		return m.catalogColumnList, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+        SELECT attname
+        FROM pg_attribute
+        WHERE attrelid = 'catalog'::regclass AND attnum > 0 AND NOT attisdropped`)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return "", err
+		}
+		existing[column] = true
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	m.catalogColumnList = catalogColumnList(existing)
+	return m.catalogColumnList, nil
+}
+
+func catalogColumnList(existing map[string]bool) string {
+	columns := []string{"driver", "user_name", "password", "server", "database_name", "schema_name"}
+	var missing []string
+	for _, column := range optionalCatalogColumns {
+		if existing[column.name] {
+			columns = append(columns, "COALESCE("+column.name+", "+column.fallback+")")
+		} else {
+			columns = append(columns, column.fallback)
+			missing = append(missing, column.name)
+		}
+	}
+	if len(missing) > 0 {
+		logError("Catalog table without columns ", strings.Join(missing, ", "), ", using defaults; run EnsureCatalogSchema to add them")
+	}
+	return strings.Join(columns, ", ")
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCatalog(row rowScanner) (*Catalog, error) {
+	var (
+		catalog  Catalog
+		options  []byte
+		defaults []byte
+	)
+
+	err := row.Scan(
 		&catalog.Driver,
 		&catalog.UserName,
 		&catalog.Password,
 		&catalog.Server,
 		&catalog.DatabaseName,
 		&catalog.SchemaName,
+		&catalog.Region,
+		&options,
+		&catalog.Maintenance,
+		&defaults,
+		&catalog.Dialer,
+		&catalog.AuthType,
 	)
+	if err != nil {
+		return nil, err
+	}
 
+	catalog.Options, err = parseCatalogOptions(options)
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return nil, ErrRecordNotFound
-		default:
-			return nil, err
-		}
+		return nil, err
+	}
+
+	catalog.Defaults, err = parseTenantDefaults(defaults)
+	if err != nil {
+		return nil, err
 	}
 
 	return &catalog, nil
 }
+
+func parseCatalogOptions(data []byte) (map[string]string, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid catalog options: %w", err)
+	}
+
+	// Aceita valores numéricos e booleanos, como {"port": 5433}
+	options := make(map[string]string, len(raw))
+	for key, value := range raw {
+		options[key] = fmt.Sprint(value)
+	}
+	return options, nil
+}
@@ -0,0 +1,38 @@
+package connection
+
+import "testing"
+
+func TestCatalogColumnList(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		want     string
+	}{
+		{
+			"original table",
+			nil,
+			`driver, user_name, password, server, database_name, schema_name, '', '{}', false, '{}', '', ''`,
+		},
+		{
+			"partially migrated",
+			[]string{"options", "region"},
+			`driver, user_name, password, server, database_name, schema_name, COALESCE(region, ''), COALESCE(options, '{}'), false, '{}', '', ''`,
+		},
+		{
+			"current schema",
+			[]string{"region", "options", "maintenance", "connect_options", "dialer", "auth_type"},
+			`driver, user_name, password, server, database_name, schema_name, COALESCE(region, ''), COALESCE(options, '{}'), COALESCE(maintenance, false), COALESCE(connect_options, '{}'), COALESCE(dialer, ''), COALESCE(auth_type, '')`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := make(map[string]bool)
+			for _, column := range tt.existing {
+				existing[column] = true
+			}
+			if got := catalogColumnList(existing); got != tt.want {
+				t.Fatalf("catalogColumnList =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
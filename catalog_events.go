@@ -0,0 +1,96 @@
+package connection
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Canal do NOTIFY no banco do catálogo usado para avisar as instâncias sobre
+// mudanças nos tenants
+const catalogEventsChannel = "tenant_connection_events"
+
+type catalogEventType string
+
+const (
+	eventMaintenance catalogEventType = "maintenance"
+	eventResume      catalogEventType = "resume"
+	eventInvalidate  catalogEventType = "invalidate"
+)
+
+type catalogEvent struct {
+	Type   catalogEventType `json:"type"`
+	Tenant string           `json:"tenant"`
+}
+
+// WatchCatalogEvents escuta as mudanças de tenants no catálogo do Manager
+// padrão.
+func WatchCatalogEvents(ctx context.Context) error {
+	return defaultManager.WatchCatalogEvents(ctx)
+}
+
+// WatchCatalogEvents escuta (LISTEN) as mudanças de tenants publicadas por
+// outras instâncias no banco do catálogo, como manutenção e troca de servidor,
+// até o contexto ser cancelado. A reconexão é feita automaticamente.
+func (m *Manager) WatchCatalogEvents(ctx context.Context) error {
+	listener := pq.NewListener(m.dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logError("Catalog events listener: ", err)
+		}
+	})
+	if err := listener.Listen(catalogEventsChannel); err != nil {
+		listener.Close()
+		return err
+	}
+
+	go func() {
+		defer listener.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case notification := <-listener.Notify:
+				// nil é enviado após uma reconexão, quando eventos podem ter
+				// sido perdidos
+				if notification == nil {
+					continue
+				}
+				m.handleCatalogEvent(notification.Extra)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (m *Manager) handleCatalogEvent(payload string) {
+	var event catalogEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		logError("Invalid catalog event: ", err)
+		return
+	}
+
+	switch event.Type {
+	case eventMaintenance:
+		m.setMaintenance(event.Tenant, true)
+		m.invalidateLocal(event.Tenant)
+	case eventResume:
+		m.setMaintenance(event.Tenant, false)
+		m.invalidateLocal(event.Tenant)
+	case eventInvalidate:
+		m.invalidateLocal(event.Tenant)
+	}
+}
+
+func (m *Manager) notifyCatalogEvent(ctx context.Context, event catalogEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.catalog.ExecContext(ctx, `SELECT pg_notify($1, $2)`, catalogEventsChannel, string(payload))
+	return err
+}
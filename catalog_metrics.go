@@ -0,0 +1,107 @@
+package connection
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Limites superiores dos buckets do histograma de duração das consultas ao
+// catálogo
+var catalogLatencyBuckets = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// CatalogStats resume as consultas feitas ao banco do catálogo. Buscas
+// atendidas pelos caches não são contadas.
+type CatalogStats struct {
+	Lookups  uint64 `json:"lookups"`
+	NotFound uint64 `json:"not_found"`
+	// Consultas que excederam o prazo ou foram canceladas
+	Timeouts uint64 `json:"timeouts"`
+	Errors   uint64 `json:"errors"`
+	// Leituras repetidas na réplica do catálogo
+	Fallbacks uint64 `json:"fallbacks"`
+	// Buscas atendidas pelo snapshot com o catálogo fora do ar
+	Snapshots uint64 `json:"snapshots"`
+	// Soma da duração das consultas, em segundos
+	DurationSeconds float64 `json:"duration_seconds"`
+	// Quantidade acumulada de consultas com duração até cada limite, como nos
+	// histogramas do Prometheus; o total (+Inf) é Lookups
+	Buckets []LatencyBucket `json:"buckets"`
+}
+
+type LatencyBucket struct {
+	// Limite superior, em segundos
+	UpperBound float64 `json:"le"`
+	Count      uint64  `json:"count"`
+}
+
+type catalogMetrics struct {
+	lookups   atomic.Uint64
+	notFound  atomic.Uint64
+	timeouts  atomic.Uint64
+	errors    atomic.Uint64
+	fallbacks atomic.Uint64
+	snapshots atomic.Uint64
+	duration  atomic.Int64
+	buckets   [len(catalogLatencyBuckets)]atomic.Uint64
+}
+
+func (m *catalogMetrics) record(elapsed time.Duration, err error) {
+	m.lookups.Add(1)
+	m.duration.Add(int64(elapsed))
+
+	for i, bound := range catalogLatencyBuckets {
+		if elapsed <= bound {
+			m.buckets[i].Add(1)
+			break
+		}
+	}
+
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrRecordNotFound):
+		m.notFound.Add(1)
+	case isCancellation(err):
+		m.timeouts.Add(1)
+	default:
+		m.errors.Add(1)
+	}
+}
+
+func (m *catalogMetrics) stats() CatalogStats {
+	stats := CatalogStats{
+		Lookups:         m.lookups.Load(),
+		NotFound:        m.notFound.Load(),
+		Timeouts:        m.timeouts.Load(),
+		Errors:          m.errors.Load(),
+		Fallbacks:       m.fallbacks.Load(),
+		Snapshots:       m.snapshots.Load(),
+		DurationSeconds: time.Duration(m.duration.Load()).Seconds(),
+		Buckets:         make([]LatencyBucket, len(catalogLatencyBuckets)),
+	}
+
+	var count uint64
+	for i, bound := range catalogLatencyBuckets {
+		count += m.buckets[i].Load()
+		stats.Buckets[i] = LatencyBucket{UpperBound: bound.Seconds(), Count: count}
+	}
+
+	return stats
+}
+
+// CatalogStats retorna as métricas das consultas ao catálogo do Manager.
+func (m *Manager) CatalogStats() CatalogStats {
+	return m.metrics.stats()
+}
@@ -0,0 +1,54 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Tempo máximo de uma consulta ao catálogo principal antes de recorrer à
+// réplica, quando configurada. Sem réplica vale apenas o prazo do chamador.
+var CatalogFallbackTimeout = 2 * time.Second
+
+// ConnectCatalogReplica configura uma réplica somente leitura do catálogo do
+// Manager padrão, usada nas buscas de tenants quando o catálogo principal
+// está fora do ar. Assim como Connect, acrescenta sslmode=disable à URL.
+func ConnectCatalogReplica(url string) error {
+	return defaultManager.SetCatalogReplica(url + "?sslmode=disable")
+}
+
+// SetCatalogReplica configura a réplica do catálogo do Manager. A DSN é usada
+// como informada. Deve ser chamada na inicialização, antes do uso das
+// conexões. Escritas no catálogo (seed, rotação de senha, manutenção...)
+// continuam exigindo o catálogo principal.
+func (m *Manager) SetCatalogReplica(dsn string) error {
+	replica, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return err
+	}
+
+	m.replica = replica
+	return nil
+}
+
+// readCatalog executa a leitura no catálogo principal e, quando ele não
+// responde por falha de conexão ou excede CatalogFallbackTimeout, repete a
+// leitura na réplica
+func (m *Manager) readCatalog(ctx context.Context, read func(ctx context.Context, db *sql.DB) error) error {
+	if m.replica == nil {
+		return read(ctx, m.catalog)
+	}
+
+	primaryCtx, cancel := context.WithTimeout(ctx, CatalogFallbackTimeout)
+	err := read(primaryCtx, m.catalog)
+	cancel()
+
+	// Com o contexto do chamador encerrado não há tempo para a réplica
+	if err == nil || ctx.Err() != nil || !(isTransient(err) || isCancellation(err)) {
+		return err
+	}
+
+	logError("Catalog unavailable, reading from replica: ", err)
+	m.metrics.fallbacks.Add(1)
+	return read(ctx, m.replica)
+}
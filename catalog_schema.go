@@ -0,0 +1,105 @@
+package connection
+
+import (
+	"context"
+)
+
+// Cada comando é idempotente, permitindo executar EnsureCatalogSchema em
+// todo start da aplicação, inclusive sobre catálogos criados manualmente.
+var catalogSchema = []string{
+	`CREATE TABLE IF NOT EXISTS catalog (
+		driver        text NOT NULL DEFAULT 'postgres',
+		user_name     text NOT NULL,
+		password      text NOT NULL,
+		server        text NOT NULL,
+		database_name text NOT NULL,
+		schema_name   text NOT NULL
+	)`,
+	`ALTER TABLE catalog ADD COLUMN IF NOT EXISTS options jsonb`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS catalog_schema_name_key ON catalog (schema_name)`,
+	`ALTER TABLE catalog ADD COLUMN IF NOT EXISTS region text`,
+	`CREATE TABLE IF NOT EXISTS catalog_replica (
+		schema_name text NOT NULL,
+		region      text NOT NULL,
+		server      text NOT NULL,
+		options     jsonb,
+		PRIMARY KEY (schema_name, region)
+	)`,
+	`CREATE TABLE IF NOT EXISTS catalog_alias (
+		kind        text NOT NULL,
+		value       text NOT NULL,
+		schema_name text NOT NULL,
+		PRIMARY KEY (kind, value)
+	)`,
+	`CREATE INDEX IF NOT EXISTS catalog_alias_schema_name_idx ON catalog_alias (schema_name)`,
+	`ALTER TABLE catalog ADD COLUMN IF NOT EXISTS maintenance boolean NOT NULL DEFAULT false`,
+	`CREATE TABLE IF NOT EXISTS catalog_prepared_tx (
+		id           text PRIMARY KEY,
+		participants text[] NOT NULL,
+		created_at   timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE TABLE IF NOT EXISTS catalog_usage (
+		schema_name  text NOT NULL,
+		period_start timestamptz NOT NULL,
+		period_end   timestamptz NOT NULL,
+		queries      bigint NOT NULL,
+		errors       bigint NOT NULL,
+		bytes_sent   bigint NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS catalog_usage_schema_name_idx ON catalog_usage (schema_name, period_start)`,
+	`ALTER TABLE catalog ADD COLUMN IF NOT EXISTS connect_options jsonb`,
+	`ALTER TABLE catalog ADD COLUMN IF NOT EXISTS dialer text`,
+	`ALTER TABLE catalog_usage ADD COLUMN IF NOT EXISTS canceled bigint NOT NULL DEFAULT 0`,
+	`ALTER TABLE catalog ADD COLUMN IF NOT EXISTS auth_type text`,
+	`CREATE TABLE IF NOT EXISTS catalog_shard (
+		schema_name  text NOT NULL,
+		shard        integer NOT NULL,
+		shard_schema text NOT NULL,
+		PRIMARY KEY (schema_name, shard)
+	)`,
+	`CREATE TABLE IF NOT EXISTS catalog_storage_limit (
+		schema_name     text PRIMARY KEY,
+		max_bytes       bigint,
+		max_table_rows  bigint,
+		max_table_bytes bigint
+	)`,
+	`CREATE TABLE IF NOT EXISTS catalog_migration_snapshot (
+		schema_name       text NOT NULL,
+		snapshot_id       text NOT NULL,
+		migration_version bigint NOT NULL,
+		created_at        timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS catalog_migration_snapshot_schema_name_idx ON catalog_migration_snapshot (schema_name, created_at)`,
+	`CREATE TABLE IF NOT EXISTS catalog_server (
+		server text PRIMARY KEY,
+		weight integer NOT NULL DEFAULT 1
+	)`,
+}
+
+// EnsureCatalogSchema cria a tabela catalog e seus índices caso não existam.
+func EnsureCatalogSchema(ctx context.Context) error {
+	return defaultManager.EnsureCatalogSchema(ctx)
+}
+
+func (m *Manager) EnsureCatalogSchema(ctx context.Context) error {
+	tx, err := m.catalog.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range catalogSchema {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// As colunas acrescentadas passam a ser lidas
+	m.catalogColumnsMutex.Lock()
+	m.catalogColumnList = ""
+	m.catalogColumnsMutex.Unlock()
+	return nil
+}
@@ -0,0 +1,150 @@
+package connection
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Idade máxima do registro do snapshot usado com o catálogo fora do ar; zero
+// não limita
+var CatalogSnapshotMaxAge time.Duration
+
+// catalogSnapshot guarda o último registro lido com sucesso de cada tenant,
+// usado quando o catálogo não responde
+type catalogSnapshot struct {
+	mu      sync.Mutex
+	entries map[string]snapshotEntry
+	// Arquivo onde o snapshot é persistido; vazio mantém apenas em memória
+	path string
+
+	fileMutex sync.Mutex
+}
+
+type snapshotEntry struct {
+	Catalog  *Catalog  `json:"catalog"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// UseCatalogSnapshotFile persiste o snapshot do catálogo do Manager padrão no
+// arquivo informado e carrega o conteúdo já existente.
+func UseCatalogSnapshotFile(path string) error {
+	return defaultManager.UseCatalogSnapshotFile(path)
+}
+
+// UseCatalogSnapshotFile persiste o snapshot do catálogo no arquivo
+// informado, carregando o conteúdo já existente para que a aplicação consiga
+// iniciar com o catálogo fora do ar. O arquivo contém as senhas dos tenants e
+// é criado com permissão 0600. Deve ser chamada na inicialização.
+func (m *Manager) UseCatalogSnapshotFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	entries := make(map[string]snapshotEntry)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return err
+		}
+	}
+
+	m.snapshot.mu.Lock()
+	defer m.snapshot.mu.Unlock()
+
+	m.snapshot.path = path
+	if m.snapshot.entries == nil {
+		m.snapshot.entries = make(map[string]snapshotEntry)
+	}
+	for tenant, entry := range entries {
+		// Registros lidos do catálogo nesta execução são mais recentes
+		if _, found := m.snapshot.entries[tenant]; !found {
+			m.snapshot.entries[tenant] = entry
+		}
+	}
+
+	return nil
+}
+
+// remember atualiza o snapshot com os registros lidos do catálogo
+func (s *catalogSnapshot) remember(catalogs ...*Catalog) {
+	now := clock.Now()
+
+	s.mu.Lock()
+	if s.entries == nil {
+		s.entries = make(map[string]snapshotEntry)
+	}
+	changed := false
+	for _, catalog := range catalogs {
+		previous, found := s.entries[catalog.SchemaName]
+		if !found || !reflect.DeepEqual(previous.Catalog, catalog) {
+			changed = true
+		}
+		copied := *catalog
+		s.entries[catalog.SchemaName] = snapshotEntry{Catalog: &copied, LoadedAt: now}
+	}
+	path := s.path
+	s.mu.Unlock()
+
+	// O arquivo é regravado apenas quando algum registro mudou
+	if changed && path != "" {
+		if err := s.save(path); err != nil {
+			logError("Catalog snapshot save failed: ", err)
+		}
+	}
+}
+
+// lookup devolve o último registro do tenant, respeitando CatalogSnapshotMaxAge
+func (s *catalogSnapshot) lookup(tenant string) (*Catalog, time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, found := s.entries[tenant]
+	if !found {
+		return nil, time.Time{}, false
+	}
+	if CatalogSnapshotMaxAge > 0 && clock.Now().Sub(entry.LoadedAt) > CatalogSnapshotMaxAge {
+		return nil, time.Time{}, false
+	}
+
+	catalog := *entry.Catalog
+	return &catalog, entry.LoadedAt, true
+}
+
+func (s *catalogSnapshot) save(path string) error {
+	s.fileMutex.Lock()
+	defer s.fileMutex.Unlock()
+
+	s.mu.Lock()
+	data, err := json.Marshal(s.entries)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Grava em um arquivo temporário e renomeia, para não deixar o snapshot
+	// pela metade se o processo for interrompido
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
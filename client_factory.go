@@ -0,0 +1,63 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// ClientFactory mantém um client de ORM por tenant, criado a partir do pool do
+// tenant pela função open. O pacote não depende de nenhum ORM; com o ent, por
+// exemplo:
+//
+//	clients := connection.NewClientFactory(func(db *sql.DB) *ent.Client {
+//		return ent.NewClient(ent.Driver(entsql.OpenDB(dialect.Postgres, db)))
+//	})
+//
+//	client, err := clients.Client(ctx, "acme")
+//
+// Quando o pool do tenant é recriado (troca de senha, MoveTenant, etc.), o
+// client é recriado na próxima chamada. O client antigo não deve ser fechado
+// pelo chamador, pois o pool é fechado pelo próprio pacote.
+type ClientFactory[T any] struct {
+	open func(db *sql.DB) T
+	opts TenantConnectOptions
+
+	mu      sync.Mutex
+	clients map[string]factoryClient[T]
+}
+
+type factoryClient[T any] struct {
+	db     *sql.DB
+	client T
+}
+
+func NewClientFactory[T any](open func(db *sql.DB) T) *ClientFactory[T] {
+	return &ClientFactory[T]{open: open, clients: make(map[string]factoryClient[T])}
+}
+
+// WithOptions define as opções usadas para obter a conexão dos tenants.
+func (f *ClientFactory[T]) WithOptions(opts TenantConnectOptions) *ClientFactory[T] {
+	f.opts = opts
+	return f
+}
+
+// Client retorna o client do tenant, criando o pool quando necessário.
+func (f *ClientFactory[T]) Client(ctx context.Context, tenant string) (T, error) {
+	conn, err := GetTenantConnectionWithOptions(ctx, tenant, f.opts)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if cached, found := f.clients[tenant]; found && cached.db == conn.DB {
+		return cached.client, nil
+	}
+
+	client := f.open(conn.DB)
+	f.clients[tenant] = factoryClient[T]{db: conn.DB, client: client}
+	return client, nil
+}
@@ -0,0 +1,46 @@
+package connection
+
+import (
+	"time"
+)
+
+// Clock abstrai a fonte de tempo do pacote para que a lógica dependente de
+// tempo possa ser testada com um relógio falso (connectiontest.FakeClock).
+// O TTL interno do ristretto não é afetado.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type Timer interface {
+	Stop() bool
+}
+
+var clock Clock = realClock{}
+
+// SetClock troca a fonte de tempo do pacote. Deve ser chamada antes do uso
+// das conexões; nil restaura o relógio real.
+func SetClock(c Clock) {
+	if c == nil {
+		c = realClock{}
+	}
+	clock = c
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+type realTicker struct{ *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
@@ -0,0 +1,106 @@
+package connection
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+	"testing"
+	"time"
+)
+
+// manualClock é um Clock cujos timers só disparam por fire
+type manualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+}
+
+type manualTimer struct {
+	clock   *manualClock
+	d       time.Duration
+	fn      func()
+	stopped bool
+}
+
+func (t *manualTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	stopped := !t.stopped
+	t.stopped = true
+	return stopped
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) NewTicker(time.Duration) Ticker {
+	panic("manualClock: NewTicker not supported")
+}
+
+func (c *manualClock) AfterFunc(d time.Duration, fn func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	timer := &manualTimer{clock: c, d: d, fn: fn}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+// fire avança o relógio em d e dispara os timers pendentes de duração d,
+// retornando quantos dispararam
+func (c *manualClock) fire(d time.Duration) int {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due []*manualTimer
+	for _, timer := range c.timers {
+		if timer.d == d && !timer.stopped {
+			timer.stopped = true
+			due = append(due, timer)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, timer := range due {
+		timer.fn()
+	}
+	return len(due)
+}
+
+func TestDrainConnectionsUsesClock(t *testing.T) {
+	fake := &manualClock{now: time.Unix(0, 0)}
+	SetClock(fake)
+	defer SetClock(nil)
+
+	db, _ := stubDB(map[string]stubResult{"SELECT": {columns: []string{"n"}, rows: [][]driver.Value{{int64(1)}}}})
+	defer db.Close()
+	// Uma conexão em uso que nunca é devolvida
+	held, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Close()
+
+	done := make(chan struct{})
+	go func() {
+		drainConnections(context.Background(), []Connection{{DB: db, SearchPath: "drain-test"}})
+		close(done)
+	}()
+
+	// O prazo do drain só expira pelo clock do pacote
+	deadline := time.Now().Add(5 * time.Second)
+	for fake.fire(MoveDrainTimeout) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("drainConnections did not schedule its timeout on the package clock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("drainConnections did not return after MoveDrainTimeout on the fake clock")
+	}
+}
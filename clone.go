@@ -0,0 +1,139 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var ErrTenantExists = errors.New("tenant already exists")
+
+type CloneOptions struct {
+	// Copia também os registros das tabelas, além da estrutura
+	CopyData bool
+	// Caminho do pg_dump usado para obter a DDL; padrão "pg_dump"
+	PgDumpPath string
+	// Regras de mascaramento aplicadas aos dados copiados com CopyData
+	Anonymize AnonymizationRules
+}
+
+// CloneTenant cria o tenant target como cópia do schema de source no mesmo
+// servidor e banco: recria a estrutura a partir do pg_dump, opcionalmente
+// copia os dados e cadastra target no catálogo com as credenciais de source.
+func CloneTenant(ctx context.Context, source, target string, opts CloneOptions) error {
+	catalog, err := GetTenantContext(ctx, source)
+	if err != nil {
+		return err
+	}
+
+	if _, err := queryTenant(ctx, target); err == nil {
+		return fmt.Errorf("%w: %s", ErrTenantExists, target)
+	} else if !errors.Is(err, ErrRecordNotFound) {
+		return err
+	}
+
+	var ddl bytes.Buffer
+	if err := exportSchema(ctx, source, &ddl, opts.PgDumpPath); err != nil {
+		return err
+	}
+
+	// O script do pg_dump altera configurações da sessão (search_path), então
+	// é executado em um pool próprio, descartado ao final.
+	db, err := openTenantDB(catalog, TenantConnectOptions{})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	temporary := "clone_" + strconv.FormatInt(clock.Now().UnixNano(), 36)
+	for _, stmt := range cloneSchemaScript(ddl.String(), source, target, temporary) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("replaying schema of %s: %w", source, err)
+		}
+	}
+
+	if opts.CopyData {
+		sourceConn := Connection{DB: db, SearchPath: source}
+		targetConn := Connection{DB: db, SearchPath: target}
+
+		if err := opts.Anonymize.check(ctx, sourceConn); err != nil {
+			return err
+		}
+
+		tables, err := sourceConn.dependencyOrder(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, table := range tables {
+			columns, err := sourceConn.insertableColumns(ctx, table)
+			if err != nil {
+				return err
+			}
+
+			quoted := make([]string, len(columns))
+			for i, column := range columns {
+				quoted[i] = pq.QuoteIdentifier(column)
+			}
+			selects, err := opts.Anonymize.selectList(ctx, sourceConn, table, columns)
+			if err != nil {
+				return err
+			}
+
+			query := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", targetConn.qualified(table),
+				strings.Join(quoted, ", "), strings.Join(selects, ", "), sourceConn.qualified(table))
+			if _, err := tx.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("copying %s: %w", table, err)
+			}
+			if err := resetSequences(ctx, tx, targetConn, exportTable{Name: table, Columns: columns}); err != nil {
+				return fmt.Errorf("resetting sequences of %s: %w", table, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	clone := *catalog
+	clone.SchemaName = target
+	return SeedTenant(ctx, clone)
+}
+
+// cloneSchemaScript devolve os comandos que recriam a DDL do pg_dump do schema
+// source com o nome target, sem reescrever o texto da DDL: na transação, source
+// é renomeado para temporary, a DDL recria o schema com o nome source, que é
+// renomeado para target, e o schema original volta ao seu nome. Os objetos
+// ficam ligados pelo OID (sequências dos defaults, chaves estrangeiras,
+// views), e as outras sessões só veem o resultado após o commit.
+func cloneSchemaScript(ddl, source, target, temporary string) []string {
+	return []string{
+		"ALTER SCHEMA " + pq.QuoteIdentifier(source) + " RENAME TO " + pq.QuoteIdentifier(temporary),
+		stripMetaCommands(ddl),
+		"ALTER SCHEMA " + pq.QuoteIdentifier(source) + " RENAME TO " + pq.QuoteIdentifier(target),
+		"ALTER SCHEMA " + pq.QuoteIdentifier(temporary) + " RENAME TO " + pq.QuoteIdentifier(source),
+	}
+}
+
+// stripMetaCommands remove os meta-comandos do psql (\connect, \restrict...),
+// que não são SQL
+func stripMetaCommands(ddl string) string {
+	lines := strings.Split(ddl, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !strings.HasPrefix(line, `\`) {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,59 @@
+package connection
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCloneSchemaScript(t *testing.T) {
+	ddl := "\\restrict abc\nCREATE SCHEMA acme;\nCREATE TABLE acme.notes (body text DEFAULT 'acme.x');\n\\unrestrict abc\n"
+
+	tests := []struct {
+		name, source, target string
+		want                 []string
+	}{
+		{
+			"plain names",
+			"acme", "acme_sandbox",
+			[]string{
+				`ALTER SCHEMA "acme" RENAME TO "clone_tmp"`,
+				"CREATE SCHEMA acme;\nCREATE TABLE acme.notes (body text DEFAULT 'acme.x');\n",
+				`ALTER SCHEMA "acme" RENAME TO "acme_sandbox"`,
+				`ALTER SCHEMA "clone_tmp" RENAME TO "acme"`,
+			},
+		},
+		{
+			"quoted names",
+			`Acme "BR"`, "sandbox; DROP",
+			[]string{
+				`ALTER SCHEMA "Acme ""BR""" RENAME TO "clone_tmp"`,
+				"CREATE SCHEMA acme;\nCREATE TABLE acme.notes (body text DEFAULT 'acme.x');\n",
+				`ALTER SCHEMA "Acme ""BR""" RENAME TO "sandbox; DROP"`,
+				`ALTER SCHEMA "clone_tmp" RENAME TO "Acme ""BR"""`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cloneSchemaScript(ddl, tt.source, tt.target, "clone_tmp")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("cloneSchemaScript =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripMetaCommands(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"SELECT 1;", "SELECT 1;"},
+		{"\\connect app\nSELECT 1;\n", "SELECT 1;\n"},
+		{"SELECT '\\x';\n  \\not-at-start", "SELECT '\\x';\n  \\not-at-start"},
+	}
+	for _, tt := range tests {
+		if got := stripMetaCommands(tt.in); got != tt.want {
+			t.Errorf("stripMetaCommands(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
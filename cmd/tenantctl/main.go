@@ -0,0 +1,225 @@
+// Comando tenantctl administra os tenants cadastrados no catálogo.
+//
+// A conexão com o catálogo é lida da variável de ambiente CATALOG_URL.
+//
+//	tenantctl list
+//	tenantctl ping <tenant>
+//	tenantctl create [flags] <tenant>
+//	tenantctl migrate [-dir migrations] [-snapshot-dir dir] (-all | <tenant>...)
+//	tenantctl rotate-password [-password pwd] <tenant>
+//	tenantctl diagnose
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	connection "github.com/MK-Solutions-LTDA/tenant-connection"
+)
+
+const usage = `usage: tenantctl <command> [arguments]
+
+commands:
+  list                     list the tenants in the catalog
+  ping <tenant>            connect to the tenant and report the latency
+  create [flags] <tenant>  add the tenant to the catalog and create its schema
+  migrate [flags] <tenant> apply the pending migrations (-all for every tenant)
+  rotate-password <tenant> change the tenant database password
+  diagnose                 check the catalog, DNS, clock and settings
+
+environment:
+  CATALOG_URL              catalog database URL (required)
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	catalogURL := os.Getenv("CATALOG_URL")
+	if catalogURL == "" {
+		fmt.Fprintln(os.Stderr, "tenantctl: CATALOG_URL is not set")
+		os.Exit(2)
+	}
+	connection.GetCatalogConnection(catalogURL)
+
+	ctx := context.Background()
+	args := os.Args[2:]
+
+	var err error
+	switch os.Args[1] {
+	case "list":
+		err = list(ctx)
+	case "ping":
+		err = ping(ctx, args)
+	case "create":
+		err = create(ctx, args)
+	case "migrate":
+		err = migrate(ctx, args)
+	case "rotate-password":
+		err = rotatePassword(ctx, args)
+	case "diagnose":
+		err = diagnose(ctx)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "tenantctl:", err)
+		os.Exit(1)
+	}
+}
+
+func list(ctx context.Context) error {
+	catalogs, err := connection.ListTenants(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TENANT\tSERVER\tDATABASE\tREGION")
+	for _, catalog := range catalogs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", catalog.SchemaName, catalog.Server, catalog.DatabaseName, catalog.Region)
+	}
+	return w.Flush()
+}
+
+func ping(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("ping", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 5*time.Second, "connection timeout")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: tenantctl ping [-timeout 5s] <tenant>")
+	}
+	tenant := fs.Arg(0)
+
+	start := time.Now()
+	conn, err := connection.GetTenantConnectionWithOptions(ctx, tenant, connection.TenantConnectOptions{
+		SetupTimeout: *timeout,
+		DialTimeout:  *timeout,
+		PingTimeout:  *timeout,
+	})
+	if err != nil {
+		return err
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	if err := conn.DB.PingContext(pingCtx); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: ok (%s)\n", tenant, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func create(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	server := fs.String("server", "", "tenant database server (host[:port])")
+	database := fs.String("database", "", "tenant database name")
+	user := fs.String("user", "", "tenant database user")
+	password := fs.String("password", os.Getenv("TENANT_PASSWORD"), "tenant database password (default $TENANT_PASSWORD)")
+	region := fs.String("region", "", "tenant primary region")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *server == "" || *database == "" || *user == "" {
+		return fmt.Errorf("usage: tenantctl create -server host -database db -user user [-password pwd] [-region r] <tenant>")
+	}
+
+	catalog := connection.Catalog{
+		UserName:     *user,
+		Password:     *password,
+		Server:       *server,
+		DatabaseName: *database,
+		SchemaName:   fs.Arg(0),
+		Region:       *region,
+	}
+	if err := connection.SeedTenant(ctx, catalog); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: created\n", catalog.SchemaName)
+	return nil
+}
+
+func migrate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dir := fs.String("dir", "migrations", "directory with the <version>_<name>.up.sql files")
+	all := fs.Bool("all", false, "migrate every tenant in the catalog")
+	snapshotDir := fs.String("snapshot-dir", "", "back up each tenant to this directory before migrating it")
+	fs.Parse(args)
+	if *all == (fs.NArg() > 0) {
+		return fmt.Errorf("usage: tenantctl migrate [-dir migrations] [-snapshot-dir dir] (-all | <tenant>...)")
+	}
+
+	tenants := fs.Args()
+	if *all {
+		catalogs, err := connection.ListTenants(ctx)
+		if err != nil {
+			return err
+		}
+		tenants = tenants[:0]
+		for _, catalog := range catalogs {
+			if catalog.Maintenance {
+				fmt.Printf("%s: skipped (maintenance)\n", catalog.SchemaName)
+				continue
+			}
+			tenants = append(tenants, catalog.SchemaName)
+		}
+	}
+
+	var opts connection.MigrateOptions
+	if *snapshotDir != "" {
+		opts.Snapshot = connection.DumpSnapshot(*snapshotDir)
+	}
+
+	failed := 0
+	for _, tenant := range tenants {
+		applied, err := connection.MigrateTenant(ctx, tenant, *dir, opts)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", tenant, err)
+		case len(applied) == 0:
+			fmt.Printf("%s: up to date\n", tenant)
+		default:
+			fmt.Printf("%s: migrated to %d (%d applied)\n", tenant, applied[len(applied)-1], len(applied))
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tenants failed", failed, len(tenants))
+	}
+	return nil
+}
+
+func rotatePassword(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("rotate-password", flag.ExitOnError)
+	password := fs.String("password", os.Getenv("TENANT_PASSWORD"), "new tenant database password (default $TENANT_PASSWORD)")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *password == "" {
+		return fmt.Errorf("usage: tenantctl rotate-password [-password pwd] <tenant>")
+	}
+
+	tenant := fs.Arg(0)
+	if err := connection.RotateTenantPassword(ctx, tenant, *password); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: password rotated\n", tenant)
+	return nil
+}
+
+func diagnose(ctx context.Context) error {
+	report := connection.Diagnose(ctx)
+	fmt.Print(report)
+	if !report.OK() {
+		return fmt.Errorf("diagnostics found errors")
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package connection
+
+import (
+	"database/sql"
+)
+
+// Métodos não podem ter parâmetros de tipo, então os helpers abaixo são
+// funções do pacote que recebem o retorno de QueryContext.
+
+// CollectRows lê todas as linhas com scan e fecha rows ao final, inclusive
+// em caso de erro.
+func CollectRows[T any](rows *sql.Rows, scan func(*sql.Rows) (T, error)) ([]T, error) {
+	defer rows.Close()
+
+	var items []T
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// CollectOne lê a primeira linha com scan e fecha rows. Retorna
+// sql.ErrNoRows quando a consulta não retorna linhas.
+func CollectOne[T any](rows *sql.Rows, scan func(*sql.Rows) (T, error)) (T, error) {
+	defer rows.Close()
+
+	var item T
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return item, err
+		}
+		return item, sql.ErrNoRows
+	}
+
+	item, err := scan(rows)
+	if err != nil {
+		return item, err
+	}
+
+	return item, rows.Close()
+}
@@ -0,0 +1,170 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+type CompareOptions struct {
+	// Compara também um checksum do conteúdo de cada tabela, além da
+	// quantidade de linhas. Lê todas as linhas das tabelas.
+	Checksum bool
+}
+
+// TableComparison é o resultado da comparação de uma tabela entre os dois
+// tenants.
+type TableComparison struct {
+	Table     string `json:"table"`
+	RowsA     int64  `json:"rows_a"`
+	RowsB     int64  `json:"rows_b"`
+	ChecksumA string `json:"checksum_a,omitempty"`
+	ChecksumB string `json:"checksum_b,omitempty"`
+	Match     bool   `json:"match"`
+	// Motivo de a tabela não poder ser comparada, como não existir em um dos
+	// tenants ou ter colunas diferentes
+	Error string `json:"error,omitempty"`
+}
+
+type DataComparison struct {
+	TenantA string            `json:"tenant_a"`
+	TenantB string            `json:"tenant_b"`
+	Tables  []TableComparison `json:"tables"`
+}
+
+// Equal indica se todas as tabelas comparadas são iguais.
+func (c *DataComparison) Equal() bool {
+	for _, table := range c.Tables {
+		if !table.Match {
+			return false
+		}
+	}
+	return true
+}
+
+// CompareData compara a quantidade de linhas e, com Checksum, o conteúdo das
+// tabelas de dois tenants, que podem estar em servidores diferentes, para
+// validar um MoveTenant ou CloneTenant. Sem tables, compara todas as tabelas
+// dos dois schemas. O checksum não depende da ordem das linhas nem das
+// colunas, mas os dados não devem ser alterados durante a comparação.
+func CompareData(ctx context.Context, tenantA, tenantB string, tables []string, opts CompareOptions) (*DataComparison, error) {
+	connA, err := GetTenantConnectionWithOptions(ctx, tenantA, TenantConnectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	connB, err := GetTenantConnectionWithOptions(ctx, tenantB, TenantConnectOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	tablesA, err := connA.Tables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tablesB, err := connB.Tables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inA, inB := make(map[string]bool), make(map[string]bool)
+	for _, table := range tablesA {
+		inA[table] = true
+	}
+	for _, table := range tablesB {
+		inB[table] = true
+	}
+
+	if len(tables) == 0 {
+		tables = append(tablesA, tablesB...)
+	}
+	unique := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		unique[table] = true
+	}
+	tables = tables[:0:0]
+	for table := range unique {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	comparison := &DataComparison{TenantA: tenantA, TenantB: tenantB}
+	for _, table := range tables {
+		result := TableComparison{Table: table}
+		switch {
+		case !inA[table]:
+			result.Error = "missing in " + tenantA
+		case !inB[table]:
+			result.Error = "missing in " + tenantB
+		default:
+			if err := compareTable(ctx, connA, connB, &result, opts); err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				result.Error = err.Error()
+			}
+		}
+		comparison.Tables = append(comparison.Tables, result)
+	}
+
+	return comparison, nil
+}
+
+func compareTable(ctx context.Context, connA, connB Connection, result *TableComparison, opts CompareOptions) error {
+	var columns []string
+	if opts.Checksum {
+		columnsA, err := connA.insertableColumns(ctx, result.Table)
+		if err != nil {
+			return err
+		}
+		columnsB, err := connB.insertableColumns(ctx, result.Table)
+		if err != nil {
+			return err
+		}
+		sort.Strings(columnsA)
+		sort.Strings(columnsB)
+		if strings.Join(columnsA, ",") != strings.Join(columnsB, ",") {
+			return errors.New("columns differ")
+		}
+		columns = columnsA
+	}
+
+	var err error
+	if result.RowsA, result.ChecksumA, err = tableChecksum(ctx, connA, result.Table, columns); err != nil {
+		return err
+	}
+	if result.RowsB, result.ChecksumB, err = tableChecksum(ctx, connB, result.Table, columns); err != nil {
+		return err
+	}
+
+	result.Match = result.RowsA == result.RowsB && result.ChecksumA == result.ChecksumB
+	return nil
+}
+
+// tableChecksum conta as linhas da tabela e, com columns, soma o hash de cada
+// linha, de modo que o resultado independe da ordem física das linhas
+func tableChecksum(ctx context.Context, conn Connection, table string, columns []string) (int64, string, error) {
+	if len(columns) == 0 {
+		var rows int64
+		err := conn.QueryRowContext(ctx, "SELECT count(*) FROM "+conn.qualified(table)).Scan(&rows)
+		return rows, "", err
+	}
+
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = pq.QuoteIdentifier(column)
+	}
+	query := fmt.Sprintf(`
+        SELECT count(*), COALESCE(sum(('x' || left(md5(ROW(%s)::text), 16))::bit(64)::bigint::numeric), 0)::text
+        FROM %s`, strings.Join(quoted, ", "), conn.qualified(table))
+
+	var (
+		rows     int64
+		checksum sql.NullString
+	)
+	err := conn.QueryRowContext(ctx, query).Scan(&rows, &checksum)
+	return rows, checksum.String, err
+}
@@ -0,0 +1,317 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// Config reúne a configuração do pacote, normalmente lida do ambiente com
+// LoadConfigFromEnv e aplicada na inicialização com Apply.
+type Config struct {
+	// URL do banco do catálogo (CATALOG_URL), obrigatória
+	CatalogURL string
+	// URL da réplica somente leitura do catálogo (CATALOG_REPLICA_URL),
+	// usada quando o catálogo principal está fora do ar
+	CatalogReplicaURL string
+	// Tamanho padrão dos pools (TENANT_MAX_OPEN_CONNS, TENANT_MAX_IDLE_CONNS)
+	MaxOpenConns int
+	MaxIdleConns int
+	// Tempo máximo de criação do pool (TENANT_SETUP_TIMEOUT)
+	SetupTimeout time.Duration
+	// Tempo do pool no cache (TENANT_CONNECTION_TTL) e das conexões físicas
+	// (TENANT_CONN_MAX_LIFETIME, TENANT_CONN_MAX_IDLE_TIME)
+	ConnectionTTL   time.Duration
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// Exige TLS nas conexões dos tenants (TENANT_REQUIRE_TLS)
+	RequireTLS bool
+	// Nível de log do pacote (TENANT_LOG_LEVEL): info, error ou off
+	LogLevel string
+	// Tempo a partir do qual as queries são escritas no log
+	// (TENANT_SLOW_QUERY_THRESHOLD)
+	SlowQueryThreshold time.Duration
+	// Opções por tenant, com precedência sobre as do catálogo (apenas no
+	// arquivo de LoadConfig)
+	Tenants map[string]TenantDefaults
+}
+
+// ConfigError descreve uma variável de ambiente ou uma chave do arquivo de
+// configuração inválida; Variable traz o nome da variável ou a chave seguida
+// do arquivo, como "log_level (tenant.yaml)". errors.Is reconhece
+// ErrInvalidConfig.
+type ConfigError struct {
+	Variable string
+	Reason   string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%v: %s %s", ErrInvalidConfig, e.Variable, e.Reason)
+}
+
+func (e *ConfigError) Is(target error) bool {
+	return target == ErrInvalidConfig
+}
+
+// LoadConfigFromEnv lê a configuração das variáveis de ambiente. Variáveis
+// ausentes mantêm os padrões atuais do pacote. Todas as variáveis inválidas
+// são reportadas juntas, cada uma como um *ConfigError.
+func LoadConfigFromEnv() (Config, error) {
+	cfg := defaultConfig()
+	return cfg, loadEnv(&cfg, nil)
+}
+
+func defaultConfig() Config {
+	s := currentSettings()
+	return Config{
+		MaxOpenConns:       s.maxOpenConns,
+		MaxIdleConns:       s.maxIdleConns,
+		SetupTimeout:       s.setupTimeout,
+		ConnectionTTL:      s.connectionTTL,
+		ConnMaxLifetime:    s.connMaxLifetime,
+		ConnMaxIdleTime:    s.connMaxIdleTime,
+		RequireTLS:         s.requireTLS,
+		LogLevel:           LogLevelInfo,
+		SlowQueryThreshold: s.slowQueryThreshold,
+	}
+}
+
+// configSources guarda a origem de cada campo de Config definido pelo
+// arquivo ou pelo ambiente, como "max_idle_conns (tenant.yaml)" ou
+// "TENANT_MAX_IDLE_CONNS", para que os erros de validação apontem onde o
+// valor foi definido
+type configSources map[string]string
+
+// name devolve a origem do campo, ou a variável de ambiente correspondente
+// quando o valor é o padrão
+func (s configSources) name(field, env string) string {
+	if source, found := s[field]; found {
+		return source
+	}
+	return env
+}
+
+// loadEnv sobrescreve cfg com as variáveis de ambiente definidas e valida o
+// resultado, já mesclado com o arquivo de configuração (sources)
+func loadEnv(cfg *Config, sources configSources) error {
+	var errs []error
+	invalid := func(name, reason string) {
+		errs = append(errs, &ConfigError{Variable: name, Reason: reason})
+	}
+	if sources == nil {
+		sources = configSources{}
+	}
+	// Campos de Config definidos por cada variável
+	fields := map[string]string{
+		"TENANT_MAX_OPEN_CONNS":       "MaxOpenConns",
+		"TENANT_MAX_IDLE_CONNS":       "MaxIdleConns",
+		"TENANT_LOG_LEVEL":            "LogLevel",
+		"TENANT_SETUP_TIMEOUT":        "SetupTimeout",
+		"TENANT_CONNECTION_TTL":       "ConnectionTTL",
+		"TENANT_CONN_MAX_LIFETIME":    "ConnMaxLifetime",
+		"TENANT_CONN_MAX_IDLE_TIME":   "ConnMaxIdleTime",
+		"TENANT_SLOW_QUERY_THRESHOLD": "SlowQueryThreshold",
+	}
+
+	intVar := func(name string, target *int) {
+		value, found := os.LookupEnv(name)
+		if !found {
+			return
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			invalid(name, fmt.Sprintf("must be a non-negative integer, got %q", value))
+			return
+		}
+		*target = n
+		if field, found := fields[name]; found {
+			sources[field] = name
+		}
+	}
+	durationVar := func(name string, target *time.Duration) {
+		value, found := os.LookupEnv(name)
+		if !found {
+			return
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			invalid(name, fmt.Sprintf("must be a positive duration such as 30s or 1h, got %q", value))
+			return
+		}
+		*target = d
+		sources[fields[name]] = name
+	}
+
+	if value := os.Getenv("CATALOG_URL"); value != "" {
+		cfg.CatalogURL = value
+	}
+	if cfg.CatalogURL == "" {
+		invalid("CATALOG_URL", "is required")
+	}
+	if value := os.Getenv("CATALOG_REPLICA_URL"); value != "" {
+		cfg.CatalogReplicaURL = value
+	}
+	intVar("TENANT_MAX_OPEN_CONNS", &cfg.MaxOpenConns)
+	intVar("TENANT_MAX_IDLE_CONNS", &cfg.MaxIdleConns)
+	durationVar("TENANT_SETUP_TIMEOUT", &cfg.SetupTimeout)
+	durationVar("TENANT_CONNECTION_TTL", &cfg.ConnectionTTL)
+	durationVar("TENANT_CONN_MAX_LIFETIME", &cfg.ConnMaxLifetime)
+	durationVar("TENANT_CONN_MAX_IDLE_TIME", &cfg.ConnMaxIdleTime)
+	durationVar("TENANT_SLOW_QUERY_THRESHOLD", &cfg.SlowQueryThreshold)
+
+	if value, found := os.LookupEnv("TENANT_REQUIRE_TLS"); found {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			invalid("TENANT_REQUIRE_TLS", fmt.Sprintf("must be true or false, got %q", value))
+		} else {
+			cfg.RequireTLS = b
+		}
+	}
+
+	if value, found := os.LookupEnv("TENANT_LOG_LEVEL"); found {
+		cfg.LogLevel = value
+		sources["LogLevel"] = "TENANT_LOG_LEVEL"
+	}
+
+	// Validações do resultado da mescla do arquivo com o ambiente
+	switch cfg.LogLevel {
+	case LogLevelInfo, LogLevelError, LogLevelOff:
+	default:
+		invalid(sources.name("LogLevel", "TENANT_LOG_LEVEL"), fmt.Sprintf("must be info, error or off, got %q", cfg.LogLevel))
+		cfg.LogLevel = LogLevelInfo
+	}
+
+	// Os valores do arquivo não passam pelas validações das variáveis acima
+	for _, field := range []struct {
+		name, env string
+		value     int
+	}{
+		{"MaxOpenConns", "TENANT_MAX_OPEN_CONNS", cfg.MaxOpenConns},
+		{"MaxIdleConns", "TENANT_MAX_IDLE_CONNS", cfg.MaxIdleConns},
+	} {
+		if field.value < 0 {
+			invalid(sources.name(field.name, field.env), fmt.Sprintf("must be a non-negative integer, got %d", field.value))
+		}
+	}
+	for _, field := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"SetupTimeout", cfg.SetupTimeout},
+		{"ConnectionTTL", cfg.ConnectionTTL},
+		{"ConnMaxLifetime", cfg.ConnMaxLifetime},
+		{"ConnMaxIdleTime", cfg.ConnMaxIdleTime},
+		{"SlowQueryThreshold", cfg.SlowQueryThreshold},
+	} {
+		// Os padrões do pacote podem ser zero; só os valores definidos são
+		// validados
+		if source, found := sources[field.name]; found && field.value <= 0 {
+			invalid(source, fmt.Sprintf("must be a positive duration such as 30s or 1h, got %v", field.value))
+		}
+	}
+	tenants := make([]string, 0, len(cfg.Tenants))
+	for tenant := range cfg.Tenants {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	for _, tenant := range tenants {
+		defaults := cfg.Tenants[tenant]
+		if defaults.MaxOpenConns < 0 || defaults.MaxIdleConns < 0 {
+			invalid(sources.name("Tenants."+tenant, "tenants."+tenant), fmt.Sprintf("max_open_conns (%d) and max_idle_conns (%d) must be non-negative",
+				defaults.MaxOpenConns, defaults.MaxIdleConns))
+		}
+	}
+
+	if cfg.MaxOpenConns > 0 && cfg.MaxIdleConns > cfg.MaxOpenConns {
+		invalid(sources.name("MaxIdleConns", "TENANT_MAX_IDLE_CONNS"), fmt.Sprintf("(%d) must not exceed %s (%d)",
+			cfg.MaxIdleConns, sources.name("MaxOpenConns", "TENANT_MAX_OPEN_CONNS"), cfg.MaxOpenConns))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Apply define os padrões do pacote a partir da configuração e abre a conexão
+// com o catálogo. Deve ser chamada uma vez, na inicialização; para alterações
+// em tempo de execução use ReloadConfig.
+func (c Config) Apply() {
+	configMutex.Lock()
+	currentConfig = c
+	configMutex.Unlock()
+
+	c.applySettings()
+
+	if c.CatalogURL != "" {
+		GetCatalogConnection(c.CatalogURL)
+	}
+	if c.CatalogReplicaURL != "" {
+		if err := ConnectCatalogReplica(c.CatalogReplicaURL); err != nil {
+			logError("Catalog replica connection failed: ", scrubSecrets(err.Error()))
+		}
+	}
+}
+
+// settingsMutex protege as variáveis alteradas por applySettings, que são
+// lidas pelas conexões em uso. Alterá-las diretamente só é seguro antes de
+// abrir as conexões; depois, use ReloadConfig.
+var settingsMutex sync.RWMutex
+
+// settings são os padrões do pacote alterados por applySettings
+type settings struct {
+	maxOpenConns       int
+	maxIdleConns       int
+	setupTimeout       time.Duration
+	connectionTTL      time.Duration
+	connMaxLifetime    time.Duration
+	connMaxIdleTime    time.Duration
+	requireTLS         bool
+	slowQueryThreshold time.Duration
+}
+
+// currentSettings lê os padrões do pacote sob settingsMutex
+func currentSettings() settings {
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+
+	return settings{
+		maxOpenConns:       DefaultMaxOpenConns,
+		maxIdleConns:       DefaultMaxIdleConns,
+		setupTimeout:       DefaultSetupTimeout,
+		connectionTTL:      ConnectionTTL,
+		connMaxLifetime:    ConnMaxLifetime,
+		connMaxIdleTime:    ConnMaxIdleTime,
+		requireTLS:         RequireTLS,
+		slowQueryThreshold: DefaultSlowQueryThreshold,
+	}
+}
+
+// applySettings define os padrões que podem ser alterados com as conexões em
+// uso; os pools já abertos mantêm o tamanho e os tempos com que foram criados
+func (c Config) applySettings() {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+
+	DefaultMaxOpenConns = c.MaxOpenConns
+	DefaultMaxIdleConns = c.MaxIdleConns
+	if c.SetupTimeout > 0 {
+		DefaultSetupTimeout = c.SetupTimeout
+	}
+	if c.ConnectionTTL > 0 {
+		ConnectionTTL = c.ConnectionTTL
+	}
+	if c.ConnMaxLifetime > 0 {
+		ConnMaxLifetime = c.ConnMaxLifetime
+	}
+	if c.ConnMaxIdleTime > 0 {
+		ConnMaxIdleTime = c.ConnMaxIdleTime
+	}
+	RequireTLS = c.RequireTLS
+	SetLogLevel(c.LogLevel)
+	DefaultSlowQueryThreshold = c.SlowQueryThreshold
+	defaultManager.SetTenantOverrides(c.Tenants)
+}
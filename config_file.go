@@ -0,0 +1,211 @@
+package connection
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Decodificadores de arquivos de configuração por extensão. O pacote só
+// decodifica JSON; YAML e TOML são registrados pela aplicação, com a
+// biblioteca de sua escolha:
+//
+//	connection.RegisterConfigDecoder(".yaml", yaml.Unmarshal)
+//	connection.RegisterConfigDecoder(".toml", toml.Unmarshal)
+var (
+	configDecodersMutex sync.RWMutex
+	configDecoders      = map[string]func(data []byte, v interface{}) error{
+		".json": json.Unmarshal,
+	}
+)
+
+// RegisterConfigDecoder registra o decodificador usado por LoadConfig para
+// arquivos com a extensão informada (".yaml", ".yml", ".toml", ...).
+func RegisterConfigDecoder(ext string, decode func(data []byte, v interface{}) error) {
+	configDecodersMutex.Lock()
+	defer configDecodersMutex.Unlock()
+
+	configDecoders[strings.ToLower(ext)] = decode
+}
+
+// Duração no formato do time.ParseDuration ("30s", "1h"), aceita pelos
+// decodificadores que usam encoding.TextUnmarshaler
+type configDuration time.Duration
+
+func (d *configDuration) UnmarshalText(text []byte) error {
+	value, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = configDuration(value)
+	return nil
+}
+
+// Seção do arquivo; campos ausentes ficam nil e não sobrescrevem os valores
+// anteriores
+type configSection struct {
+	CatalogURL         *string         `json:"catalog_url" yaml:"catalog_url" toml:"catalog_url"`
+	CatalogReplicaURL  *string         `json:"catalog_replica_url" yaml:"catalog_replica_url" toml:"catalog_replica_url"`
+	MaxOpenConns       *int            `json:"max_open_conns" yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns       *int            `json:"max_idle_conns" yaml:"max_idle_conns" toml:"max_idle_conns"`
+	SetupTimeout       *configDuration `json:"setup_timeout" yaml:"setup_timeout" toml:"setup_timeout"`
+	ConnectionTTL      *configDuration `json:"connection_ttl" yaml:"connection_ttl" toml:"connection_ttl"`
+	ConnMaxLifetime    *configDuration `json:"conn_max_lifetime" yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	ConnMaxIdleTime    *configDuration `json:"conn_max_idle_time" yaml:"conn_max_idle_time" toml:"conn_max_idle_time"`
+	RequireTLS         *bool           `json:"require_tls" yaml:"require_tls" toml:"require_tls"`
+	LogLevel           *string         `json:"log_level" yaml:"log_level" toml:"log_level"`
+	SlowQueryThreshold *configDuration `json:"slow_query_threshold" yaml:"slow_query_threshold" toml:"slow_query_threshold"`
+
+	Tenants map[string]tenantSection `json:"tenants" yaml:"tenants" toml:"tenants"`
+}
+
+type tenantSection struct {
+	MaxOpenConns        int            `json:"max_open_conns" yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns        int            `json:"max_idle_conns" yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ForceUTC            bool           `json:"force_utc" yaml:"force_utc" toml:"force_utc"`
+	StatementTimeout    configDuration `json:"statement_timeout" yaml:"statement_timeout" toml:"statement_timeout"`
+	DefaultQueryTimeout configDuration `json:"default_query_timeout" yaml:"default_query_timeout" toml:"default_query_timeout"`
+}
+
+type configFile struct {
+	configSection `yaml:",inline"`
+	// Sobrescritas por ambiente, escolhido por APP_ENV, GO_ENV ou ENV
+	Environments map[string]configSection `json:"environments" yaml:"environments" toml:"environments"`
+}
+
+// LoadConfig lê a configuração do arquivo, decodificado pela extensão. Só
+// .json é decodificado pelo pacote; arquivos YAML ou TOML exigem
+// RegisterConfigDecoder antes da chamada. Os valores do arquivo são sobrescritos pela seção
+// do ambiente atual (environments.<APP_ENV>) e, por fim, pelas variáveis de
+// ambiente de LoadConfigFromEnv. Um tenant presente em tenants nas duas
+// seções usa as opções da seção do ambiente.
+func LoadConfig(path string) (Config, error) {
+	cfg := defaultConfig()
+
+	ext := strings.ToLower(filepath.Ext(path))
+	configDecodersMutex.RLock()
+	decode, found := configDecoders[ext]
+	configDecodersMutex.RUnlock()
+	if !found {
+		return cfg, fmt.Errorf("%w: no decoder registered for %q files (only .json is built in; use RegisterConfigDecoder for YAML or TOML)", ErrInvalidConfig, ext)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	var file configFile
+	if err := decode(data, &file); err != nil {
+		return cfg, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, path, err)
+	}
+
+	sources := configSources{}
+	file.configSection.apply(&cfg, sources, " ("+filepath.Base(path)+")")
+	environment := currentEnvironment()
+	if env, found := file.Environments[environment]; found {
+		env.apply(&cfg, sources, " (environments."+environment+" in "+filepath.Base(path)+")")
+	}
+
+	return cfg, loadEnv(&cfg, sources)
+}
+
+// apply copia para cfg os valores definidos na seção, registrando em sources
+// a chave e o local de cada um
+func (s configSection) apply(cfg *Config, sources configSources, location string) {
+	if s.MaxOpenConns != nil {
+		sources["MaxOpenConns"] = "max_open_conns" + location
+	}
+	if s.MaxIdleConns != nil {
+		sources["MaxIdleConns"] = "max_idle_conns" + location
+	}
+	if s.LogLevel != nil {
+		sources["LogLevel"] = "log_level" + location
+	}
+	for _, duration := range []struct {
+		field, key string
+		value      *configDuration
+	}{
+		{"SetupTimeout", "setup_timeout", s.SetupTimeout},
+		{"ConnectionTTL", "connection_ttl", s.ConnectionTTL},
+		{"ConnMaxLifetime", "conn_max_lifetime", s.ConnMaxLifetime},
+		{"ConnMaxIdleTime", "conn_max_idle_time", s.ConnMaxIdleTime},
+		{"SlowQueryThreshold", "slow_query_threshold", s.SlowQueryThreshold},
+	} {
+		if duration.value != nil {
+			sources[duration.field] = duration.key + location
+		}
+	}
+	for tenant := range s.Tenants {
+		sources["Tenants."+tenant] = "tenants." + tenant + location
+	}
+
+	if s.CatalogURL != nil {
+		cfg.CatalogURL = *s.CatalogURL
+	}
+	if s.CatalogReplicaURL != nil {
+		cfg.CatalogReplicaURL = *s.CatalogReplicaURL
+	}
+	if s.MaxOpenConns != nil {
+		cfg.MaxOpenConns = *s.MaxOpenConns
+	}
+	if s.MaxIdleConns != nil {
+		cfg.MaxIdleConns = *s.MaxIdleConns
+	}
+	if s.SetupTimeout != nil {
+		cfg.SetupTimeout = time.Duration(*s.SetupTimeout)
+	}
+	if s.ConnectionTTL != nil {
+		cfg.ConnectionTTL = time.Duration(*s.ConnectionTTL)
+	}
+	if s.ConnMaxLifetime != nil {
+		cfg.ConnMaxLifetime = time.Duration(*s.ConnMaxLifetime)
+	}
+	if s.ConnMaxIdleTime != nil {
+		cfg.ConnMaxIdleTime = time.Duration(*s.ConnMaxIdleTime)
+	}
+	if s.RequireTLS != nil {
+		cfg.RequireTLS = *s.RequireTLS
+	}
+	if s.LogLevel != nil {
+		cfg.LogLevel = *s.LogLevel
+	}
+	if s.SlowQueryThreshold != nil {
+		cfg.SlowQueryThreshold = time.Duration(*s.SlowQueryThreshold)
+	}
+
+	for tenant, section := range s.Tenants {
+		if cfg.Tenants == nil {
+			cfg.Tenants = make(map[string]TenantDefaults)
+		}
+		cfg.Tenants[tenant] = TenantDefaults{
+			MaxOpenConns:        section.MaxOpenConns,
+			MaxIdleConns:        section.MaxIdleConns,
+			ForceUTC:            section.ForceUTC,
+			StatementTimeout:    time.Duration(section.StatementTimeout),
+			DefaultQueryTimeout: time.Duration(section.DefaultQueryTimeout),
+		}
+	}
+}
+
+// SetTenantOverrides define as opções de cada tenant deste Manager, com
+// precedência sobre as do catálogo, como a seção tenants do arquivo de
+// configuração faz no Manager padrão. Vale para os pools criados depois.
+func (m *Manager) SetTenantOverrides(overrides map[string]TenantDefaults) {
+	m.overridesMutex.Lock()
+	defer m.overridesMutex.Unlock()
+
+	m.overrides = overrides
+}
+
+// tenantOverrides retorna as opções do tenant definidas na configuração
+func (m *Manager) tenantOverrides(tenant string) TenantDefaults {
+	m.overridesMutex.RLock()
+	defer m.overridesMutex.RUnlock()
+
+	return m.overrides[tenant]
+}
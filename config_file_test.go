@@ -0,0 +1,144 @@
+package connection
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		// Variable dos erros esperados; vazio quando a configuração é válida
+		want []string
+	}{
+		{
+			name: "valid",
+			file: `{"log_level": "error", "max_open_conns": 10, "max_idle_conns": 5}`,
+		},
+		{
+			name: "invalid log level in file",
+			file: `{"log_level": "debug"}`,
+			want: []string{"log_level (tenant.json)"},
+		},
+		{
+			name: "invalid log level in environment section",
+			file: `{"log_level": "info", "environments": {"staging": {"log_level": "verbose"}}}`,
+			env:  map[string]string{"APP_ENV": "staging"},
+			want: []string{"log_level (environments.staging in tenant.json)"},
+		},
+		{
+			name: "env fixes file log level",
+			file: `{"log_level": "debug"}`,
+			env:  map[string]string{"TENANT_LOG_LEVEL": "off"},
+		},
+		{
+			name: "invalid log level in env",
+			file: `{"log_level": "error"}`,
+			env:  map[string]string{"TENANT_LOG_LEVEL": "loud"},
+			want: []string{"TENANT_LOG_LEVEL"},
+		},
+		{
+			name: "idle above open in file",
+			file: `{"max_open_conns": 2, "max_idle_conns": 4}`,
+			want: []string{"max_idle_conns (tenant.json)"},
+		},
+		{
+			name: "idle in env above open in file",
+			file: `{"max_open_conns": 2}`,
+			env:  map[string]string{"TENANT_MAX_IDLE_CONNS": "4"},
+			want: []string{"TENANT_MAX_IDLE_CONNS"},
+		},
+		{
+			name: "negative open conns in file",
+			file: `{"max_open_conns": -1}`,
+			want: []string{"max_open_conns (tenant.json)"},
+		},
+		{
+			name: "negative idle conns in environment section",
+			file: `{"environments": {"staging": {"max_idle_conns": -2}}}`,
+			env:  map[string]string{"APP_ENV": "staging"},
+			want: []string{"max_idle_conns (environments.staging in tenant.json)"},
+		},
+		{
+			name: "env fixes negative file value",
+			file: `{"max_open_conns": -1}`,
+			env:  map[string]string{"TENANT_MAX_OPEN_CONNS": "4"},
+		},
+		{
+			name: "zero duration in file",
+			file: `{"setup_timeout": "0s", "slow_query_threshold": "-1s"}`,
+			want: []string{"setup_timeout (tenant.json)", "slow_query_threshold (tenant.json)"},
+		},
+		{
+			name: "negative tenant conns",
+			file: `{"tenants": {"globex": {"max_idle_conns": -1}, "acme": {"max_open_conns": -3}}}`,
+			want: []string{"tenants.acme (tenant.json)", "tenants.globex (tenant.json)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"APP_ENV", "GO_ENV", "ENV", "TENANT_LOG_LEVEL", "TENANT_MAX_OPEN_CONNS", "TENANT_MAX_IDLE_CONNS",
+				"TENANT_SETUP_TIMEOUT", "TENANT_SLOW_QUERY_THRESHOLD"} {
+				t.Setenv(name, "")
+				os.Unsetenv(name)
+			}
+			t.Setenv("CATALOG_URL", "postgres://catalog")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			path := filepath.Join(t.TempDir(), "tenant.json")
+			if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := LoadConfig(path)
+			var got []string
+			for _, e := range unwrapJoined(err) {
+				var configErr *ConfigError
+				if !errors.As(e, &configErr) {
+					t.Fatalf("unexpected error %v", e)
+				}
+				got = append(got, configErr.Variable)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("errors = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("errors = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigIdleMessageNamesSources(t *testing.T) {
+	t.Setenv("CATALOG_URL", "postgres://catalog")
+	t.Setenv("TENANT_MAX_OPEN_CONNS", "3")
+	path := filepath.Join(t.TempDir(), "tenant.json")
+	if err := os.WriteFile(path, []byte(`{"max_idle_conns": 8}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadConfig(path)
+	want := ErrInvalidConfig.Error() + ": max_idle_conns (tenant.json) (8) must not exceed TENANT_MAX_OPEN_CONNS (3)"
+	if err == nil || err.Error() != want {
+		t.Fatalf("error = %v, want %q", err, want)
+	}
+}
+
+func unwrapJoined(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
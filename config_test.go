@@ -0,0 +1,74 @@
+package connection
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReloadConcurrentWithReaders(t *testing.T) {
+	saved := currentSettings()
+	configMutex.Lock()
+	savedConfig := currentConfig
+	configMutex.Unlock()
+	defer func() {
+		configMutex.Lock()
+		currentConfig = savedConfig
+		configMutex.Unlock()
+	}()
+	defer Config{
+		MaxOpenConns:       saved.maxOpenConns,
+		MaxIdleConns:       saved.maxIdleConns,
+		SetupTimeout:       saved.setupTimeout,
+		ConnectionTTL:      saved.connectionTTL,
+		ConnMaxLifetime:    saved.connMaxLifetime,
+		ConnMaxIdleTime:    saved.connMaxIdleTime,
+		RequireTLS:         saved.requireTLS,
+		LogLevel:           LogLevelInfo,
+		SlowQueryThreshold: saved.slowQueryThreshold,
+	}.applySettings()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				s := currentSettings()
+				if s.maxIdleConns > s.maxOpenConns {
+					t.Errorf("torn read: idle %d > open %d", s.maxIdleConns, s.maxOpenConns)
+					return
+				}
+				defaultManager.tenantOverrides("acme")
+				_ = defaultConfig()
+			}
+		}()
+	}
+
+	for i := 1; i <= 200; i++ {
+		cfg := Config{
+			MaxOpenConns:       i,
+			MaxIdleConns:       i,
+			ConnectionTTL:      time.Duration(i) * time.Second,
+			RequireTLS:         i%2 == 0,
+			LogLevel:           LogLevelOff,
+			SlowQueryThreshold: time.Duration(i) * time.Millisecond,
+			Tenants:            map[string]TenantDefaults{"acme": {MaxOpenConns: i}},
+		}
+		if err := ReloadConfig(func() (Config, error) { return cfg, nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if got := currentSettings(); got.maxOpenConns != 200 || got.connectionTTL != 200*time.Second || !got.requireTLS {
+		t.Fatalf("settings after reload = %+v", got)
+	}
+}
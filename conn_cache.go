@@ -0,0 +1,305 @@
+package connection
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/dgraph-io/ristretto/z"
+)
+
+// ConnCache guarda os pools e os registros do catálogo de um Manager. Por
+// padrão é usado o ristretto; NewLRUCache oferece uma alternativa simples,
+// com limite de itens. Outras implementações podem ser informadas em
+// ManagerOptions.
+type ConnCache interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{}, ttl time.Duration)
+	// Del remove o item sem chamar a função de OnEvict
+	Del(key string)
+	// Range chama fn para cada item até fn retornar false
+	Range(fn func(key string, value interface{}) bool)
+	// OnEvict registra a função chamada quando um item sai do cache por
+	// expiração (expired) ou por falta de espaço
+	OnEvict(fn func(key string, value interface{}, expired bool))
+}
+
+// ristrettoCache adapta o ristretto a ConnCache. O ristretto não permite
+// listar as chaves, então o adaptador mantém o índice do hash para a chave.
+type ristrettoCache struct {
+	cache *ristretto.Cache
+
+	mu      sync.Mutex
+	keys    map[uint64]string
+	onEvict func(key string, value interface{}, expired bool)
+}
+
+func newRistrettoCache() (*ristrettoCache, error) {
+	c := &ristrettoCache{keys: make(map[uint64]string)}
+
+	var err error
+	c.cache, err = ristretto.NewCache(&ristretto.Config{
+		NumCounters: 1e7,     // número de contadores de bits
+		MaxCost:     1 << 30, // tamanho máximo do cache em bytes
+		BufferItems: 64,      // tamanho do buffer interno
+		Metrics:     true,    // habilita as estatísticas expostas em debug.go
+		OnEvict:     c.evicted,
+		OnReject:    c.rejected,
+	})
+	return c, err
+}
+
+func (c *ristrettoCache) Get(key string) (interface{}, bool) {
+	return c.cache.Get(key)
+}
+
+func (c *ristrettoCache) Set(key string, value interface{}, ttl time.Duration) {
+	hash, _ := z.KeyToHash(key)
+
+	c.mu.Lock()
+	c.keys[hash] = key
+	c.mu.Unlock()
+
+	c.cache.SetWithTTL(key, value, 1, ttl)
+}
+
+func (c *ristrettoCache) Del(key string) {
+	hash, _ := z.KeyToHash(key)
+
+	c.mu.Lock()
+	delete(c.keys, hash)
+	c.mu.Unlock()
+
+	c.cache.Del(key)
+}
+
+func (c *ristrettoCache) Range(fn func(key string, value interface{}) bool) {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.keys))
+	for _, key := range c.keys {
+		keys = append(keys, key)
+	}
+	c.mu.Unlock()
+
+	for _, key := range keys {
+		value, found := c.cache.Get(key)
+		if !found {
+			continue
+		}
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+func (c *ristrettoCache) OnEvict(fn func(key string, value interface{}, expired bool)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onEvict = fn
+}
+
+// Wait aguarda a aplicação das escritas pendentes do ristretto
+func (c *ristrettoCache) Wait() {
+	c.cache.Wait()
+}
+
+func (c *ristrettoCache) evicted(item *ristretto.Item) {
+	c.mu.Lock()
+	key := c.keys[item.Key]
+	delete(c.keys, item.Key)
+	fn := c.onEvict
+	c.mu.Unlock()
+
+	if fn != nil {
+		// A expiração é calculada pelo ristretto com o relógio real, e não
+		// com o clock do pacote
+		expired := !item.Expiration.IsZero() && !time.Now().Before(item.Expiration)
+		fn(key, item.Value, expired)
+	}
+}
+
+func (c *ristrettoCache) rejected(item *ristretto.Item) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.keys, item.Key)
+}
+
+// Intervalo mínimo entre as varreduras dos itens expirados do LRUCache
+const lruSweepInterval = time.Minute
+
+// LRUCache é um ConnCache em memória com limite de itens: ao atingir o
+// limite, o item usado há mais tempo é descartado. Os itens expirados são
+// removidos no acesso e em varreduras feitas durante as escritas.
+type LRUCache struct {
+	mu        sync.Mutex
+	size      int
+	items     map[string]*list.Element
+	order     *list.List
+	lastSweep time.Time
+	onEvict   func(key string, value interface{}, expired bool)
+}
+
+type lruEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+type lruEviction struct {
+	entry   *lruEntry
+	expired bool
+}
+
+// NewLRUCache cria um LRUCache com até size itens; size <= 0 não limita a
+// quantidade.
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{
+		size:      size,
+		items:     make(map[string]*list.Element),
+		order:     list.New(),
+		lastSweep: clock.Now(),
+	}
+}
+
+func (c *LRUCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	elem, found := c.items[key]
+	if !found {
+		c.mu.Unlock()
+		return nil, false
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if entry.expired(clock.Now()) {
+		c.remove(elem)
+		c.mu.Unlock()
+		c.evicted([]lruEviction{{entry, true}})
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	c.mu.Unlock()
+	return entry.value, true
+}
+
+func (c *LRUCache) Set(key string, value interface{}, ttl time.Duration) {
+	now := clock.Now()
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+	}
+
+	c.mu.Lock()
+	var evictions []lruEviction
+	if now.Sub(c.lastSweep) >= lruSweepInterval {
+		evictions = c.sweep(now)
+		c.lastSweep = now
+	}
+
+	if elem, found := c.items[key]; found {
+		entry := elem.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+	} else {
+		c.items[key] = c.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	}
+
+	for c.size > 0 && c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.remove(oldest)
+		evictions = append(evictions, lruEviction{oldest.Value.(*lruEntry), false})
+	}
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+}
+
+func (c *LRUCache) Del(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, found := c.items[key]; found {
+		c.remove(elem)
+	}
+}
+
+func (c *LRUCache) Range(fn func(key string, value interface{}) bool) {
+	now := clock.Now()
+
+	c.mu.Lock()
+	entries := make([]lruEntry, 0, len(c.items))
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		if entry := elem.Value.(*lruEntry); !entry.expired(now) {
+			entries = append(entries, *entry)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, entry := range entries {
+		if !fn(entry.key, entry.value) {
+			return
+		}
+	}
+}
+
+func (c *LRUCache) OnEvict(fn func(key string, value interface{}, expired bool)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onEvict = fn
+}
+
+// Len retorna a quantidade de itens no cache, incluindo os expirados ainda
+// não removidos.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *LRUCache) sweep(now time.Time) []lruEviction {
+	var evictions []lruEviction
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if entry := elem.Value.(*lruEntry); entry.expired(now) {
+			c.remove(elem)
+			evictions = append(evictions, lruEviction{entry, true})
+		}
+		elem = next
+	}
+	return evictions
+}
+
+func (c *LRUCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*lruEntry).key)
+}
+
+// evicted chama a função de OnEvict fora do lock, já que ela pode acessar o
+// cache novamente
+func (c *LRUCache) evicted(evictions []lruEviction) {
+	if len(evictions) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	fn := c.onEvict
+	c.mu.Unlock()
+
+	if fn == nil {
+		return
+	}
+	for _, eviction := range evictions {
+		fn(eviction.entry.key, eviction.entry.value, eviction.expired)
+	}
+}
+
+func (e *lruEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
@@ -0,0 +1,24 @@
+// Package connectionbun integra o Bun aos pools de tenant-connection. Fica em
+// um módulo próprio para que o pacote principal não dependa do Bun.
+package connectionbun
+
+import (
+	"context"
+	"database/sql"
+
+	connection "github.com/MK-Solutions-LTDA/tenant-connection"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+var factory = connection.NewClientFactory(func(db *sql.DB) *bun.DB {
+	return bun.NewDB(db, pgdialect.New())
+})
+
+// GetBunDB retorna o *bun.DB do tenant, com o pgdialect, sobre o pool em cache
+// do tenant. Cada conexão do pool abre com o search_path do tenant, então as
+// tabelas sem schema são as do tenant em qualquer conexão. O *bun.DB não deve
+// ser fechado pelo chamador.
+func GetBunDB(ctx context.Context, tenant string) (*bun.DB, error) {
+	return factory.Client(ctx, tenant)
+}
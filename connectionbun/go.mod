@@ -0,0 +1,15 @@
+module github.com/MK-Solutions-LTDA/tenant-connection/connectionbun
+
+go 1.20
+
+require (
+	github.com/MK-Solutions-LTDA/tenant-connection v0.0.0
+	github.com/uptrace/bun v1.1.14
+	github.com/uptrace/bun/dialect/pgdialect v1.1.14
+)
+
+// Apenas para desenvolvimento neste repositório: o connectionbun usa
+// NewClientFactory, que ainda não está em uma versão publicada do módulo
+// principal. Ao publicar, o require acima passa para a tag (ex.: v1.x.0) e este
+// replace é removido, junto com a tag connectionbun/v1.x.0.
+replace github.com/MK-Solutions-LTDA/tenant-connection => ../
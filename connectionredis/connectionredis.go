@@ -0,0 +1,100 @@
+// Package connectionredis implementa o SharedCatalog de tenant-connection
+// sobre o go-redis. Fica em um módulo próprio para que o pacote principal não
+// dependa do Redis.
+//
+//	rdb := redis.NewClient(&redis.Options{Addr: "redis:6379"})
+//	connection.UseSharedCatalog(ctx, connectionredis.New(rdb), 10*time.Minute)
+package connectionredis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	connection "github.com/MK-Solutions-LTDA/tenant-connection"
+	"github.com/redis/go-redis/v9"
+)
+
+// Prefixo padrão das chaves e do canal de invalidações
+const DefaultPrefix = "tenant-connection:"
+
+// Catalog é o SharedCatalog sobre um client do go-redis. Os registros ficam
+// em "<prefixo>catalog:<tenant>", em JSON, e as invalidações são publicadas no
+// canal "<prefixo>invalidations".
+type Catalog struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+var _ connection.SharedCatalog = (*Catalog)(nil)
+
+// New cria o SharedCatalog com o prefixo padrão. Aceita *redis.Client,
+// *redis.ClusterClient ou *redis.Ring.
+func New(client redis.UniversalClient) *Catalog {
+	return NewWithPrefix(client, DefaultPrefix)
+}
+
+// NewWithPrefix cria o SharedCatalog com as chaves sob o prefixo informado,
+// para serviços diferentes no mesmo Redis.
+func NewWithPrefix(client redis.UniversalClient, prefix string) *Catalog {
+	return &Catalog{client: client, prefix: prefix}
+}
+
+func (c *Catalog) key(tenant string) string {
+	return c.prefix + "catalog:" + tenant
+}
+
+func (c *Catalog) channel() string {
+	return c.prefix + "invalidations"
+}
+
+func (c *Catalog) GetCatalog(ctx context.Context, tenant string) (*connection.Catalog, error) {
+	data, err := c.client.Get(ctx, c.key(tenant)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, connection.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var catalog connection.Catalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, err
+	}
+	return &catalog, nil
+}
+
+func (c *Catalog) SetCatalog(ctx context.Context, catalog *connection.Catalog, ttl time.Duration) error {
+	data, err := json.Marshal(catalog)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, c.key(catalog.SchemaName), data, ttl).Err()
+}
+
+func (c *Catalog) DeleteCatalog(ctx context.Context, tenant string) error {
+	return c.client.Del(ctx, c.key(tenant)).Err()
+}
+
+func (c *Catalog) PublishInvalidation(ctx context.Context, tenant string) error {
+	return c.client.Publish(ctx, c.channel(), tenant).Err()
+}
+
+// SubscribeInvalidations retorna quando o contexto é cancelado ou a
+// inscrição falha; UseSharedCatalog se inscreve novamente após uma falha.
+func (c *Catalog) SubscribeInvalidations(ctx context.Context, fn func(tenant string)) error {
+	pubsub := c.client.Subscribe(ctx, c.channel())
+	defer pubsub.Close()
+
+	for {
+		message, err := pubsub.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		fn(message.Payload)
+	}
+}
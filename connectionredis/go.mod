@@ -0,0 +1,13 @@
+module github.com/MK-Solutions-LTDA/tenant-connection/connectionredis
+
+go 1.20
+
+require (
+	github.com/MK-Solutions-LTDA/tenant-connection v0.0.0
+	github.com/redis/go-redis/v9 v9.0.5
+)
+
+// Apenas para desenvolvimento neste repositório, como em connectionbun: ao
+// publicar, o require acima passa para a tag do módulo principal e este
+// replace é removido, junto com a tag connectionredis/v1.x.0.
+replace github.com/MK-Solutions-LTDA/tenant-connection => ../
@@ -0,0 +1,128 @@
+package connectiontest
+
+import (
+	"sync"
+	"time"
+
+	connection "github.com/MK-Solutions-LTDA/tenant-connection"
+)
+
+// FakeClock é um connection.Clock que só avança quando Advance é chamado.
+// Tickers e timers vencidos disparam de forma síncrona, em ordem, dentro de
+// Advance.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	clock   *FakeClock
+	at      time.Time
+	period  time.Duration
+	fn      func()
+	ch      chan time.Time
+	stopped bool
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) connection.Ticker {
+	if d <= 0 {
+		panic("connectiontest: non-positive interval for NewTicker")
+	}
+	return fakeTicker{c.add(&fakeWaiter{period: d, ch: make(chan time.Time, 1)}, d)}
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) connection.Timer {
+	return fakeTimer{c.add(&fakeWaiter{fn: f}, d)}
+}
+
+func (c *FakeClock) add(w *fakeWaiter, d time.Duration) *fakeWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w.clock = c
+	w.at = c.now.Add(d)
+	c.waiters = append(c.waiters, w)
+	return w
+}
+
+// Advance avança o relógio, disparando os tickers e timers que vencerem.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		w := c.next(target)
+		if w == nil {
+			c.now = target
+			c.mu.Unlock()
+			return
+		}
+
+		c.now = w.at
+		now := c.now
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			w.stopped = true
+		}
+		c.mu.Unlock()
+
+		if w.ch != nil {
+			// Assim como no time.Ticker, ticks não consumidos são descartados
+			select {
+			case w.ch <- now:
+			default:
+			}
+		} else {
+			w.fn()
+		}
+	}
+}
+
+func (c *FakeClock) next(target time.Time) *fakeWaiter {
+	var next *fakeWaiter
+	for _, w := range c.waiters {
+		if w.stopped || w.at.After(target) {
+			continue
+		}
+		if next == nil || w.at.Before(next.at) {
+			next = w
+		}
+	}
+	return next
+}
+
+type fakeTicker struct{ w *fakeWaiter }
+
+func (t fakeTicker) C() <-chan time.Time { return t.w.ch }
+
+func (t fakeTicker) Stop() { t.w.stop() }
+
+type fakeTimer struct{ w *fakeWaiter }
+
+func (t fakeTimer) Stop() bool { return t.w.stop() }
+
+func (w *fakeWaiter) stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+
+	active := !w.stopped
+	w.stopped = true
+	return active
+}
+
+var _ connection.Clock = (*FakeClock)(nil)
@@ -0,0 +1,77 @@
+// Package connectiontest fornece implementações em memória das interfaces do
+// pacote connection para testes unitários sem um Postgres real.
+package connectiontest
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	connection "github.com/MK-Solutions-LTDA/tenant-connection"
+)
+
+// Catalog é um CatalogProvider com um conjunto fixo de tenants.
+type Catalog struct {
+	mu      sync.RWMutex
+	tenants map[string]connection.Catalog
+}
+
+func NewCatalog(catalogs ...connection.Catalog) *Catalog {
+	c := &Catalog{tenants: make(map[string]connection.Catalog)}
+	for _, catalog := range catalogs {
+		c.Add(catalog)
+	}
+	return c
+}
+
+func (c *Catalog) Add(catalog connection.Catalog) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tenants[catalog.SchemaName] = catalog
+}
+
+func (c *Catalog) GetTenant(_ context.Context, tenant string) (*connection.Catalog, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	catalog, found := c.tenants[tenant]
+	if !found {
+		return nil, connection.ErrRecordNotFound
+	}
+	return &catalog, nil
+}
+
+// Connector é um TenantConnector que devolve conexões previamente
+// registradas, normalmente criadas com sqlmock.New().
+type Connector struct {
+	mu  sync.RWMutex
+	dbs map[string]*sql.DB
+}
+
+func NewConnector() *Connector {
+	return &Connector{dbs: make(map[string]*sql.DB)}
+}
+
+func (c *Connector) Add(tenant string, db *sql.DB) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dbs[tenant] = db
+}
+
+func (c *Connector) GetTenantConnection(_ context.Context, tenant string, _ connection.TenantConnectOptions) (connection.Connection, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	db, found := c.dbs[tenant]
+	if !found {
+		return connection.Connection{}, connection.ErrRecordNotFound
+	}
+	return connection.Connection{DB: db, SearchPath: tenant}, nil
+}
+
+var (
+	_ connection.CatalogProvider = (*Catalog)(nil)
+	_ connection.TenantConnector = (*Connector)(nil)
+)
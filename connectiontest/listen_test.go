@@ -0,0 +1,52 @@
+package connectiontest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	connection "github.com/MK-Solutions-LTDA/tenant-connection"
+)
+
+func TestListenTenantsShareDatabase(t *testing.T) {
+	StartPostgres(t, "acme", "globex")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	acme, err := connection.Listen(ctx, "acme", "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	globex, err := connection.Listen(ctx, "globex", "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := connection.Notify(ctx, "globex", "orders", "from globex"); err != nil {
+		t.Fatal(err)
+	}
+	if err := connection.Notify(ctx, "acme", "orders", "from acme"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case n := <-globex:
+		if n.Tenant != "globex" || n.Channel != "orders" || n.Payload != "from globex" {
+			t.Fatalf("globex received %+v", n)
+		}
+	case <-ctx.Done():
+		t.Fatal("globex notification not delivered")
+	}
+
+	// O NOTIFY de globex foi feito antes, então a primeira notificação de acme
+	// seria a de globex se os canais fossem compartilhados
+	select {
+	case n := <-acme:
+		if n.Tenant != "acme" || n.Payload != "from acme" {
+			t.Fatalf("acme received %+v", n)
+		}
+	case <-ctx.Done():
+		t.Fatal("acme notification not delivered")
+	}
+}
@@ -0,0 +1,137 @@
+package connectiontest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	connection "github.com/MK-Solutions-LTDA/tenant-connection"
+)
+
+const defaultImage = "postgres:15-alpine"
+
+// Postgres é um container do Postgres com o catálogo e os schemas dos tenants
+// já criados, no mesmo banco.
+type Postgres struct {
+	CatalogURL string
+	Server     string
+	Tenants    []string
+
+	containerID string
+}
+
+// StartPostgres sobe um container do Postgres via docker, cria a tabela
+// catalog e um schema com registro no catálogo para cada tenant, e conecta o
+// pacote connection a esse catálogo. O container é removido ao final do teste.
+// A imagem pode ser trocada com CONNECTIONTEST_POSTGRES_IMAGE.
+//
+// Como o catálogo do pacote é global, testes que usam StartPostgres não devem
+// rodar em paralelo.
+func StartPostgres(t testing.TB, tenants ...string) *Postgres {
+	t.Helper()
+
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("connectiontest: docker not available")
+	}
+
+	image := os.Getenv("CONNECTIONTEST_POSTGRES_IMAGE")
+	if image == "" {
+		image = defaultImage
+	}
+
+	id, err := docker("run", "-d", "--rm",
+		"-e", "POSTGRES_USER=postgres",
+		"-e", "POSTGRES_PASSWORD=postgres",
+		"-e", "POSTGRES_DB=catalog",
+		"-p", "127.0.0.1::5432",
+		image)
+	if err != nil {
+		t.Fatalf("connectiontest: starting postgres: %v", err)
+	}
+
+	pg := &Postgres{containerID: id, Tenants: tenants}
+	t.Cleanup(func() { pg.stop(t) })
+
+	address, err := docker("port", id, "5432/tcp")
+	if err != nil {
+		t.Fatalf("connectiontest: resolving postgres port: %v", err)
+	}
+	// Pode listar IPv4 e IPv6, um por linha
+	pg.Server = strings.Split(address, "\n")[0]
+	pg.CatalogURL = fmt.Sprintf("postgres://postgres:postgres@%s/catalog", pg.Server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	db, err := openReady(ctx, pg.CatalogURL+"?sslmode=disable")
+	if err != nil {
+		t.Fatalf("connectiontest: waiting for postgres: %v", err)
+	}
+	db.Close()
+
+	connection.Connect(pg.CatalogURL)
+	if err := connection.EnsureCatalogSchema(ctx); err != nil {
+		t.Fatalf("connectiontest: creating catalog: %v", err)
+	}
+
+	catalogs := make([]connection.Catalog, 0, len(tenants))
+	for _, tenant := range tenants {
+		catalogs = append(catalogs, connection.Catalog{
+			Driver:       "postgres",
+			UserName:     "postgres",
+			Password:     "postgres",
+			Server:       pg.Server,
+			DatabaseName: "catalog",
+			SchemaName:   tenant,
+		})
+	}
+	if err := connection.SeedTenants(ctx, catalogs); err != nil {
+		t.Fatalf("connectiontest: seeding tenants: %v", err)
+	}
+
+	return pg
+}
+
+func (pg *Postgres) stop(t testing.TB) {
+	// Descarta os pools em cache que apontam para este container
+	for _, tenant := range pg.Tenants {
+		connection.InvalidateTenant(context.Background(), tenant)
+	}
+
+	if _, err := docker("rm", "-f", pg.containerID); err != nil {
+		t.Logf("connectiontest: removing postgres container: %v", err)
+	}
+}
+
+func openReady(ctx context.Context, url string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if err = db.PingContext(ctx); err == nil {
+			return db, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			db.Close()
+			return nil, fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+func docker(args ...string) (string, error) {
+	out, err := exec.Command("docker", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("docker %s: %w: %s", args[0], err, out)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,58 @@
+package connection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrMissingTenant = errors.New("message without tenant")
+
+// MessageHandler processa uma mensagem de fila (Kafka, SQS, RabbitMQ, ...).
+type MessageHandler[M any] func(ctx context.Context, msg M) error
+
+// Consumer envolve handler para workers assíncronos: extrai o tenant da
+// mensagem com tenantOf, obtém a conexão do tenant e chama handler com um
+// contexto que carrega a conexão (veja ConnectionFromContext). Mensagens sem
+// tenant retornam ErrMissingTenant sem chamar handler.
+func Consumer[M any](tenantOf func(msg M) (string, error), opts TenantConnectOptions, handler MessageHandler[M]) MessageHandler[M] {
+	return func(ctx context.Context, msg M) error {
+		tenant, err := tenantOf(msg)
+		if err != nil {
+			return err
+		}
+		if tenant == "" {
+			return ErrMissingTenant
+		}
+
+		conn, err := GetTenantConnectionWithOptions(ctx, tenant, opts)
+		if err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant, err)
+		}
+
+		return handler(ContextWithConnection(ctx, conn), msg)
+	}
+}
+
+// TenantFromJSONField extrai o tenant de um campo do envelope JSON da
+// mensagem, como {"tenant": "acme", "payload": {...}}.
+func TenantFromJSONField(field string) func(body []byte) (string, error) {
+	return func(body []byte) (string, error) {
+		var envelope map[string]json.RawMessage
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			return "", fmt.Errorf("invalid message envelope: %w", err)
+		}
+
+		raw, found := envelope[field]
+		if !found {
+			return "", ErrMissingTenant
+		}
+
+		var tenant string
+		if err := json.Unmarshal(raw, &tenant); err != nil {
+			return "", fmt.Errorf("invalid tenant field %q: %w", field, err)
+		}
+		return tenant, nil
+	}
+}
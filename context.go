@@ -0,0 +1,37 @@
+package connection
+
+import (
+	"context"
+)
+
+type connectionContextKey struct{}
+
+// ContextWithConnection retorna um contexto que carrega a conexão do tenant,
+// recuperável com ConnectionFromContext e TenantFromContext.
+func ContextWithConnection(ctx context.Context, conn Connection) context.Context {
+	return context.WithValue(ctx, connectionContextKey{}, conn)
+}
+
+// ConnectionFromContext retorna a conexão de ContextWithConnection ou, em um
+// contexto de WithRequestConnection, a conexão do request, obtida na primeira
+// chamada. Falhas ao obter a conexão retornam false; use RequestConnection
+// para receber o erro.
+func ConnectionFromContext(ctx context.Context) (Connection, bool) {
+	if conn, ok := ctx.Value(connectionContextKey{}).(Connection); ok {
+		return conn, true
+	}
+
+	conn, err := RequestConnection(ctx)
+	return conn, err == nil
+}
+
+// TenantFromContext retorna o tenant do contexto sem obter a conexão.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	if conn, ok := ctx.Value(connectionContextKey{}).(Connection); ok {
+		return conn.SearchPath, true
+	}
+	if holder, ok := ctx.Value(requestConnectionKey{}).(*requestConnection); ok {
+		return holder.tenant, true
+	}
+	return "", false
+}
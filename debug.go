@@ -0,0 +1,138 @@
+package connection
+
+import (
+	"database/sql"
+	"encoding/json"
+	"expvar"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type CacheStats struct {
+	Hits        uint64  `json:"hits"`
+	Misses      uint64  `json:"misses"`
+	Ratio       float64 `json:"ratio"`
+	KeysAdded   uint64  `json:"keys_added"`
+	KeysEvicted uint64  `json:"keys_evicted"`
+	SetsDropped uint64  `json:"sets_dropped"`
+}
+
+type DebugInfo struct {
+	OpenTenants []string               `json:"open_tenants"`
+	Cache       CacheStats             `json:"cache"`
+	Pools       map[string]sql.DBStats `json:"pools"`
+	LastUsedAt  map[string]time.Time   `json:"last_used_at"`
+	Creation    PoolCreationStats      `json:"creation"`
+	Catalog     CatalogStats           `json:"catalog"`
+	Replicas    []ReplicaStats         `json:"replicas,omitempty"`
+	// Apenas tenants com StatementCacheSize habilitado
+	Statements map[string]StatementCacheStats `json:"statements,omitempty"`
+}
+
+func init() {
+	vars := expvar.NewMap("tenant_connection")
+	vars.Set("open_tenants", expvar.Func(func() interface{} { return Snapshot().OpenTenants }))
+	vars.Set("cache", expvar.Func(func() interface{} { return Snapshot().Cache }))
+	vars.Set("pools", expvar.Func(func() interface{} { return Snapshot().Pools }))
+	vars.Set("creation", expvar.Func(func() interface{} { return Snapshot().Creation }))
+	vars.Set("catalog", expvar.Func(func() interface{} { return defaultManager.CatalogStats() }))
+	vars.Set("replicas", expvar.Func(func() interface{} { return ReplicaWeights() }))
+	vars.Set("replica_lag", expvar.Func(func() interface{} { return ReplicaLags() }))
+	vars.Set("storage_bytes", expvar.Func(func() interface{} { return tenantStorageBytes() }))
+	vars.Set("panics", expvar.Func(func() interface{} { return PanicCounts() }))
+	vars.Set("last_used_at", expvar.Func(func() interface{} { return Snapshot().LastUsedAt }))
+	vars.Set("statements", expvar.Func(func() interface{} { return Snapshot().Statements }))
+}
+
+// Snapshot retorna o estado atual das conexões em cache e das estatísticas do cache.
+func Snapshot() DebugInfo {
+	conns := defaultManager.openConnections()
+
+	info := DebugInfo{
+		OpenTenants: defaultManager.OpenTenants(),
+		Pools:       make(map[string]sql.DBStats, len(conns)),
+		LastUsedAt:  make(map[string]time.Time, len(conns)),
+		Creation:    defaultManager.creationLimiter.stats(),
+		Catalog:     defaultManager.CatalogStats(),
+		Replicas:    ReplicaWeights(),
+	}
+	for _, conn := range conns {
+		info.Pools[conn.poolKey()] = conn.DB.Stats()
+		info.LastUsedAt[conn.poolKey()] = conn.LastUsedAt()
+		if conn.stmts != nil {
+			if info.Statements == nil {
+				info.Statements = make(map[string]StatementCacheStats)
+			}
+			info.Statements[conn.poolKey()] = conn.stmts.stats()
+		}
+	}
+
+	if metrics := Connections.Metrics; metrics != nil {
+		info.Cache = CacheStats{
+			Hits:        metrics.Hits(),
+			Misses:      metrics.Misses(),
+			Ratio:       metrics.Ratio(),
+			KeysAdded:   metrics.KeysAdded(),
+			KeysEvicted: metrics.KeysEvicted(),
+			SetsDropped: metrics.SetsDropped(),
+		}
+	}
+
+	return info
+}
+
+// DebugHandler renderiza o Snapshot em JSON, ou em HTML quando solicitado
+// pelo navegador (Accept: text/html) ou via ?format=html.
+func DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := Snapshot()
+
+		if r.URL.Query().Get("format") == "html" || strings.Contains(r.Header.Get("Accept"), "text/html") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if err := debugTemplate.Execute(w, info); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(info); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+var debugTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head><title>tenant-connection</title></head>
+<body>
+<h1>tenant-connection</h1>
+<h2>Cache</h2>
+<table border="1">
+<tr><th>Hits</th><th>Misses</th><th>Ratio</th><th>Keys added</th><th>Keys evicted</th><th>Sets dropped</th></tr>
+<tr><td>{{.Cache.Hits}}</td><td>{{.Cache.Misses}}</td><td>{{printf "%.2f" .Cache.Ratio}}</td><td>{{.Cache.KeysAdded}}</td><td>{{.Cache.KeysEvicted}}</td><td>{{.Cache.SetsDropped}}</td></tr>
+</table>
+<h2>Pool creation</h2>
+<p>Queued: {{.Creation.Queued}} / Delayed: {{.Creation.Delayed}}</p>
+<h2>Catalog</h2>
+<p>Lookups: {{.Catalog.Lookups}} / Not found: {{.Catalog.NotFound}} / Timeouts: {{.Catalog.Timeouts}} / Errors: {{.Catalog.Errors}} / Total duration: {{printf "%.3f" .Catalog.DurationSeconds}}s</p>
+{{if .Replicas}}<h2>Replicas</h2>
+<table border="1">
+<tr><th>Address</th><th>Weight</th><th>Latency</th><th>Error rate</th><th>Dials</th><th>Errors</th></tr>
+{{range .Replicas}}<tr><td>{{.Address}}</td><td>{{printf "%.2f" .Weight}}</td><td>{{printf "%.3f" .LatencySeconds}}s</td><td>{{printf "%.2f" .ErrorRate}}</td><td>{{.Dials}}</td><td>{{.Errors}}</td></tr>
+{{end}}</table>{{end}}
+<h2>Pools ({{len .Pools}})</h2>
+<table border="1">
+<tr><th>Tenant</th><th>Open</th><th>In use</th><th>Idle</th><th>Max open</th><th>Wait count</th><th>Wait duration</th><th>Last used</th></tr>
+{{range $tenant, $stats := .Pools}}<tr><td>{{$tenant}}</td><td>{{$stats.OpenConnections}}</td><td>{{$stats.InUse}}</td><td>{{$stats.Idle}}</td><td>{{$stats.MaxOpenConnections}}</td><td>{{$stats.WaitCount}}</td><td>{{$stats.WaitDuration}}</td><td>{{index $.LastUsedAt $tenant}}</td></tr>
+{{end}}</table>
+{{if .Statements}}<h2>Prepared statements</h2>
+<table border="1">
+<tr><th>Tenant</th><th>Size</th><th>Hits</th><th>Misses</th><th>Ratio</th><th>Evictions</th></tr>
+{{range $tenant, $stats := .Statements}}<tr><td>{{$tenant}}</td><td>{{$stats.Size}}</td><td>{{$stats.Hits}}</td><td>{{$stats.Misses}}</td><td>{{printf "%.2f" $stats.Ratio}}</td><td>{{$stats.Evictions}}</td></tr>
+{{end}}</table>{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,137 @@
+package connection
+
+import (
+	"context"
+	"time"
+)
+
+// SetDefaultTenantOptions define as opções usadas como base em todas as
+// chamadas a GetTenantConnection e GetTenantConnectionWithOptions. Os campos
+// informados pelo chamador têm precedência; os demais vêm destes padrões e,
+// depois, das opções do tenant no catálogo. Deve ser chamada na
+// inicialização: as opções aplicadas na criação do pool, como MaxOpenConns e
+// ForceUTC, não alteram os pools já abertos.
+func SetDefaultTenantOptions(opts TenantConnectOptions) {
+	defaultManager.SetDefaultTenantOptions(opts)
+}
+
+// SetDefaultTenantOptions define as opções padrão das conexões do Manager.
+func (m *Manager) SetDefaultTenantOptions(opts TenantConnectOptions) {
+	m.defaultsMutex.Lock()
+	defer m.defaultsMutex.Unlock()
+
+	m.defaults = opts
+}
+
+func (m *Manager) defaultOptions() TenantConnectOptions {
+	m.defaultsMutex.RLock()
+	defer m.defaultsMutex.RUnlock()
+
+	return m.defaults
+}
+
+// apply preenche os campos não informados em opts com os de d
+func (d TenantConnectOptions) apply(opts TenantConnectOptions) TenantConnectOptions {
+	if opts.SetupTimeout == 0 {
+		opts.SetupTimeout = d.SetupTimeout
+	}
+	if opts.IgnoreCallerDeadline == ToggleDefault {
+		opts.IgnoreCallerDeadline = d.IgnoreCallerDeadline
+	}
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = d.DialTimeout
+	}
+	if opts.PingTimeout == 0 {
+		opts.PingTimeout = d.PingTimeout
+	}
+	if opts.DefaultQueryTimeout == 0 {
+		opts.DefaultQueryTimeout = d.DefaultQueryTimeout
+	}
+	if opts.PreferredRegion == "" {
+		opts.PreferredRegion = d.PreferredRegion
+	}
+	if opts.ForceUTC == ToggleDefault {
+		opts.ForceUTC = d.ForceUTC
+	}
+	if opts.SessionSettings == nil {
+		opts.SessionSettings = d.SessionSettings
+	}
+	if opts.StatementTimeout == 0 {
+		opts.StatementTimeout = d.StatementTimeout
+	}
+	if opts.MaxOpenConns == 0 {
+		opts.MaxOpenConns = d.MaxOpenConns
+	}
+	if opts.MaxIdleConns == 0 {
+		opts.MaxIdleConns = d.MaxIdleConns
+	}
+	if opts.BinaryParameters == ToggleDefault {
+		opts.BinaryParameters = d.BinaryParameters
+	}
+	if opts.KeepAlive == 0 {
+		opts.KeepAlive = d.KeepAlive
+	}
+	if opts.TargetSessionAttrs == "" {
+		opts.TargetSessionAttrs = d.TargetSessionAttrs
+	}
+	if opts.ExtraParams == nil {
+		opts.ExtraParams = d.ExtraParams
+	}
+	if opts.DialFunc == nil {
+		opts.DialFunc = d.DialFunc
+	}
+	if opts.SlowQueryThreshold == 0 {
+		opts.SlowQueryThreshold = d.SlowQueryThreshold
+	}
+	if opts.Detached == ToggleDefault {
+		opts.Detached = d.Detached
+	}
+	if opts.GuardStatements == ToggleDefault {
+		opts.GuardStatements = d.GuardStatements
+	}
+	if opts.InjectionCheck == InjectionIgnore {
+		opts.InjectionCheck = d.InjectionCheck
+	}
+	if opts.AllowedQueries == nil {
+		opts.AllowedQueries = d.AllowedQueries
+	}
+	if opts.DetectFailover == ToggleDefault {
+		opts.DetectFailover = d.DetectFailover
+	}
+	if opts.ConnMaxLifetime == 0 {
+		opts.ConnMaxLifetime = d.ConnMaxLifetime
+	}
+	if opts.DialErrorThreshold == 0 {
+		opts.DialErrorThreshold = d.DialErrorThreshold
+	}
+	if opts.Cache == CacheDefault {
+		opts.Cache = d.Cache
+	}
+	if opts.CacheTTL == 0 {
+		opts.CacheTTL = d.CacheTTL
+	}
+	if opts.RetryReads.MaxAttempts == 0 {
+		opts.RetryReads = d.RetryReads
+	}
+	// DSNOverride é específico de um tenant e não tem padrão
+	return opts
+}
+
+// Tempo a partir do qual as queries são escritas no log quando a conexão não
+// define SlowQueryThreshold; zero desabilita
+var DefaultSlowQueryThreshold time.Duration
+
+// logSlowQuery registra a query iniciada em start quando ela ultrapassa o
+// SlowQueryThreshold
+func (c Connection) logSlowQuery(ctx context.Context, start time.Time, query string) {
+	threshold := c.slowQueryThreshold
+	if threshold == 0 {
+		threshold = currentSettings().slowQueryThreshold
+	}
+	if threshold <= 0 {
+		return
+	}
+	if elapsed := clock.Now().Sub(start); elapsed >= threshold {
+		logInfo("Slow query for tenant ", c.SearchPath, " (", elapsed, "): ", query, requestLogFields(ctx))
+	}
+}
@@ -0,0 +1,41 @@
+package connection
+
+import "testing"
+
+func TestDefaultOptionsToggle(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults Toggle
+		caller   Toggle
+		want     bool
+	}{
+		{"no default", ToggleDefault, ToggleDefault, false},
+		{"default on", ToggleOn, ToggleDefault, true},
+		{"caller on", ToggleDefault, ToggleOn, true},
+		{"caller turns default off", ToggleOn, ToggleOff, false},
+		{"caller on over default off", ToggleOff, ToggleOn, true},
+	}
+	for _, tt := range tests {
+		defaults := TenantConnectOptions{
+			ForceUTC: tt.defaults, Detached: tt.defaults, GuardStatements: tt.defaults,
+			DetectFailover: tt.defaults, BinaryParameters: tt.defaults, IgnoreCallerDeadline: tt.defaults,
+		}
+		got := defaults.apply(TenantConnectOptions{
+			ForceUTC: tt.caller, Detached: tt.caller, GuardStatements: tt.caller,
+			DetectFailover: tt.caller, BinaryParameters: tt.caller, IgnoreCallerDeadline: tt.caller,
+		})
+		for field, toggle := range map[string]Toggle{
+			"ForceUTC": got.ForceUTC, "Detached": got.Detached, "GuardStatements": got.GuardStatements,
+			"DetectFailover": got.DetectFailover, "BinaryParameters": got.BinaryParameters, "IgnoreCallerDeadline": got.IgnoreCallerDeadline,
+		} {
+			if toggle.On() != tt.want {
+				t.Errorf("%s: %s = %v, want on %v", tt.name, field, toggle, tt.want)
+			}
+		}
+	}
+
+	// O ForceUTC do catálogo também não sobrepõe o ToggleOff do chamador
+	if got := (TenantDefaults{ForceUTC: true}).apply(TenantConnectOptions{ForceUTC: ToggleOff}); got.ForceUTC.On() {
+		t.Error("catalog ForceUTC overrode ToggleOff")
+	}
+}
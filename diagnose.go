@@ -0,0 +1,237 @@
+package connection
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// Quantidade máxima de servidores de tenant com o DNS verificado por Diagnose
+var DiagnoseSampleSize = 10
+
+// Diferença máxima tolerada entre o relógio local e o do banco do catálogo
+var MaxClockSkew = 2 * time.Second
+
+type DiagnosticSeverity string
+
+const (
+	DiagnosticOK      DiagnosticSeverity = "ok"
+	DiagnosticWarning DiagnosticSeverity = "warning"
+	DiagnosticError   DiagnosticSeverity = "error"
+)
+
+type Diagnostic struct {
+	Check    string             `json:"check"`
+	Severity DiagnosticSeverity `json:"severity"`
+	Message  string             `json:"message"`
+	// Sugestão de correção; vazia quando a verificação passou
+	Hint string `json:"hint,omitempty"`
+}
+
+type DiagnosticsReport struct {
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// OK indica que nenhuma verificação terminou com erro; avisos são permitidos.
+func (r DiagnosticsReport) OK() bool {
+	for _, d := range r.Diagnostics {
+		if d.Severity == DiagnosticError {
+			return false
+		}
+	}
+	return true
+}
+
+// String formata o relatório para leitura, com uma verificação por linha e a
+// sugestão de correção logo abaixo.
+func (r DiagnosticsReport) String() string {
+	var b strings.Builder
+	for _, d := range r.Diagnostics {
+		fmt.Fprintf(&b, "[%s] %s: %s\n", strings.ToUpper(string(d.Severity)), d.Check, d.Message)
+		if d.Hint != "" {
+			fmt.Fprintf(&b, "    hint: %s\n", d.Hint)
+		}
+	}
+	return b.String()
+}
+
+func (r *DiagnosticsReport) add(check string, severity DiagnosticSeverity, message, hint string) {
+	r.Diagnostics = append(r.Diagnostics, Diagnostic{Check: check, Severity: severity, Message: message, Hint: hint})
+}
+
+// Diagnose verifica o ambiente na inicialização: conexão com o catálogo,
+// resolução de DNS de uma amostra dos servidores de tenant, diferença de
+// relógio com o banco e a configuração dos pools. O relatório também é
+// escrito no log, com sugestões de correção para cada problema.
+func Diagnose(ctx context.Context) DiagnosticsReport {
+	var report DiagnosticsReport
+
+	diagnosePoolSettings(&report)
+	if catalogs, ok := diagnoseCatalog(ctx, &report); ok {
+		diagnoseClockSkew(ctx, &report)
+		diagnoseTenantSettings(&report, catalogs)
+		diagnoseDNS(ctx, &report, catalogs)
+	}
+
+	logInfo("Diagnostics report:\n" + report.String())
+	return report
+}
+
+func diagnoseCatalog(ctx context.Context, report *DiagnosticsReport) ([]*Catalog, bool) {
+	if defaultManager.catalog == nil {
+		report.add("catalog", DiagnosticError, "catalog connection not initialized",
+			"call GetCatalogConnection with the catalog URL before Diagnose")
+		return nil, false
+	}
+
+	if err := defaultManager.catalog.PingContext(ctx); err != nil {
+		report.add("catalog", DiagnosticError, "catalog unreachable: "+scrubSecrets(err.Error()),
+			"check the catalog URL, credentials, firewall rules and that the server accepts connections from this host")
+		return nil, false
+	}
+
+	catalogs, err := ListTenants(ctx)
+	if err != nil {
+		report.add("catalog", DiagnosticError, "cannot read the catalog table: "+err.Error(),
+			"run EnsureCatalogSchema to create or upgrade the catalog tables")
+		return nil, false
+	}
+
+	if len(catalogs) == 0 {
+		report.add("catalog", DiagnosticWarning, "catalog reachable but has no tenants",
+			"register tenants with SeedTenant or tenantctl create")
+	} else {
+		report.add("catalog", DiagnosticOK, fmt.Sprintf("catalog reachable, %d tenants", len(catalogs)), "")
+	}
+	return catalogs, true
+}
+
+func diagnoseClockSkew(ctx context.Context, report *DiagnosticsReport) {
+	var dbNow time.Time
+
+	before := clock.Now()
+	if err := defaultManager.catalog.QueryRowContext(ctx, `SELECT now()`).Scan(&dbNow); err != nil {
+		report.add("clock", DiagnosticWarning, "cannot read the catalog clock: "+err.Error(), "")
+		return
+	}
+	after := clock.Now()
+
+	// Compara com o meio da consulta para descontar a latência
+	local := before.Add(after.Sub(before) / 2)
+	skew := local.Sub(dbNow)
+	if skew < 0 {
+		skew = -skew
+	}
+
+	if skew > MaxClockSkew {
+		report.add("clock", DiagnosticWarning, fmt.Sprintf("local clock differs from the catalog database by %s", skew.Round(time.Millisecond)),
+			"enable NTP on this host and on the database server; TTLs, usage periods and job schedules depend on both clocks")
+		return
+	}
+	report.add("clock", DiagnosticOK, fmt.Sprintf("clock skew %s", skew.Round(time.Millisecond)), "")
+}
+
+func diagnosePoolSettings(report *DiagnosticsReport) {
+	ok := true
+	warn := func(message, hint string) {
+		ok = false
+		report.add("pool settings", DiagnosticWarning, message, hint)
+	}
+
+	if currentSettings().setupTimeout <= 0 {
+		warn("DefaultSetupTimeout is not positive", "set DefaultSetupTimeout to a few seconds so pool creation cannot hang forever")
+	}
+	if MaxPoolCreationsPerSecond < 0 {
+		warn("MaxPoolCreationsPerSecond is negative and behaves as unlimited", "use 0 for unlimited or a positive rate")
+	}
+	if StatementCacheSize < 0 {
+		warn("StatementCacheSize is negative and disables the cache", "use 0 to disable the statement cache explicitly")
+	}
+	if PoolWaitThreshold <= 0 {
+		warn("PoolWaitThreshold is not positive", "every deadline on a saturated pool will be reported as ErrPoolExhausted; use at least a few hundred milliseconds")
+	}
+
+	if ok {
+		report.add("pool settings", DiagnosticOK, "package settings are consistent", "")
+	}
+}
+
+// diagnoseTenantSettings verifica as opções de cada tenant no catálogo
+func diagnoseTenantSettings(report *DiagnosticsReport, catalogs []*Catalog) {
+	problems := 0
+	for _, catalog := range catalogs {
+		defaults := catalog.Defaults
+		if defaults.MaxOpenConns > 0 && defaults.MaxIdleConns > defaults.MaxOpenConns {
+			problems++
+			report.add("tenant "+catalog.SchemaName, DiagnosticWarning,
+				fmt.Sprintf("max_idle_conns (%d) is greater than max_open_conns (%d)", defaults.MaxIdleConns, defaults.MaxOpenConns),
+				"lower max_idle_conns in the connect_options column; database/sql caps it at max_open_conns")
+		}
+
+		if _, err := BuildDSN(catalog, TenantConnectOptions{}); err != nil {
+			problems++
+			report.add("tenant "+catalog.SchemaName, DiagnosticError, "invalid connection settings: "+err.Error(),
+				"fix the server and options columns of the tenant in the catalog")
+		}
+
+		if catalog.Dialer != "" {
+			if _, err := registeredDialer(catalog.Dialer); err != nil {
+				problems++
+				report.add("tenant "+catalog.SchemaName, DiagnosticError, err.Error(),
+					"call RegisterDialer with this name during startup, before the first connection")
+			}
+		}
+	}
+
+	if problems == 0 {
+		report.add("tenant settings", DiagnosticOK, "tenant options are consistent", "")
+	}
+}
+
+// diagnoseDNS resolve uma amostra aleatória dos hosts dos servidores de tenant
+func diagnoseDNS(ctx context.Context, report *DiagnosticsReport, catalogs []*Catalog) {
+	seen := make(map[string]bool)
+	var hosts []string
+	for _, catalog := range catalogs {
+		// Tenants com dialer resolvem o endereço do outro lado do túnel
+		if catalog.Dialer != "" {
+			continue
+		}
+		addresses, err := parseServer(catalog.Server)
+		if err != nil {
+			continue
+		}
+		for _, address := range addresses {
+			if address.Socket || net.ParseIP(strings.SplitN(address.Host, "%", 2)[0]) != nil || seen[address.Host] {
+				continue
+			}
+			seen[address.Host] = true
+			hosts = append(hosts, address.Host)
+		}
+	}
+
+	rand.Shuffle(len(hosts), func(i, j int) { hosts[i], hosts[j] = hosts[j], hosts[i] })
+	if len(hosts) > DiagnoseSampleSize {
+		hosts = hosts[:DiagnoseSampleSize]
+	}
+
+	failed := 0
+	for _, host := range hosts {
+		lookupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		_, err := net.DefaultResolver.LookupHost(lookupCtx, host)
+		cancel()
+
+		if err != nil {
+			failed++
+			report.add("dns", DiagnosticError, fmt.Sprintf("cannot resolve %s: %v", host, err),
+				"check the resolv.conf search domains and the DNS/VPN of this environment, or fix the server column in the catalog")
+		}
+	}
+
+	if failed == 0 {
+		report.add("dns", DiagnosticOK, fmt.Sprintf("%d tenant server hosts resolved", len(hosts)), "")
+	}
+}
@@ -0,0 +1,45 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+var ErrUnknownDialer = errors.New("unknown dialer")
+
+// DialFunc abre a conexão de rede com o servidor do tenant. A assinatura é a
+// mesma de net.Dialer.DialContext.
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+var (
+	dialersMutex sync.RWMutex
+	dialers      = make(map[string]DialFunc)
+)
+
+// RegisterDialer registra um dialer com o nome usado na coluna dialer do
+// catálogo. Tenants hospedados na rede do cliente, por exemplo, podem ser
+// acessados por um túnel SSH sem VPN em cada serviço:
+//
+//	connection.RegisterDialer("ssh-acme", func(ctx context.Context, network, address string) (net.Conn, error) {
+//		return sshClient.Dial(network, address)
+//	})
+func RegisterDialer(name string, dial DialFunc) {
+	dialersMutex.Lock()
+	defer dialersMutex.Unlock()
+
+	dialers[name] = dial
+}
+
+func registeredDialer(name string) (DialFunc, error) {
+	dialersMutex.RLock()
+	defer dialersMutex.RUnlock()
+
+	dial, found := dialers[name]
+	if !found {
+		return nil, fmt.Errorf("%w %q", ErrUnknownDialer, name)
+	}
+	return dial, nil
+}
@@ -0,0 +1,516 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/lib/pq"
+)
+
+const defaultPort = "5432"
+
+var (
+	ErrInvalidServer         = errors.New("invalid catalog server")
+	ErrInvalidSessionSetting = errors.New("invalid session setting")
+)
+
+// Nome de GUC, opcionalmente com prefixo de extensão (app.tenant_id)
+var sessionSettingName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+type serverAddress struct {
+	Host string
+	Port string
+	// Host é o diretório do socket Unix do servidor
+	Socket bool
+}
+
+// Endereço no formato aceito pela URL da DSN, sem porta quando não informada
+func (a serverAddress) urlHost() string {
+	// A zona do IPv6 (fe80::1%eth0) precisa ser escapada na URL
+	host := strings.ReplaceAll(a.Host, "%", "%25")
+	switch {
+	case a.Port != "":
+		return net.JoinHostPort(host, a.Port)
+	case strings.Contains(host, ":"):
+		// O lib/pq só remove os colchetes do IPv6 quando há porta
+		return net.JoinHostPort(host, defaultPort)
+	default:
+		return host
+	}
+}
+
+func (a serverAddress) dialAddress(defaultPort string) string {
+	if a.Port == "" {
+		return net.JoinHostPort(a.Host, defaultPort)
+	}
+	return net.JoinHostPort(a.Host, a.Port)
+}
+
+// parseServer interpreta a coluna server do catálogo, que pode conter um
+// único host ou uma lista separada por vírgulas, cada um com porta opcional:
+// "db1", "db1:5433", "[::1]:5433", "::1" ou "db1:5432,db2:5432". Também
+// aceita o diretório de um socket Unix, como "/var/run/postgresql".
+func parseServer(server string) ([]serverAddress, error) {
+	entries := strings.Split(server, ",")
+	addresses := make([]serverAddress, 0, len(entries))
+
+	for _, entry := range entries {
+		address, err := parseServerAddress(strings.TrimSpace(entry))
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidServer, server, err)
+		}
+		if address.Socket && len(entries) > 1 {
+			return nil, fmt.Errorf("%w %q: unix socket cannot be combined with other hosts", ErrInvalidServer, server)
+		}
+		addresses = append(addresses, address)
+	}
+
+	return addresses, nil
+}
+
+func parseServerAddress(entry string) (serverAddress, error) {
+	var (
+		address serverAddress
+		err     error
+	)
+
+	// Socket Unix: "/var/run/postgresql" ou "host=/var/run/postgresql". A
+	// porta, que compõe o nome do arquivo do socket, vem das opções.
+	path := strings.TrimPrefix(entry, "host=")
+	if strings.HasPrefix(path, "/") {
+		address.Host = path
+		address.Socket = true
+		return address, nil
+	}
+
+	switch {
+	case entry == "":
+		return address, errors.New("empty host")
+	case strings.HasPrefix(entry, "[") && strings.HasSuffix(entry, "]"):
+		// IPv6 entre colchetes sem porta
+		address.Host = entry[1 : len(entry)-1]
+	case strings.HasPrefix(entry, "["):
+		address.Host, address.Port, err = net.SplitHostPort(entry)
+		if err == nil && address.Port == "" {
+			err = errors.New("empty port")
+		}
+	case strings.Count(entry, ":") > 1:
+		// IPv6 sem colchetes não pode ter porta
+		address.Host = entry
+	case strings.Contains(entry, ":"):
+		address.Host, address.Port, err = net.SplitHostPort(entry)
+		if err == nil && address.Port == "" {
+			err = errors.New("empty port")
+		}
+	default:
+		address.Host = entry
+	}
+	if err != nil {
+		return address, err
+	}
+
+	if address.Host == "" {
+		return address, errors.New("empty host")
+	}
+	if strings.Contains(address.Host, ":") {
+		ip, _, _ := strings.Cut(address.Host, "%")
+		if net.ParseIP(ip) == nil {
+			return address, fmt.Errorf("invalid IPv6 address %q", address.Host)
+		}
+	}
+	if address.Port != "" {
+		if port, err := strconv.Atoi(address.Port); err != nil || port < 1 || port > 65535 {
+			return address, fmt.Errorf("invalid port %q", address.Port)
+		}
+	}
+
+	return address, nil
+}
+
+// Parâmetros da libpq que o lib/pq não entende e enviaria ao servidor como
+// parâmetros de sessão, fazendo a conexão falhar. Continuam na DSN de
+// BuildDSN, usada por ferramentas como o pg_dump, e são removidos da DSN do
+// driver; os keepalives são aplicados pelo dialer e o target_session_attrs
+// por sessionAttrsConnector.
+var libpqOnlyParams = []string{"keepalives", "keepalives_idle", "keepalives_interval", "keepalives_count", "target_session_attrs"}
+
+// BuildDSN monta a string de conexão do tenant no formato de URL da libpq,
+// com todos os hosts do catálogo, os parâmetros do catálogo e as opções
+// (connect_timeout, binary_parameters, keepalives, target_session_attrs,
+// parâmetros de sessão e ExtraParams). É o mesmo caminho usado pelo pacote
+// para abrir os pools, que apenas remove o que o lib/pq não suporta.
+func BuildDSN(catalog *Catalog, opts TenantConnectOptions) (string, error) {
+	if err := validateSessionSettings(opts.SessionSettings); err != nil {
+		return "", err
+	}
+
+	addresses, err := parseServer(catalog.Server)
+	if err != nil {
+		return "", err
+	}
+
+	params := dsnParams(catalog, opts)
+	if addresses[0].Socket {
+		return socketDSN(catalog, addresses[0].Host, params), nil
+	}
+	if err := checkTLS(catalog, params, opts); err != nil {
+		return "", err
+	}
+
+	hosts := make([]string, len(addresses))
+	for i, address := range addresses {
+		hosts[i] = address.urlHost()
+	}
+
+	return formatDSN(catalog, strings.Join(hosts, ","), params), nil
+}
+
+// socketDSN monta a DSN no formato chave=valor, pois o caminho do socket não
+// pode ser usado como host de uma URL
+func socketDSN(catalog *Catalog, dir string, params url.Values) string {
+	pairs := []string{
+		"host=" + quoteDSNValue(dir),
+		"user=" + quoteDSNValue(catalog.UserName),
+		"dbname=" + quoteDSNValue(catalog.DatabaseName),
+	}
+	if catalog.Password != "" {
+		pairs = append(pairs, "password="+quoteDSNValue(catalog.Password))
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+quoteDSNValue(params.Get(key)))
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+func quoteDSNValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+func formatDSN(catalog *Catalog, host string, params url.Values) string {
+	scheme := catalog.Driver
+	if scheme == yugabyteDriver {
+		// O YugabyteDB usa o protocolo do Postgres e o mesmo formato de URL
+		scheme = "postgres"
+	}
+	// Sem senha, a DSN não a define, e a libpq pode usar PGPASSWORD
+	user := url.User(catalog.UserName)
+	if catalog.Password != "" {
+		user = url.UserPassword(catalog.UserName, catalog.Password)
+	}
+	return fmt.Sprintf("%s://%s@%s/%s?%s", scheme, user.String(), host, catalog.DatabaseName, params.Encode())
+}
+
+func dsnParams(catalog *Catalog, opts TenantConnectOptions) url.Values {
+	params := url.Values{}
+	params.Set("sslmode", "disable")
+	if catalog.Driver == yugabyteDriver {
+		params.Set("load_balance", "true")
+	}
+	// As opções do catálogo sobrescrevem os padrões, mas não as do chamador
+	for key, value := range catalog.Options {
+		params.Set(key, value)
+	}
+	if opts.DialTimeout > 0 {
+		params.Set("connect_timeout", strconv.Itoa(int(math.Ceil(opts.DialTimeout.Seconds()))))
+	}
+	if opts.BinaryParameters.On() {
+		params.Set("binary_parameters", "yes")
+	}
+	if opts.KeepAlive > 0 {
+		params.Set("keepalives", "1")
+		params.Set("keepalives_idle", strconv.Itoa(int(math.Ceil(opts.KeepAlive.Seconds()))))
+	}
+	if opts.TargetSessionAttrs != "" {
+		params.Set("target_session_attrs", opts.TargetSessionAttrs)
+	}
+	if opts.ForceUTC.On() {
+		// Parâmetros desconhecidos pelo lib/pq são enviados na inicialização
+		// de cada sessão
+		params.Set("timezone", "UTC")
+	}
+	settings := make(map[string]string, len(opts.SessionSettings)+2)
+	for name, value := range opts.SessionSettings {
+		settings[name] = value
+	}
+	if _, found := settings["statement_timeout"]; !found && opts.StatementTimeout > 0 {
+		settings["statement_timeout"] = strconv.FormatInt(opts.StatementTimeout.Milliseconds(), 10)
+	}
+	// O search_path vai na inicialização de cada sessão, e não em um SET
+	// executado em uma das conexões do pool
+	if _, found := settings["search_path"]; !found && catalog.SchemaName != "" {
+		settings["search_path"] = pq.QuoteIdentifier(catalog.SchemaName)
+	}
+	if len(settings) > 0 {
+		options := sessionOptions(settings)
+		if current := params.Get("options"); current != "" {
+			options = current + " " + options
+		}
+		params.Set("options", options)
+	}
+	for key, value := range opts.ExtraParams {
+		params.Set(key, value)
+	}
+
+	return params
+}
+
+// keepAlive retorna o intervalo de keepalive TCP definido nos parâmetros, ou
+// zero quando não informado. Com keepalives=0, retorna -1 (desabilitado).
+func keepAlive(params url.Values) time.Duration {
+	if params.Get("keepalives") == "0" {
+		return -1
+	}
+	seconds, err := strconv.Atoi(params.Get("keepalives_idle"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func validateSessionSettings(settings map[string]string) error {
+	for name, value := range settings {
+		if !sessionSettingName.MatchString(name) || strings.ContainsRune(value, 0) {
+			return fmt.Errorf("%w: %q", ErrInvalidSessionSetting, name)
+		}
+	}
+	return nil
+}
+
+// sessionOptions monta o parâmetro options da DSN (-c nome=valor ...). O
+// Postgres separa os argumentos por espaço, então espaços e barras invertidas
+// dos valores são escapados com barra invertida.
+func sessionOptions(settings map[string]string) string {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := make([]string, 0, len(names))
+	for _, name := range names {
+		var value strings.Builder
+		for _, r := range settings[name] {
+			if r == '\\' || unicode.IsSpace(r) {
+				value.WriteRune('\\')
+			}
+			value.WriteRune(r)
+		}
+		args = append(args, "-c "+name+"="+value.String())
+	}
+	return strings.Join(args, " ")
+}
+
+// Variável de ambiente TENANT_<NOME>_DSN, com caracteres fora de [A-Z0-9]
+// trocados por "_" (tenant "acme-br" usa TENANT_ACME_BR_DSN)
+func dsnOverride(tenant string, opts TenantConnectOptions) string {
+	if opts.DSNOverride != "" {
+		return opts.DSNOverride
+	}
+
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(tenant))
+
+	return os.Getenv("TENANT_" + name + "_DSN")
+}
+
+func openTenantDB(catalog *Catalog, opts TenantConnectOptions) (*sql.DB, error) {
+	if catalog.AuthType != "" {
+		connector, err := newTokenConnector(catalog, opts)
+		if err != nil {
+			return nil, err
+		}
+		return sql.OpenDB(cancelConnector{connector}), nil
+	}
+
+	connector, err := driverConnector(catalog, opts)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(cancelConnector{connector}), nil
+}
+
+// searchPathConnector define o search_path de cada conexão aberta com uma DSN
+// do usuário (DSNOverride), na qual o parâmetro options não é montado pelo
+// pacote
+type searchPathConnector struct {
+	driver.Connector
+	schema string
+}
+
+func (c searchPathConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("driver connection %T cannot set search_path", conn)
+	}
+	if _, err := execer.ExecContext(ctx, "SET search_path TO "+pq.QuoteIdentifier(c.schema), nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// driverConnector monta o connector do lib/pq para o tenant, com o dialer de
+// tenantConnector, o target_session_attrs aplicado por sessionAttrsConnector
+// e o balanceamento do YugabyteDB
+func driverConnector(catalog *Catalog, opts TenantConnectOptions) (driver.Connector, error) {
+	dsn, dialer, err := tenantConnector(catalog, opts)
+	if err != nil {
+		return nil, err
+	}
+	params := dsnParams(catalog, opts)
+	if balancer, ok := dialer.(failoverDialer); ok && catalog.Driver == yugabyteDriver && params.Get("load_balance") == "true" {
+		return newYugabyteConnector(dsn, balancer, params)
+	}
+	if attrs := params.Get("target_session_attrs"); attrs != "" && attrs != "any" {
+		return newSessionAttrsConnector(dsn, dialer, attrs)
+	}
+
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if dialer != nil {
+		connector.Dialer(dialer)
+	}
+	return connector, nil
+}
+
+// tenantConnector monta a DSN do tenant para o lib/pq e, quando necessário, o
+// dialer a ser usado pelo driver. O dialer é nil quando o padrão do lib/pq é
+// suficiente.
+func tenantConnector(catalog *Catalog, opts TenantConnectOptions) (string, pq.Dialer, error) {
+	if err := validateSessionSettings(opts.SessionSettings); err != nil {
+		return "", nil, err
+	}
+
+	addresses, err := parseServer(catalog.Server)
+	if err != nil {
+		return "", nil, err
+	}
+
+	params := dsnParams(catalog, opts)
+	keepAlive := keepAlive(params)
+	loadBalance := catalog.Driver == yugabyteDriver && params.Get("load_balance") == "true"
+	for _, key := range libpqOnlyParams {
+		params.Del(key)
+	}
+	for _, key := range yugabyteParams {
+		params.Del(key)
+	}
+
+	if addresses[0].Socket {
+		return socketDSN(catalog, addresses[0].Host, params), nil, nil
+	}
+	if err := checkTLS(catalog, params, opts); err != nil {
+		return "", nil, err
+	}
+
+	dial := opts.DialFunc
+	if dial == nil && catalog.Dialer != "" {
+		if dial, err = registeredDialer(catalog.Dialer); err != nil {
+			return "", nil, err
+		}
+	}
+
+	dsn := formatDSN(catalog, addresses[0].urlHost(), params)
+	if len(addresses) == 1 && keepAlive == 0 && dial == nil && !loadBalance {
+		return dsn, nil, nil
+	}
+
+	// O lib/pq não suporta múltiplos hosts na DSN, então o failover é feito
+	// no dialer, tentando os hosts na ordem em que aparecem no catálogo.
+	port := defaultPort
+	if p, found := catalog.Options["port"]; found {
+		port = p
+	}
+
+	dialer := failoverDialer{dialer: net.Dialer{KeepAlive: keepAlive}, dial: dial, weighted: catalog.readReplica}
+	for _, address := range addresses {
+		dialer.addresses = append(dialer.addresses, address.dialAddress(port))
+	}
+
+	return dsn, dialer, nil
+}
+
+type failoverDialer struct {
+	addresses []string
+	dialer    net.Dialer
+	// Dialer registrado para o tenant; quando nil, é usado o net.Dialer
+	dial DialFunc
+	// Hosts de réplica, tentados na ordem sorteada pela saúde de cada um
+	// em vez da ordem do catálogo
+	weighted bool
+}
+
+func (d failoverDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+func (d failoverDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return d.DialContext(ctx, network, address)
+}
+
+// DialContext ignora o endereço montado pelo driver e tenta cada host configurado
+func (d failoverDialer) DialContext(ctx context.Context, network, _ string) (net.Conn, error) {
+	var errs []error
+	dial := d.dial
+	if dial == nil {
+		dial = d.dialer.DialContext
+	}
+
+	addresses := d.addresses
+	if d.weighted && len(addresses) > 1 {
+		addresses = weightedOrder(addresses)
+	}
+
+	for _, address := range addresses {
+		start := clock.Now()
+		conn, err := dial(ctx, network, address)
+		if d.weighted {
+			recordReplicaDial(address, clock.Now().Sub(start), err)
+		}
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
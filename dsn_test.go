@@ -0,0 +1,165 @@
+package connection
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseServer(t *testing.T) {
+	tests := []struct {
+		server string
+		want   []serverAddress
+	}{
+		{"db1", []serverAddress{{Host: "db1"}}},
+		{"db1:5433", []serverAddress{{Host: "db1", Port: "5433"}}},
+		{"[::1]:5433", []serverAddress{{Host: "::1", Port: "5433"}}},
+		{"[::1]", []serverAddress{{Host: "::1"}}},
+		{"::1", []serverAddress{{Host: "::1"}}},
+		{"fe80::1%eth0", []serverAddress{{Host: "fe80::1%eth0"}}},
+		{"[fe80::1%eth0]:5432", []serverAddress{{Host: "fe80::1%eth0", Port: "5432"}}},
+		{"db1:5432,db2:5433", []serverAddress{{Host: "db1", Port: "5432"}, {Host: "db2", Port: "5433"}}},
+		{"db1, [::1]:5433, db3", []serverAddress{{Host: "db1"}, {Host: "::1", Port: "5433"}, {Host: "db3"}}},
+		{"/var/run/postgresql", []serverAddress{{Host: "/var/run/postgresql", Socket: true}}},
+		{"host=/tmp", []serverAddress{{Host: "/tmp", Socket: true}}},
+	}
+	for _, tt := range tests {
+		got, err := parseServer(tt.server)
+		if err != nil {
+			t.Errorf("parseServer(%q) error = %v", tt.server, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseServer(%q) = %+v, want %+v", tt.server, got, tt.want)
+		}
+	}
+}
+
+func TestParseServerInvalid(t *testing.T) {
+	for _, server := range []string{
+		"",
+		"db1,",
+		"db1:0",
+		"db1:65536",
+		"db1:abc",
+		"db1:",
+		"[::1]:",
+		"[::1]:99999",
+		"[::1",
+		"::zz",
+		"[]:5432",
+		"/var/run/postgresql,db2",
+	} {
+		if _, err := parseServer(server); !errors.Is(err, ErrInvalidServer) {
+			t.Errorf("parseServer(%q) error = %v, want ErrInvalidServer", server, err)
+		}
+	}
+}
+
+func TestURLHost(t *testing.T) {
+	tests := []struct {
+		address serverAddress
+		want    string
+	}{
+		{serverAddress{Host: "db1"}, "db1"},
+		{serverAddress{Host: "db1", Port: "5433"}, "db1:5433"},
+		{serverAddress{Host: "::1"}, "[::1]:5432"},
+		{serverAddress{Host: "::1", Port: "5433"}, "[::1]:5433"},
+		{serverAddress{Host: "fe80::1%eth0"}, "[fe80::1%25eth0]:5432"},
+	}
+	for _, tt := range tests {
+		if got := tt.address.urlHost(); got != tt.want {
+			t.Errorf("%+v.urlHost() = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestBuildDSNHosts(t *testing.T) {
+	tests := []struct {
+		server string
+		host   string
+	}{
+		{"[::1]:5433", "[::1]:5433"},
+		{"::1", "[::1]:5432"},
+		{"db1:5432,db2", "db1:5432,db2"},
+	}
+	for _, tt := range tests {
+		catalog := &Catalog{Driver: "postgres", UserName: "app", Server: tt.server, DatabaseName: "app"}
+		dsn, err := BuildDSN(catalog, TenantConnectOptions{})
+		if err != nil {
+			t.Fatalf("BuildDSN(%q) error = %v", tt.server, err)
+		}
+		// A lista de hosts não é aceita por url.Parse
+		if !strings.HasPrefix(dsn, "postgres://app@"+tt.host+"/app?") {
+			t.Errorf("BuildDSN(%q) = %q, want host %q", tt.server, dsn, tt.host)
+		}
+	}
+}
+
+func TestBuildDSNSearchPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		settings map[string]string
+		want     string
+	}{
+		{"plain", "acme", nil, `-c search_path="acme"`},
+		{"quoted", `Acme Corp"; DROP`, nil, `-c search_path="Acme\ Corp"";\ DROP"`},
+		{"caller setting wins", "acme", map[string]string{"search_path": "shared"}, `-c search_path=shared`},
+		{"with other settings", "acme", map[string]string{"work_mem": "64MB"}, `-c search_path="acme" -c work_mem=64MB`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			catalog := &Catalog{Driver: "postgres", UserName: "app", Server: "db.internal", DatabaseName: "app", SchemaName: tt.schema}
+			dsn, err := BuildDSN(catalog, TenantConnectOptions{SessionSettings: tt.settings})
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := url.Parse(dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := parsed.Query().Get("options"); got != tt.want {
+				t.Fatalf("options = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type execRecorder struct {
+	driver.Conn
+	queries []string
+}
+
+func (c *execRecorder) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.queries = append(c.queries, query)
+	return driver.RowsAffected(0), nil
+}
+
+type recorderConnector struct {
+	conn *execRecorder
+}
+
+func (c recorderConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.conn, nil
+}
+
+func (recorderConnector) Driver() driver.Driver {
+	return errorDriver{}
+}
+
+func TestSearchPathConnector(t *testing.T) {
+	conn := &execRecorder{Conn: errorConn{}}
+	connector := searchPathConnector{Connector: recorderConnector{conn}, schema: `we"ird`}
+	if _, err := connector.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(conn.queries) != 1 || conn.queries[0] != `SET search_path TO "we""ird"` {
+		t.Fatalf("queries = %q", conn.queries)
+	}
+}
@@ -0,0 +1,97 @@
+package connection
+
+import (
+	"time"
+)
+
+type EventType string
+
+const (
+	// Pool do tenant criado e salvo no cache
+	EventCreated EventType = "created"
+	// Pool do tenant encontrado no cache
+	EventCacheHit EventType = "cache-hit"
+	// Pool removido do cache por TTL, falta de espaço ou invalidação
+	EventEvicted EventType = "evicted"
+	// Pool fechado após o período de carência
+	EventClosed EventType = "closed"
+	// Falha ao validar um pool recém-criado (ping ou search_path)
+	EventUnhealthy EventType = "unhealthy"
+	// Panic recuperado por RecoverMiddleware; Reason traz a mensagem
+	EventPanic EventType = "panic"
+	// Pool descartado após um erro de failover (DetectFailover); Reason traz
+	// o erro
+	EventFailover EventType = "failover"
+	// Réplica regional excluída do roteamento pelo atraso da replicação, ou
+	// de volta a ele; Reason traz a região e o atraso
+	EventReplicaExcluded EventType = "replica-excluded"
+	EventReplicaRestored EventType = "replica-restored"
+	// Tenant acima de um limite de StorageLimits na coleta do CollectStats,
+	// ou de volta abaixo dele; Reason traz a medida e os valores
+	EventStorageLimitExceeded EventType = "storage-limit-exceeded"
+	EventStorageLimitCleared  EventType = "storage-limit-cleared"
+)
+
+type Event struct {
+	Type   EventType
+	Tenant string
+	Time   time.Time
+	// Motivo do evento, como "expired", "invalidated" ou o erro ocorrido
+	Reason string
+}
+
+type eventSubscriber struct {
+	id int
+	fn func(Event)
+}
+
+// Subscribe registra fn para receber os eventos do ciclo de vida dos pools e
+// retorna a função que cancela a inscrição. Os eventos são entregues de forma
+// síncrona e na ordem em que acontecem, então fn deve ser rápida e não pode
+// obter conexões do pacote, pois alguns eventos são emitidos durante a criação
+// do pool.
+func Subscribe(fn func(Event)) (unsubscribe func()) {
+	return defaultManager.Subscribe(fn)
+}
+
+// Subscribe registra fn para receber os eventos dos pools deste Manager.
+func (m *Manager) Subscribe(fn func(Event)) (unsubscribe func()) {
+	m.subscribersMutex.Lock()
+	defer m.subscribersMutex.Unlock()
+
+	m.nextSubscriberID++
+	id := m.nextSubscriberID
+	m.subscribers = append(m.subscribers, eventSubscriber{id: id, fn: fn})
+
+	return func() {
+		m.subscribersMutex.Lock()
+		defer m.subscribersMutex.Unlock()
+
+		for i, subscriber := range m.subscribers {
+			if subscriber.id == id {
+				m.subscribers = append(m.subscribers[:i:i], m.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// emit envia o evento aos inscritos no Manager padrão
+func emit(eventType EventType, tenant, reason string) {
+	defaultManager.emit(eventType, tenant, reason)
+}
+
+func (m *Manager) emit(eventType EventType, tenant, reason string) {
+	m.subscribersMutex.RLock()
+	current := m.subscribers
+	m.subscribersMutex.RUnlock()
+
+	if len(current) == 0 {
+		return
+	}
+
+	event := Event{Type: eventType, Tenant: tenant, Time: clock.Now(), Reason: reason}
+	for _, subscriber := range current {
+		subscriber.fn(event)
+	}
+}
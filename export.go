@@ -0,0 +1,352 @@
+package connection
+
+import (
+	"archive/tar"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+type ExportFormat int
+
+const (
+	// Arquivo tar com manifest.json e um arquivo JSON Lines por tabela. A
+	// primeira linha de cada arquivo traz os nomes das colunas e as demais os
+	// valores em texto (null para NULL). O lib/pq não suporta COPY TO, então
+	// os dados são lidos com SELECT.
+	ExportData ExportFormat = iota
+	// DDL do schema gerada pelo pg_dump --schema-only.
+	ExportSchemaOnly
+)
+
+type ExportOptions struct {
+	Format ExportFormat
+	// Tabelas exportadas; vazio exporta todas as tabelas do schema
+	Tables []string
+	// Caminho do pg_dump usado em ExportSchemaOnly; padrão "pg_dump"
+	PgDumpPath string
+	// Regras de mascaramento aplicadas aos dados exportados em ExportData
+	Anonymize AnonymizationRules
+}
+
+const exportManifestName = "manifest.json"
+
+type exportManifest struct {
+	Tenant    string        `json:"tenant"`
+	CreatedAt time.Time     `json:"created_at"`
+	Tables    []exportTable `json:"tables"`
+}
+
+type exportTable struct {
+	Name    string   `json:"name"`
+	File    string   `json:"file"`
+	Columns []string `json:"columns"`
+}
+
+// ExportTenant grava em w os dados ou a estrutura do schema do tenant, para
+// backups e pedidos de portabilidade de dados (LGPD/GDPR).
+func ExportTenant(ctx context.Context, tenant string, w io.Writer, opts ExportOptions) error {
+	switch opts.Format {
+	case ExportData:
+		conn, err := GetTenantConnectionWithOptions(ctx, tenant, TenantConnectOptions{})
+		if err != nil {
+			return err
+		}
+		if err := opts.Anonymize.check(ctx, conn); err != nil {
+			return err
+		}
+		return exportData(ctx, conn, conn, w, opts.Tables, opts.Anonymize, nil)
+	case ExportSchemaOnly:
+		return exportSchema(ctx, tenant, w, opts.PgDumpPath)
+	default:
+		return fmt.Errorf("unknown export format %d", opts.Format)
+	}
+}
+
+// rowsQuerier é a parte de Connection e de *sql.Tx usada na leitura das
+// tabelas exportadas
+type rowsQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// exportData grava o arquivo de ExportData lendo as linhas com q, que pode
+// ser a própria conexão ou uma transação, com as colunas mascaradas por
+// rules. progress, quando informada, é chamada após cada tabela.
+func exportData(ctx context.Context, conn Connection, q rowsQuerier, w io.Writer, tables []string, rules AnonymizationRules, progress func(BackupProgress)) error {
+	ordered, err := conn.dependencyOrder(ctx, tables)
+	if err != nil {
+		return err
+	}
+
+	manifest := exportManifest{Tenant: conn.SearchPath, CreatedAt: clock.Now().UTC()}
+	for _, table := range ordered {
+		columns, err := conn.insertableColumns(ctx, table)
+		if err != nil {
+			return err
+		}
+		manifest.Tables = append(manifest.Tables, exportTable{Name: table, File: table + ".jsonl", Columns: columns})
+	}
+
+	tw := tar.NewWriter(w)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, exportManifestName, data); err != nil {
+		return err
+	}
+
+	for i, table := range manifest.Tables {
+		rows, err := exportTableData(ctx, conn, q, tw, table, rules)
+		if err != nil {
+			return fmt.Errorf("exporting %s: %w", table.Name, err)
+		}
+		if progress != nil {
+			progress(BackupProgress{Table: table.Name, Done: i + 1, Total: len(manifest.Tables), Rows: rows})
+		}
+	}
+
+	return tw.Close()
+}
+
+// O cabeçalho do tar exige o tamanho do arquivo, então cada tabela é gravada
+// primeiro em um arquivo temporário.
+func exportTableData(ctx context.Context, conn Connection, q rowsQuerier, tw *tar.Writer, table exportTable, rules AnonymizationRules) (int64, error) {
+	tmp, err := os.CreateTemp("", "tenant-export-*.jsonl")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	enc := json.NewEncoder(tmp)
+	if err := enc.Encode(table.Columns); err != nil {
+		return 0, err
+	}
+
+	selects, err := rules.selectList(ctx, conn, table.Name, table.Columns)
+	if err != nil {
+		return 0, err
+	}
+	for i := range selects {
+		selects[i] += "::text"
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selects, ", "), conn.qualified(table.Name))
+
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count int64
+	values := make([]sql.NullString, len(table.Columns))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	line := make([]*string, len(values))
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return count, err
+		}
+		for i, value := range values {
+			line[i] = nil
+			if value.Valid {
+				line[i] = &values[i].String
+			}
+		}
+		if err := enc.Encode(line); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+
+	info, err := tmp.Stat()
+	if err != nil {
+		return count, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return count, err
+	}
+
+	if err := tw.WriteHeader(&tar.Header{Name: table.File, Mode: 0o644, Size: info.Size(), ModTime: clock.Now()}); err != nil {
+		return count, err
+	}
+	_, err = io.Copy(tw, tmp)
+	return count, err
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: clock.Now()}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func exportSchema(ctx context.Context, tenant string, w io.Writer, pgDump string) error {
+	if pgDump == "" {
+		pgDump = "pg_dump"
+	}
+
+	catalog, err := GetTenantContext(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	if catalog, err = withAuthToken(ctx, catalog); err != nil {
+		return err
+	}
+	cmd, err := pgDumpCommand(ctx, pgDump, catalog, "--schema-only", "--no-owner", "--no-privileges", "--schema="+tenant)
+	if err != nil {
+		return err
+	}
+
+	var stderr strings.Builder
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		// A saída de erro do pg_dump não contém a senha
+		return fmt.Errorf("pg_dump: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// pgDumpCommand monta o comando do pg_dump para o banco do tenant. A senha (ou
+// o token) vai em PGPASSWORD, e não na DSN, para não aparecer na linha de
+// comando do processo (ps, /proc/<pid>/cmdline).
+func pgDumpCommand(ctx context.Context, pgDump string, catalog *Catalog, args ...string) (*exec.Cmd, error) {
+	withoutPassword := *catalog
+	withoutPassword.Password = ""
+	dsn, err := BuildDSN(&withoutPassword, TenantConnectOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, pgDump, append(args, "--dbname="+dsn)...)
+	cmd.Env = os.Environ()
+	if catalog.Password != "" {
+		cmd.Env = append(cmd.Env, "PGPASSWORD="+catalog.Password)
+	}
+	return cmd, nil
+}
+
+// Colunas que podem receber valores em uma importação (sem colunas geradas)
+func (c Connection) insertableColumns(ctx context.Context, table string) ([]string, error) {
+	rows, err := c.QueryContext(ctx, `
+        SELECT column_name
+        FROM information_schema.columns
+		WHERE table_schema = $1 AND table_name = $2 AND is_generated = 'NEVER'
+        ORDER BY ordinal_position`, c.SearchPath, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s not found in schema %s", table, c.SearchPath)
+	}
+
+	return columns, nil
+}
+
+// dependencyOrder ordena as tabelas para que as referenciadas por chaves
+// estrangeiras venham antes das que as referenciam. Tabelas em ciclos ficam
+// no final, na ordem alfabética.
+func (c Connection) dependencyOrder(ctx context.Context, tables []string) ([]string, error) {
+	if len(tables) == 0 {
+		var err error
+		if tables, err = c.Tables(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := c.QueryContext(ctx, `
+        SELECT child.relname, parent.relname
+        FROM pg_constraint con
+        JOIN pg_class child ON child.oid = con.conrelid
+        JOIN pg_class parent ON parent.oid = con.confrelid
+        JOIN pg_namespace ns ON ns.oid = child.relnamespace
+		WHERE con.contype = 'f' AND ns.nspname = $1 AND con.conrelid <> con.confrelid`, c.SearchPath)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	selected := make(map[string]bool, len(tables))
+	unique := tables[:0:0]
+	for _, table := range tables {
+		if !selected[table] {
+			selected[table] = true
+			unique = append(unique, table)
+		}
+	}
+	tables = unique
+
+	pending := make(map[string]int, len(tables))
+	dependents := make(map[string][]string)
+	for rows.Next() {
+		var child, parent string
+		if err := rows.Scan(&child, &parent); err != nil {
+			return nil, err
+		}
+		if selected[child] && selected[parent] {
+			pending[child]++
+			dependents[parent] = append(dependents[parent], child)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ordered := make([]string, 0, len(tables))
+	done := make(map[string]bool, len(tables))
+	for len(ordered) < len(tables) {
+		progress := false
+		for _, table := range tables {
+			if done[table] || pending[table] > 0 {
+				continue
+			}
+			done[table] = true
+			ordered = append(ordered, table)
+			for _, child := range dependents[table] {
+				pending[child]--
+			}
+			progress = true
+		}
+		if !progress {
+			// Ciclo entre chaves estrangeiras
+			for _, table := range tables {
+				if !done[table] {
+					done[table] = true
+					ordered = append(ordered, table)
+				}
+			}
+		}
+	}
+
+	return ordered, nil
+}
@@ -0,0 +1,39 @@
+package connection
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPgDumpCommandKeepsPasswordOffArgv(t *testing.T) {
+	tests := []struct {
+		name   string
+		server string
+	}{
+		{"tcp", "db.internal:5432"},
+		{"hosts", "db1.internal,db2.internal:5433"},
+		{"socket", "/var/run/postgresql"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			catalog := &Catalog{Driver: "postgres", UserName: "acme", Password: "p@ss:w/rd", Server: tt.server, DatabaseName: "app", SchemaName: "acme"}
+
+			cmd, err := pgDumpCommand(context.Background(), "pg_dump", catalog, "--schema-only")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, arg := range cmd.Args {
+				if strings.Contains(arg, "p@ss") || strings.Contains(arg, "p%40ss") || strings.Contains(arg, "password") {
+					t.Errorf("argument %q contains the password", arg)
+				}
+			}
+			if got := cmd.Env[len(cmd.Env)-1]; got != "PGPASSWORD=p@ss:w/rd" {
+				t.Errorf("last env = %q, want PGPASSWORD", got)
+			}
+			if catalog.Password != "p@ss:w/rd" {
+				t.Error("catalog password was modified")
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package connection
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Intervalo mínimo entre dois descartes do pool do mesmo tenant por
+// failover, evitando recriar o pool a cada erro enquanto o DNS do cluster
+// ainda aponta para o servidor antigo
+var FailoverCooldown = 5 * time.Second
+
+// isFailoverError indica erros de um servidor que deixou de ser o primário:
+// escrita recusada por transação somente leitura, como acontece no antigo
+// writer após um failover do Aurora, ou servidor encerrado pelo administrador
+func isFailoverError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code {
+	case "25006", "57P01", "57P02":
+		// read_only_sql_transaction, admin_shutdown, crash_shutdown
+		return true
+	}
+	return false
+}
+
+// failoverDetected descarta o pool do tenant quando a conexão usa
+// DetectFailover, para que as próximas conexões resolvam o DNS novamente e
+// alcancem o novo primário, sem esperar ConnMaxLifetime renovar as conexões.
+func (c Connection) failoverDetected(err error) {
+	m := c.manager
+	if m == nil {
+		m = defaultManager
+	}
+
+	current, found := m.trackedConnection(c.poolKey())
+	if !found || current.DB != c.DB {
+		return
+	}
+
+	now := clock.Now()
+	m.failoverMutex.Lock()
+	if last, found := m.failovers[c.SearchPath]; found && now.Sub(last) < FailoverCooldown {
+		m.failoverMutex.Unlock()
+		return
+	}
+	if m.failovers == nil {
+		m.failovers = make(map[string]time.Time)
+	}
+	m.failovers[c.SearchPath] = now
+	m.failoverMutex.Unlock()
+
+	logError("Failover detected for tenant ", c.SearchPath, ", discarding pool: ", err)
+	m.emit(EventFailover, c.SearchPath, err.Error())
+	m.invalidateConnection(c.SearchPath)
+}
@@ -0,0 +1,239 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrStatementDenied = errors.New("statement denied")
+
+// StatementRule é uma regra da lista de comandos proibidos. Match recebe o
+// comando normalizado: sem comentários, com os literais trocados por '?', os
+// espaços reduzidos a um e em maiúsculas, inclusive os identificadores entre
+// aspas. Vários comandos na mesma string são separados por "; ".
+//
+// As regras são uma rede de segurança, não um parser de SQL: funções que
+// executam SQL dinâmico (EXECUTE, DO) ou condições sempre verdadeiras
+// (WHERE true) não são detectadas.
+type StatementRule struct {
+	Name  string
+	Match func(statement string) bool
+}
+
+// DenyPattern cria uma regra a partir de uma expressão regular, aplicada ao
+// início de cada comando normalizado.
+func DenyPattern(name, pattern string) StatementRule {
+	re := regexp.MustCompile(`(?i)(?:^|; )(?:` + pattern + `)`)
+	return StatementRule{Name: name, Match: re.MatchString}
+}
+
+// Comandos recusados pelas conexões com GuardStatements. A lista pode ser
+// alterada na inicialização da aplicação.
+var DeniedStatements = []StatementRule{
+	DenyPattern("drop", `DROP (SCHEMA|DATABASE|TABLE|OWNED|ROLE|USER)\b`),
+	DenyPattern("truncate", `TRUNCATE\b`),
+	{Name: "delete without where", Match: withoutWhere("DELETE")},
+	{Name: "update without where", Match: withoutWhere("UPDATE")},
+	DenyPattern("role", `(CREATE|ALTER|DROP) (ROLE|USER)\b|GRANT\b|REVOKE\b`),
+	DenyPattern("alter system", `ALTER SYSTEM\b`),
+	// Trocar o schema ou o usuário da sessão escapa do isolamento do tenant
+	DenyPattern("session", `(SET|RESET)( SESSION| LOCAL)? "?(SEARCH_PATH|ROLE|SESSION AUTHORIZATION)\b`),
+	DenyPattern("copy program", `COPY\b[^;]* PROGRAM\b`),
+	{Name: "multiple statements", Match: func(statement string) bool { return strings.Contains(statement, "; ") }},
+}
+
+// Palavras que precedem um DELETE ou UPDATE que é o próprio comando, e não
+// parte de outro (FOR UPDATE, ON DELETE, BEFORE UPDATE...)
+var commandStarts = map[string]bool{"": true, "(": true, ")": true, ";": true, "AS": true, "EXPLAIN": true, "ANALYZE": true, "VERBOSE": true, "INSTEAD": true, "ALSO": true}
+
+// withoutWhere encontra os comandos command (DELETE ou UPDATE) sem WHERE no
+// mesmo nível de parênteses, incluindo os que estão em CTEs (WITH x AS
+// (DELETE ...)) ou depois delas. Um WHERE de subquery (USING (SELECT ...
+// WHERE ...)) não conta.
+func withoutWhere(command string) func(string) bool {
+	return func(statement string) bool {
+		tokens, _ := tokenizeSQL(statement)
+		for i, token := range tokens {
+			previous := ""
+			if i > 0 {
+				previous = tokens[i-1].text
+			}
+			if token.kind == tokenWord && token.text == command && commandStarts[previous] && !hasWhere(tokens[i+1:]) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// hasWhere indica se há um WHERE antes do fim do comando iniciado em tokens:
+// o ; ou o parêntese que fecha o nível atual
+func hasWhere(tokens []sqlToken) bool {
+	depth := 0
+	for _, token := range tokens {
+		if token.kind != tokenWord && token.kind != tokenSemicolon {
+			continue
+		}
+		switch token.text {
+		case "(":
+			depth++
+		case ")":
+			if depth == 0 {
+				return false
+			}
+			depth--
+		case ";":
+			return false
+		case "WHERE":
+			if depth == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// StatementDeniedError indica um comando recusado por uma regra de
+// DeniedStatements. errors.Is reconhece ErrStatementDenied.
+type StatementDeniedError struct {
+	Tenant string
+	Rule   string
+	Query  string
+}
+
+func (e *StatementDeniedError) Error() string {
+	return fmt.Sprintf("%v: tenant %s: rule %q", ErrStatementDenied, e.Tenant, e.Rule)
+}
+
+func (e *StatementDeniedError) Is(target error) bool {
+	return target == ErrStatementDenied
+}
+
+// guardStatement recusa o comando quando a conexão usa GuardStatements e ele
+// corresponde a alguma regra de DeniedStatements
+func (c Connection) guardStatement(query string) error {
+	if !c.guardStatements {
+		return nil
+	}
+
+	statement := normalizeStatement(query)
+	for _, rule := range DeniedStatements {
+		if rule.Match(statement) {
+			logError("Statement denied for tenant ", c.SearchPath, " by rule ", rule.Name)
+			return &StatementDeniedError{Tenant: c.SearchPath, Rule: rule.Name, Query: query}
+		}
+	}
+	return nil
+}
+
+// normalizeStatement remove os comentários, troca os literais (strings e
+// dollar quoting) por '?', reduz os espaços e converte para maiúsculas,
+// separando os comandos por "; ". Os identificadores entre aspas são mantidos
+// inteiros, para que um ; ou -- dentro deles não separe o comando.
+func normalizeStatement(query string) string {
+	var b strings.Builder
+	space := false
+	write := func(s string) {
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteString(s)
+	}
+
+	for i := 0; i < len(query); {
+		ch := query[i]
+		switch {
+		case ch == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end
+			}
+			space = true
+		case ch == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 4
+			}
+			space = true
+		case ch == '\'':
+			i, _ = skipString(query, i)
+			write("'?'")
+		case ch == '"':
+			end := skipQuoted(query, i, '"')
+			write(strings.ToUpper(query[i:end]))
+			i = end
+		case ch == '$' && dollarTag(query[i:]) != "":
+			tag := dollarTag(query[i:])
+			end := strings.Index(query[i+len(tag):], tag)
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += len(tag) + end + len(tag)
+			}
+			write("'?'")
+		case ch == ';':
+			i++
+			// Ignora o ; final e os repetidos
+			rest := strings.TrimLeft(query[i:], " \t\r\n;")
+			if rest != "" && b.Len() > 0 {
+				b.WriteByte(';')
+				space = true
+			}
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n':
+			i++
+			space = true
+		default:
+			start := i
+			for i < len(query) && !strings.ContainsRune(" \t\r\n;'\"-/$", rune(query[i])) {
+				i++
+			}
+			if i == start {
+				i++
+			}
+			write(strings.ToUpper(query[start:i]))
+		}
+	}
+
+	return b.String()
+}
+
+// skipString devolve a posição seguinte ao literal iniciado em query[start],
+// considerando as aspas repetidas e, nas strings E'...', as barras de escape.
+// closed é falso quando o literal não é fechado até o fim da query.
+func skipString(query string, start int) (end int, closed bool) {
+	escapes := start > 0 && (query[start-1] == 'E' || query[start-1] == 'e')
+	for i := start + 1; i < len(query); i++ {
+		switch {
+		case escapes && query[i] == '\\':
+			i++
+		case query[i] == '\'':
+			if i+1 < len(query) && query[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1, true
+		}
+	}
+	return len(query), false
+}
+
+// dollarTag devolve a marca de dollar quoting ($$ ou $tag$) no início de s,
+// ou "" quando s começa com um parâmetro como $1
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '$':
+			return s[:i+1]
+		case !isNamePart(s[i]) || (i == 1 && s[i] >= '0' && s[i] <= '9'):
+			return ""
+		}
+	}
+	return ""
+}
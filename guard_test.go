@@ -0,0 +1,78 @@
+package connection
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGuardStatement(t *testing.T) {
+	tests := []struct {
+		query string
+		rule  string
+	}{
+		{"SELECT * FROM orders", ""},
+		{"DELETE FROM orders WHERE id = $1", ""},
+		{"UPDATE orders SET total = 0 WHERE id = $1;", ""},
+		{"INSERT INTO orders (id) VALUES (1) ON CONFLICT (id) DO UPDATE SET total = 0", ""},
+		{"SELECT id FROM orders FOR UPDATE", ""},
+		{"DELETE FROM orders o USING items i WHERE o.id = i.order_id", ""},
+		{`DELETE FROM "where" WHERE id = 1`, ""},
+		{"UPDATE orders SET note = 'no where here' WHERE id = 1", ""},
+		{"WITH old AS (SELECT id FROM orders WHERE total = 0) DELETE FROM orders WHERE id IN (SELECT id FROM old)", ""},
+		{"drop table orders", "drop"},
+		{"TRUNCATE orders", "truncate"},
+		{"DELETE FROM orders", "delete without where"},
+		{"delete from orders -- WHERE id = 1", "delete without where"},
+		{"DELETE FROM orders /* WHERE id = 1 */", "delete without where"},
+		{"UPDATE orders SET note = 'WHERE'", "update without where"},
+		{"UPDATE orders SET total = 0", "update without where"},
+		// CTE antes do comando
+		{"WITH x AS (SELECT 1) DELETE FROM orders", "delete without where"},
+		// Comando dentro da CTE
+		{"WITH x AS (DELETE FROM orders RETURNING id) SELECT * FROM x WHERE id > 0", "delete without where"},
+		{"WITH x AS (UPDATE orders SET total = 0 RETURNING id) SELECT id FROM x", "update without where"},
+		// WHERE apenas na subquery
+		{"DELETE FROM orders USING (SELECT id FROM items WHERE qty = 0) i", "delete without where"},
+		{"UPDATE orders SET total = (SELECT sum(qty) FROM items WHERE items.order_id = 1)", "update without where"},
+		// WHERE como identificador entre aspas
+		{`DELETE FROM orders AS "WHERE"`, "delete without where"},
+		{`DELETE FROM "orders"`, "delete without where"},
+		{"EXPLAIN ANALYZE DELETE FROM orders", "delete without where"},
+		{`SET "search_path" TO other`, "session"},
+		{"set local search_path to other", "session"},
+		{"GRANT ALL ON orders TO public", "role"},
+		{"COPY orders TO PROGRAM 'curl x'", "copy program"},
+		{"SELECT 1;DELETE FROM orders WHERE id = 1", "multiple statements"},
+		{`SELECT ";" FROM orders`, ""},
+	}
+
+	conn := Connection{SearchPath: "guard-test", guardStatements: true}
+	for _, test := range tests {
+		err := conn.guardStatement(test.query)
+		var denied *StatementDeniedError
+		switch {
+		case test.rule == "" && err != nil:
+			t.Errorf("guardStatement(%q) = %v, want nil", test.query, err)
+		case test.rule != "" && (!errors.As(err, &denied) || denied.Rule != test.rule):
+			t.Errorf("guardStatement(%q) = %v, want rule %q", test.query, err, test.rule)
+		case test.rule != "" && !errors.Is(err, ErrStatementDenied):
+			t.Errorf("guardStatement(%q) is not ErrStatementDenied", test.query)
+		}
+	}
+}
+
+func TestNormalizeStatement(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"select  *\n from t -- x", "SELECT * FROM T"},
+		{"SELECT 'a;b', $$x$$ FROM t;", "SELECT '?', '?' FROM T"},
+		{`SELECT "a;b--c" FROM t`, `SELECT "A;B--C" FROM T`},
+		{"SELECT 1; select 2;", "SELECT 1; SELECT 2"},
+	}
+	for _, test := range tests {
+		if got := normalizeStatement(test.query); got != test.want {
+			t.Errorf("normalizeStatement(%q) = %q, want %q", test.query, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrCatalogNotInitialized = errors.New("catalog connection not initialized")
+
+// Prefixo do nome de serviço que identifica um tenant nas verificações de
+// saúde ("tenant:acme")
+const healthTenantPrefix = "tenant:"
+
+// Tempo máximo de cada verificação de saúde quando o contexto não define um
+// prazo menor
+var HealthCheckTimeout = 5 * time.Second
+
+// HealthStatus segue os valores do HealthCheckResponse_ServingStatus do
+// grpc_health_v1, permitindo a conversão direta entre os dois.
+type HealthStatus int32
+
+const (
+	HealthUnknown        HealthStatus = 0
+	HealthServing        HealthStatus = 1
+	HealthNotServing     HealthStatus = 2
+	HealthServiceUnknown HealthStatus = 3
+)
+
+func (s HealthStatus) String() string {
+	switch s {
+	case HealthServing:
+		return "SERVING"
+	case HealthNotServing:
+		return "NOT_SERVING"
+	case HealthServiceUnknown:
+		return "SERVICE_UNKNOWN"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// CheckHealth verifica o serviço no Manager padrão.
+func CheckHealth(ctx context.Context, service string) (HealthStatus, error) {
+	return defaultManager.CheckHealth(ctx, service)
+}
+
+// CheckHealth implementa a semântica do Check do grpc_health_v1. O serviço
+// vazio verifica o banco do catálogo e "tenant:<nome>" faz o ping no pool do
+// tenant, criando-o quando necessário. Tenants fora do catálogo e outros nomes
+// de serviço retornam HealthServiceUnknown; tenants em manutenção ou com falha
+// de conexão, HealthNotServing. O erro descreve a falha encontrada.
+func (m *Manager) CheckHealth(ctx context.Context, service string) (HealthStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+	defer cancel()
+
+	if service == "" {
+		// A verificação pode rodar antes de GetCatalogConnection ou Apply
+		if m.catalog == nil {
+			return HealthNotServing, ErrCatalogNotInitialized
+		}
+		if err := m.catalog.PingContext(ctx); err != nil {
+			return HealthNotServing, err
+		}
+		return HealthServing, nil
+	}
+
+	tenant, found := strings.CutPrefix(service, healthTenantPrefix)
+	if !found || tenant == "" {
+		return HealthServiceUnknown, nil
+	}
+
+	conn, err := m.GetTenantConnection(ctx, tenant, TenantConnectOptions{})
+	if errors.Is(err, ErrRecordNotFound) {
+		return HealthServiceUnknown, err
+	}
+	if err != nil {
+		return HealthNotServing, err
+	}
+
+	if err := conn.DB.PingContext(ctx); err != nil {
+		return HealthNotServing, err
+	}
+	return HealthServing, nil
+}
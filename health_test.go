@@ -0,0 +1,33 @@
+package connection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadinessWithoutCatalog(t *testing.T) {
+	catalog := defaultManager.catalog
+	defaultManager.catalog = nil
+	defer func() { defaultManager.catalog = catalog }()
+
+	if status, err := CheckHealth(context.Background(), ""); status != HealthNotServing || !errors.Is(err, ErrCatalogNotInitialized) {
+		t.Fatalf("CheckHealth = %v, %v; want NOT_SERVING and ErrCatalogNotInitialized", status, err)
+	}
+
+	recorder := httptest.NewRecorder()
+	ReadinessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", recorder.Code)
+	}
+	var report readinessReport
+	if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Ready || report.Catalog.Error != ErrCatalogNotInitialized.Error() {
+		t.Fatalf("report = %+v", report)
+	}
+}
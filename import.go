@@ -0,0 +1,171 @@
+package connection
+
+import (
+	"archive/tar"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lib/pq"
+)
+
+var ErrTargetNotEmpty = errors.New("import target is not empty")
+
+type ImportOptions struct {
+	// Permite importar em tabelas que já possuem registros
+	AllowNonEmpty bool
+}
+
+// ImportTenant carrega no schema do tenant um arquivo gerado por ExportTenant
+// com ExportData. As tabelas já devem existir (schema recém-provisionado) e,
+// por padrão, estar vazias. A carga é feita em uma única transação.
+func ImportTenant(ctx context.Context, tenant string, r io.Reader, opts ImportOptions) error {
+	conn, err := GetTenantConnectionWithOptions(ctx, tenant, TenantConnectOptions{})
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+
+	manifest, err := readImportManifest(tr)
+	if err != nil {
+		return err
+	}
+
+	if !opts.AllowNonEmpty {
+		for _, table := range manifest.Tables {
+			var exists bool
+			query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s)", conn.qualified(table.Name))
+			if err := conn.QueryRowContext(ctx, query).Scan(&exists); err != nil {
+				return err
+			}
+			if exists {
+				return fmt.Errorf("%w: table %s has rows", ErrTargetNotEmpty, table.Name)
+			}
+		}
+	}
+
+	tx, err := conn.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	tables := make(map[string]exportTable, len(manifest.Tables))
+	for _, table := range manifest.Tables {
+		tables[table.File] = table
+	}
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		table, found := tables[header.Name]
+		if !found {
+			return fmt.Errorf("unexpected file %s in archive", header.Name)
+		}
+		if err := importTableData(ctx, tx, conn.SearchPath, table, tr); err != nil {
+			return fmt.Errorf("importing %s: %w", table.Name, err)
+		}
+		if err := resetSequences(ctx, tx, conn, table); err != nil {
+			return fmt.Errorf("resetting sequences of %s: %w", table.Name, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func readImportManifest(tr *tar.Reader) (*exportManifest, error) {
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+	if header.Name != exportManifestName {
+		return nil, fmt.Errorf("archive must start with %s, found %s", exportManifestName, header.Name)
+	}
+
+	var manifest exportManifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", exportManifestName, err)
+	}
+	return &manifest, nil
+}
+
+func importTableData(ctx context.Context, tx *sql.Tx, schema string, table exportTable, r io.Reader) error {
+	dec := json.NewDecoder(r)
+
+	var columns []string
+	if err := dec.Decode(&columns); err != nil {
+		return err
+	}
+	if len(columns) != len(table.Columns) {
+		return fmt.Errorf("columns in file do not match the manifest")
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyInSchema(schema, table.Name, columns...))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	args := make([]interface{}, len(columns))
+	for {
+		var line []*string
+		if err := dec.Decode(&line); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if len(line) != len(columns) {
+			return fmt.Errorf("row with %d values, expected %d", len(line), len(columns))
+		}
+
+		for i, value := range line {
+			args[i] = nil
+			if value != nil {
+				args[i] = *value
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return err
+		}
+	}
+
+	// Exec sem argumentos finaliza o COPY
+	_, err = stmt.ExecContext(ctx)
+	return err
+}
+
+// Avança as sequences das colunas serial/identity para depois dos valores
+// importados, evitando conflitos nos próximos inserts.
+func resetSequences(ctx context.Context, tx *sql.Tx, conn Connection, table exportTable) error {
+	for _, column := range table.Columns {
+		var sequence sql.NullString
+		err := tx.QueryRowContext(ctx, `SELECT pg_get_serial_sequence($1, $2)`, conn.qualified(table.Name), column).Scan(&sequence)
+		if err != nil {
+			return err
+		}
+		if !sequence.Valid {
+			continue
+		}
+
+		query := fmt.Sprintf("SELECT setval($1, COALESCE(MAX(%[1]s), 1), MAX(%[1]s) IS NOT NULL) FROM %[2]s", pq.QuoteIdentifier(column), conn.qualified(table.Name))
+		if _, err := tx.ExecContext(ctx, query, sequence.String); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Nome da tabela qualificado com o schema do tenant
+func (c Connection) qualified(table string) string {
+	return pq.QuoteIdentifier(c.SearchPath) + "." + pq.QuoteIdentifier(table)
+}
@@ -0,0 +1,195 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrSuspiciousQuery = errors.New("suspicious query")
+
+// InjectionPolicy define o que fazer com as queries que parecem ter valores
+// concatenados em vez de parâmetros.
+type InjectionPolicy int
+
+const (
+	// Não verifica as queries
+	InjectionIgnore InjectionPolicy = iota
+	// Escreve a query suspeita no log e a executa normalmente
+	InjectionLog
+	// Recusa a query suspeita com SuspiciousQueryError
+	InjectionDeny
+)
+
+// SuspiciousQueryError indica uma query recusada por InjectionDeny. errors.Is
+// reconhece ErrSuspiciousQuery.
+type SuspiciousQueryError struct {
+	Tenant string
+	Reason string
+	Query  string
+}
+
+func (e *SuspiciousQueryError) Error() string {
+	return fmt.Sprintf("%v: tenant %s: %s", ErrSuspiciousQuery, e.Tenant, e.Reason)
+}
+
+func (e *SuspiciousQueryError) Is(target error) bool {
+	return target == ErrSuspiciousQuery
+}
+
+// checkInjection aplica a InjectionPolicy da conexão à query
+func (c Connection) checkInjection(query string, args []interface{}) error {
+	if c.injectionCheck == InjectionIgnore {
+		return nil
+	}
+
+	reason := injectionReason(query, len(args))
+	if reason == "" {
+		return nil
+	}
+
+	logError("Suspicious query for tenant ", c.SearchPath, " (", reason, "): ", scrubSecrets(query))
+	if c.injectionCheck == InjectionDeny {
+		return &SuspiciousQueryError{Tenant: c.SearchPath, Reason: reason, Query: query}
+	}
+	return nil
+}
+
+type sqlTokenKind int
+
+const (
+	tokenWord sqlTokenKind = iota
+	tokenLiteral
+	tokenComment
+	tokenSemicolon
+	tokenPlaceholder
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+}
+
+// injectionReason devolve o motivo pelo qual a query parece ter valores
+// concatenados, ou "" quando nada foi encontrado. É uma heurística: os sinais
+// procurados são os deixados por valores com aspas que fecham o literal
+// antes do previsto.
+func injectionReason(query string, args int) string {
+	tokens, unterminated := tokenizeSQL(query)
+	if unterminated {
+		return "unterminated literal"
+	}
+
+	placeholders := 0
+	for i, token := range tokens {
+		switch token.kind {
+		case tokenPlaceholder:
+			placeholders++
+		case tokenLiteral:
+			if i+1 < len(tokens) {
+				switch tokens[i+1].kind {
+				case tokenComment:
+					return "comment after literal"
+				case tokenSemicolon:
+					// O ; final de um único comando é aceito
+					if i+2 < len(tokens) && tokens[i+2].kind != tokenComment {
+						return "statement after literal"
+					}
+				}
+			}
+		case tokenWord:
+			if strings.EqualFold(token.text, "OR") && i+3 < len(tokens) && isTautology(tokens[i+1], tokens[i+2], tokens[i+3]) {
+				return "tautology"
+			}
+		}
+	}
+
+	if placeholders == 0 && args == 0 {
+		for _, token := range tokens {
+			// Aspas escapadas dentro do literal indicam um valor recebido de
+			// fora, escapado à mão
+			if token.kind == tokenLiteral && (strings.Contains(token.text[1:len(token.text)-1], "''") || strings.Contains(token.text, `\'`)) {
+				return "escaped quotes without placeholders"
+			}
+		}
+	}
+
+	return ""
+}
+
+// isTautology reconhece comparações sempre verdadeiras como 1=1 e 'a'='a'
+func isTautology(left, op, right sqlToken) bool {
+	if op.text != "=" || left.text != right.text {
+		return false
+	}
+	if left.kind == tokenLiteral {
+		return true
+	}
+	return left.kind == tokenWord && strings.Trim(left.text, "0123456789") == ""
+}
+
+// tokenizeSQL divide a query em palavras (inclusive identificadores entre
+// aspas), operadores, literais, comentários, parâmetros e separadores de
+// comando. unterminated indica um literal ou
+// comentário sem fechamento.
+func tokenizeSQL(query string) (tokens []sqlToken, unterminated bool) {
+	for i := 0; i < len(query); {
+		ch := query[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n':
+			i++
+		case ch == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			tokens = append(tokens, sqlToken{tokenComment, query[i : i+end]})
+			i += end
+		case ch == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return tokens, true
+			}
+			tokens = append(tokens, sqlToken{tokenComment, query[i : i+end+4]})
+			i += end + 4
+		case ch == '\'':
+			end, closed := skipString(query, i)
+			if !closed {
+				return tokens, true
+			}
+			tokens = append(tokens, sqlToken{tokenLiteral, query[i:end]})
+			i = end
+		case ch == '$' && dollarTag(query[i:]) != "":
+			tag := dollarTag(query[i:])
+			end := strings.Index(query[i+len(tag):], tag)
+			if end < 0 {
+				return tokens, true
+			}
+			tokens = append(tokens, sqlToken{tokenLiteral, "'" + query[i+len(tag):i+len(tag)+end] + "'"})
+			i += len(tag) + end + len(tag)
+		case ch == '$' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9':
+			start := i
+			for i++; i < len(query) && query[i] >= '0' && query[i] <= '9'; i++ {
+			}
+			tokens = append(tokens, sqlToken{tokenPlaceholder, query[start:i]})
+		case ch == '"':
+			// Identificador entre aspas, mantido como uma única palavra
+			end := skipQuoted(query, i, '"')
+			tokens = append(tokens, sqlToken{tokenWord, query[i:end]})
+			i = end
+		case ch == ';':
+			tokens = append(tokens, sqlToken{tokenSemicolon, ";"})
+			i++
+		case isNamePart(ch):
+			start := i
+			for i < len(query) && isNamePart(query[i]) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{tokenWord, query[start:i]})
+		default:
+			tokens = append(tokens, sqlToken{tokenWord, string(ch)})
+			i++
+		}
+	}
+	return tokens, false
+}
@@ -0,0 +1,56 @@
+package connection
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInjectionReason(t *testing.T) {
+	tests := []struct {
+		query string
+		args  int
+		want  string
+	}{
+		{"SELECT * FROM users WHERE id = $1", 1, ""},
+		{"SELECT * FROM users WHERE name = 'acme'", 0, ""},
+		{"SELECT 'a;b' FROM t;", 0, ""},
+		{"SELECT 'x'; -- fim", 0, ""},
+		{"SELECT $$it's$$ FROM t", 0, ""},
+		{`SELECT "it's" FROM t`, 0, ""},
+		{"SELECT * FROM t WHERE a = 1 OR b = 2", 0, ""},
+		{"SELECT * FROM users WHERE name = 'x' -- ' AND active", 0, "comment after literal"},
+		{"SELECT * FROM users WHERE name = 'x' /* */", 0, "comment after literal"},
+		{"SELECT * FROM users WHERE name = 'x'; DROP TABLE users", 0, "statement after literal"},
+		{"SELECT * FROM users WHERE name = 'x' OR 1=1", 0, "tautology"},
+		{"SELECT * FROM users WHERE name = 'x' or 'a' = 'a'", 0, "tautology"},
+		{"SELECT * FROM users WHERE name = 'x", 0, "unterminated literal"},
+		{"SELECT * FROM users /* aberto", 0, "unterminated literal"},
+		{"SELECT * FROM users WHERE name = 'O''Brien'", 0, "escaped quotes without placeholders"},
+		{`SELECT * FROM users WHERE name = E'O\'Brien'`, 0, "escaped quotes without placeholders"},
+		{"SELECT * FROM users WHERE name = 'O''Brien' AND id = $1", 1, ""},
+	}
+
+	for _, tt := range tests {
+		if got := injectionReason(tt.query, tt.args); got != tt.want {
+			t.Errorf("injectionReason(%q, %d) = %q, want %q", tt.query, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestCheckInjectionPolicy(t *testing.T) {
+	const query = "SELECT * FROM users WHERE name = 'x' OR 1=1"
+
+	for _, policy := range []InjectionPolicy{InjectionIgnore, InjectionLog} {
+		conn := Connection{SearchPath: "injection-test", injectionCheck: policy}
+		if err := conn.checkInjection(query, nil); err != nil {
+			t.Errorf("policy %d: checkInjection = %v, want nil", policy, err)
+		}
+	}
+
+	conn := Connection{SearchPath: "injection-test", injectionCheck: InjectionDeny}
+	err := conn.checkInjection(query, nil)
+	var suspicious *SuspiciousQueryError
+	if !errors.As(err, &suspicious) || !errors.Is(err, ErrSuspiciousQuery) || suspicious.Reason != "tautology" {
+		t.Fatalf("InjectionDeny: checkInjection = %v, want tautology SuspiciousQueryError", err)
+	}
+}
@@ -0,0 +1,34 @@
+package connection
+
+import (
+	"context"
+)
+
+// Interfaces para que os serviços dependam de abstrações e possam usar os
+// fakes do pacote connectiontest nos testes.
+
+type TenantConnector interface {
+	GetTenantConnection(ctx context.Context, tenant string, opts TenantConnectOptions) (Connection, error)
+}
+
+type CatalogProvider interface {
+	GetTenant(ctx context.Context, tenant string) (*Catalog, error)
+}
+
+// Implementações padrão, que usam o catálogo e o cache do pacote
+var (
+	DefaultConnector TenantConnector = defaultConnector{}
+	DefaultCatalog   CatalogProvider = defaultCatalog{}
+)
+
+type defaultConnector struct{}
+
+func (defaultConnector) GetTenantConnection(ctx context.Context, tenant string, opts TenantConnectOptions) (Connection, error) {
+	return GetTenantConnectionWithOptions(ctx, tenant, opts)
+}
+
+type defaultCatalog struct{}
+
+func (defaultCatalog) GetTenant(ctx context.Context, tenant string) (*Catalog, error) {
+	return GetTenantContext(ctx, tenant)
+}
@@ -0,0 +1,75 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+)
+
+type Column struct {
+	Name     string
+	DataType string
+	Nullable bool
+	Default  sql.NullString
+}
+
+// Tables retorna as tabelas do schema do tenant, em ordem alfabética.
+func (c Connection) Tables(ctx context.Context) ([]string, error) {
+	rows, err := c.QueryContext(ctx, `
+        SELECT table_name
+        FROM information_schema.tables
+		WHERE table_schema = $1 AND table_type = 'BASE TABLE'
+        ORDER BY table_name`, c.SearchPath)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+
+	return tables, rows.Err()
+}
+
+// Columns retorna as colunas da tabela no schema do tenant, na ordem em que
+// foram definidas. Retorna uma lista vazia quando a tabela não existe.
+func (c Connection) Columns(ctx context.Context, table string) ([]Column, error) {
+	rows, err := c.QueryContext(ctx, `
+        SELECT column_name, data_type, is_nullable = 'YES', column_default
+        FROM information_schema.columns
+		WHERE table_schema = $1 AND table_name = $2
+        ORDER BY ordinal_position`, c.SearchPath, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []Column
+	for rows.Next() {
+		var column Column
+		if err := rows.Scan(&column.Name, &column.DataType, &column.Nullable, &column.Default); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, rows.Err()
+}
+
+// HasTable informa se a tabela existe no schema do tenant.
+func (c Connection) HasTable(ctx context.Context, name string) (bool, error) {
+	var exists bool
+	err := c.QueryRowContext(ctx, `
+        SELECT EXISTS (
+            SELECT 1
+            FROM information_schema.tables
+			WHERE table_schema = $1 AND table_name = $2
+        )`, c.SearchPath, name).Scan(&exists)
+
+	return exists, err
+}
@@ -0,0 +1,115 @@
+package connection
+
+import (
+	"context"
+	"time"
+)
+
+// JobFunc é executada para um tenant por RunForAllTenants
+type JobFunc func(ctx context.Context, conn Connection) error
+
+// RunForAllTenants executa fn para cada tenant do catálogo do Manager padrão.
+func RunForAllTenants(ctx context.Context, jobName string, every time.Duration, fn JobFunc) error {
+	return defaultManager.RunForAllTenants(ctx, jobName, every, fn)
+}
+
+// RunForAllTenants executa fn para cada tenant do catálogo a cada intervalo,
+// até o contexto ser cancelado. Em cada tenant o job roda sob um advisory lock
+// e a última execução fica registrada na tabela _jobs do schema do tenant,
+// então várias réplicas do serviço podem chamar RunForAllTenants sem que o
+// job rode mais de uma vez por intervalo para o mesmo tenant.
+func (m *Manager) RunForAllTenants(ctx context.Context, jobName string, every time.Duration, fn JobFunc) error {
+	ticker := clock.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		m.runForAllTenants(ctx, jobName, every, fn)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+	}
+}
+
+func (m *Manager) runForAllTenants(ctx context.Context, jobName string, every time.Duration, fn JobFunc) {
+	catalogs, err := m.ListTenants(ctx)
+	if err != nil {
+		logError("Job ", jobName, ": listing tenants failed: ", err)
+		return
+	}
+
+	for _, catalog := range catalogs {
+		if ctx.Err() != nil {
+			return
+		}
+		if catalog.Maintenance {
+			continue
+		}
+
+		tenant := catalog.SchemaName
+		_, err := m.TryWithAdvisoryLock(ctx, tenant, "job:"+jobName, func() error {
+			return m.runTenantJob(ctx, tenant, jobName, every, fn)
+		})
+		if err != nil {
+			logError("Job ", jobName, " failed for tenant ", tenant, ": ", err)
+		}
+	}
+}
+
+func (m *Manager) runTenantJob(ctx context.Context, tenant, jobName string, every time.Duration, fn JobFunc) error {
+	conn, err := m.GetTenantConnection(ctx, tenant, TenantConnectOptions{})
+	if err != nil {
+		return err
+	}
+
+	jobs := conn.qualified("_jobs")
+
+	_, err = conn.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS `+jobs+` (
+            name        text PRIMARY KEY,
+            last_run_at timestamptz NOT NULL,
+            last_error  text
+        )`)
+	if err != nil {
+		return err
+	}
+
+	// Usa o relógio do banco para não depender do relógio das réplicas. A
+	// execução é registrada com o horário de início, para que a duração do job
+	// não se some ao intervalo.
+	var (
+		recent    bool
+		startedAt time.Time
+	)
+	err = conn.QueryRowContext(ctx, `
+        SELECT EXISTS (
+            SELECT 1 FROM `+jobs+`
+            WHERE name = $1 AND last_run_at > now() - make_interval(secs => $2)
+        ), now()`, jobName, every.Seconds()).Scan(&recent, &startedAt)
+	if err != nil || recent {
+		return err
+	}
+
+	start := clock.Now()
+	jobErr := fn(ctx, conn)
+
+	var lastError *string
+	if jobErr != nil {
+		message := jobErr.Error()
+		lastError = &message
+	}
+
+	_, err = conn.ExecContext(ctx, `
+        INSERT INTO `+jobs+` (name, last_run_at, last_error)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (name) DO UPDATE SET last_run_at = excluded.last_run_at, last_error = excluded.last_error`,
+		jobName, startedAt, lastError)
+	if err != nil {
+		return err
+	}
+
+	logInfo("Job ", jobName, " for tenant ", tenant, " finished in ", clock.Now().Sub(start))
+	return jobErr
+}
@@ -0,0 +1,230 @@
+package connection
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Tamanho do buffer de cada inscrição; notificações excedentes são descartadas
+const notificationBuffer = 64
+
+// Tamanho máximo do nome de um canal no Postgres (NAMEDATALEN - 1)
+const maxChannelName = 63
+
+type Notification struct {
+	Tenant  string
+	Channel string
+	Payload string
+	// PID do backend que executou o NOTIFY
+	PID int
+}
+
+// Conexão de LISTEN compartilhada pelas inscrições de um tenant. Os canais
+// permanecem em LISTEN até a conexão ser fechada, quando não restam inscrições.
+type tenantListener struct {
+	manager   *Manager
+	tenant    string
+	listener  *pq.Listener
+	listening map[string]bool
+
+	// Protege subs e channels; é o único lock usado por dispatch, para que ele
+	// nunca espere por operações de rede do listener
+	mu   sync.Mutex
+	subs map[string]map[chan Notification]struct{}
+	// Nome do canal pedido pelo chamador, por nome do canal no banco
+	channels map[string]string
+}
+
+// tenantChannel retorna o nome do canal no banco. Os canais do Postgres valem
+// para o banco inteiro, então o nome leva o tenant para que tenants com schema
+// no mesmo banco não recebam as notificações uns dos outros. Nomes com ponto,
+// que seriam ambíguos, ou longos demais usam um hash.
+func tenantChannel(tenant, channel string) string {
+	name := tenant + "." + channel
+	if len(name) <= maxChannelName && !strings.Contains(tenant, ".") && !strings.Contains(channel, ".") {
+		return name
+	}
+	sum := sha256.Sum256([]byte(tenant + "\x00" + channel))
+	return "tenant_" + hex.EncodeToString(sum[:16])
+}
+
+// Listen inscreve-se no canal de NOTIFY do tenant, no Manager padrão.
+func Listen(ctx context.Context, tenant, channel string) (<-chan Notification, error) {
+	return defaultManager.Listen(ctx, tenant, channel)
+}
+
+// Listen inscreve-se no canal de NOTIFY do tenant. Cada tenant usa uma única
+// conexão dedicada de LISTEN, fora do pool, reconectada automaticamente pelo
+// lib/pq. O canal retornado é fechado quando o contexto é cancelado;
+// notificações emitidas durante uma reconexão podem ser perdidas.
+//
+// O canal é separado por tenant (veja Notify): um NOTIFY direto no banco só é
+// entregue se usar o nome retornado por TenantChannel.
+func (m *Manager) Listen(ctx context.Context, tenant, channel string) (<-chan Notification, error) {
+	m.listenersMutex.Lock()
+	defer m.listenersMutex.Unlock()
+
+	tl, found := m.listeners[tenant]
+	if !found {
+		listener, err := m.newTenantListener(ctx, tenant)
+		if err != nil {
+			return nil, err
+		}
+		tl = m.newListenerState(tenant, listener)
+		if m.listeners == nil {
+			m.listeners = make(map[string]*tenantListener)
+		}
+		m.listeners[tenant] = tl
+		go tl.dispatch()
+	}
+
+	name := tenantChannel(tenant, channel)
+	if !tl.listening[name] {
+		if err := tl.listener.Listen(name); err != nil {
+			if len(tl.listening) == 0 {
+				tl.close()
+			}
+			return nil, err
+		}
+		tl.listening[name] = true
+	}
+
+	ch := tl.subscribe(channel)
+	go func() {
+		<-ctx.Done()
+		tl.unsubscribe(name, ch)
+	}()
+
+	return ch, nil
+}
+
+// Notify envia payload ao canal do tenant, no Manager padrão.
+func Notify(ctx context.Context, tenant, channel, payload string) error {
+	return defaultManager.Notify(ctx, tenant, channel, payload)
+}
+
+// Notify envia payload ao canal do tenant, entregue às inscrições de Listen
+// para o mesmo tenant e canal.
+func (m *Manager) Notify(ctx context.Context, tenant, channel, payload string) error {
+	conn, err := m.GetTenantConnection(ctx, tenant, TenantConnectOptions{})
+	if err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, "SELECT pg_notify($1, $2)", tenantChannel(tenant, channel), payload)
+	return err
+}
+
+// TenantChannel retorna o nome do canal do tenant no banco, para NOTIFY feitos
+// fora do pacote, como em triggers.
+func TenantChannel(tenant, channel string) string {
+	return tenantChannel(tenant, channel)
+}
+
+func (m *Manager) newListenerState(tenant string, listener *pq.Listener) *tenantListener {
+	return &tenantListener{
+		manager:   m,
+		tenant:    tenant,
+		listener:  listener,
+		listening: make(map[string]bool),
+		subs:      make(map[string]map[chan Notification]struct{}),
+		channels:  make(map[string]string),
+	}
+}
+
+func (tl *tenantListener) subscribe(channel string) chan Notification {
+	name := tenantChannel(tl.tenant, channel)
+	ch := make(chan Notification, notificationBuffer)
+
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	if tl.subs[name] == nil {
+		tl.subs[name] = make(map[chan Notification]struct{})
+	}
+	tl.subs[name][ch] = struct{}{}
+	tl.channels[name] = channel
+	return ch
+}
+
+func (m *Manager) newTenantListener(ctx context.Context, tenant string) (*pq.Listener, error) {
+	catalog, err := m.GetTenant(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	// O listener reconecta com o mesmo token, então um token expirado só é
+	// renovado quando o listener é recriado
+	if catalog, err = withAuthToken(ctx, catalog); err != nil {
+		return nil, err
+	}
+
+	dsn, dialer, err := tenantConnector(catalog, TenantConnectOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	callback := func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logError("Listener for tenant ", tenant, ": ", err)
+		}
+	}
+	if dialer != nil {
+		return pq.NewDialListener(dialer, dsn, time.Second, time.Minute, callback), nil
+	}
+	return pq.NewListener(dsn, time.Second, time.Minute, callback), nil
+}
+
+func (tl *tenantListener) dispatch() {
+	for notification := range tl.listener.Notify {
+		// nil é enviado após uma reconexão
+		if notification == nil {
+			continue
+		}
+
+		tl.mu.Lock()
+		channel := tl.channels[notification.Channel]
+		for ch := range tl.subs[notification.Channel] {
+			select {
+			case ch <- Notification{Tenant: tl.tenant, Channel: channel, Payload: notification.Extra, PID: notification.BePid}:
+			default:
+				logError("Notification dropped for tenant ", tl.tenant, " on channel ", channel, ": subscriber is not keeping up")
+			}
+		}
+		tl.mu.Unlock()
+	}
+}
+
+func (tl *tenantListener) unsubscribe(name string, ch chan Notification) {
+	tl.manager.listenersMutex.Lock()
+	defer tl.manager.listenersMutex.Unlock()
+
+	tl.mu.Lock()
+	delete(tl.subs[name], ch)
+	close(ch)
+	if len(tl.subs[name]) == 0 {
+		delete(tl.subs, name)
+		delete(tl.channels, name)
+	}
+	empty := len(tl.subs) == 0
+	tl.mu.Unlock()
+
+	if empty {
+		tl.close()
+	}
+}
+
+// Deve ser chamada com o listenersMutex do Manager
+func (tl *tenantListener) close() {
+	// Já fechado por Manager.Close
+	if tl.manager.listeners[tl.tenant] != tl {
+		return
+	}
+	delete(tl.manager.listeners, tl.tenant)
+	if err := tl.listener.Close(); err != nil {
+		logError("Listener close failed for tenant ", tl.tenant, ": ", err)
+	}
+}
@@ -0,0 +1,87 @@
+package connection
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestTenantChannel(t *testing.T) {
+	long := strings.Repeat("x", 60)
+	tests := []struct {
+		tenant, channel string
+		want            string
+	}{
+		{"acme", "orders", "acme.orders"},
+		{"globex", "orders", "globex.orders"},
+		// Ambíguos ou longos demais usam o hash
+		{"a.b", "c", "tenant_"},
+		{"a", "b.c", "tenant_"},
+		{"acme", long, "tenant_"},
+	}
+	seen := make(map[string]string)
+	for _, tt := range tests {
+		got := tenantChannel(tt.tenant, tt.channel)
+		if !strings.HasPrefix(got, tt.want) || len(got) > maxChannelName {
+			t.Errorf("tenantChannel(%q, %q) = %q, want %q", tt.tenant, tt.channel, got, tt.want)
+		}
+		key := tt.tenant + "/" + tt.channel
+		if other, found := seen[got]; found {
+			t.Errorf("tenantChannel(%q, %q) = %q, same as %s", tt.tenant, tt.channel, got, other)
+		}
+		seen[got] = key
+	}
+}
+
+func TestListenTenantsShareDatabase(t *testing.T) {
+	// Os dois tenants têm schema no mesmo banco, que entrega cada NOTIFY a
+	// todas as conexões em LISTEN no canal
+	acme := newTestManager().newListenerState("acme", &pq.Listener{Notify: make(chan *pq.Notification, 1)})
+	globex := newTestManager().newListenerState("globex", &pq.Listener{Notify: make(chan *pq.Notification, 1)})
+	acmeOrders, globexOrders := acme.subscribe("orders"), globex.subscribe("orders")
+	go acme.dispatch()
+	go globex.dispatch()
+	defer close(acme.listener.Notify)
+	defer close(globex.listener.Notify)
+
+	notify := func(channel, payload string) {
+		for _, tl := range []*tenantListener{acme, globex} {
+			tl.listener.Notify <- &pq.Notification{Channel: channel, Extra: payload}
+		}
+	}
+
+	notify(tenantChannel("acme", "orders"), "from acme")
+	select {
+	case n := <-acmeOrders:
+		if n.Tenant != "acme" || n.Channel != "orders" || n.Payload != "from acme" {
+			t.Fatalf("acme received %+v", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("acme notification not delivered")
+	}
+
+	// Um NOTIFY sem o tenant no nome não chega a nenhuma inscrição
+	notify("orders", "raw")
+	notify(tenantChannel("globex", "orders"), "from globex")
+	select {
+	case n := <-globexOrders:
+		if n.Tenant != "globex" || n.Payload != "from globex" {
+			t.Fatalf("globex received %+v", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("globex notification not delivered")
+	}
+
+	// A próxima notificação de acme é a dele, não as anteriores de globex
+	notify(tenantChannel("acme", "orders"), "last")
+	select {
+	case n := <-acmeOrders:
+		if n.Payload != "last" {
+			t.Fatalf("acme received another tenant's notification %+v", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("acme notification not delivered")
+	}
+}
@@ -0,0 +1,50 @@
+package connection
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// Níveis das mensagens que o pacote escreve no log padrão
+const (
+	// Todas as mensagens, inclusive criação de pools e jobs concluídos
+	LogLevelInfo = "info"
+	// Apenas falhas
+	LogLevelError = "error"
+	// Nenhuma mensagem
+	LogLevelOff = "off"
+)
+
+var logLevel atomic.Int32
+
+const (
+	logInfoLevel int32 = iota
+	logErrorLevel
+	logOffLevel
+)
+
+// SetLogLevel define quais mensagens do pacote são escritas no log padrão:
+// LogLevelInfo (padrão), LogLevelError ou LogLevelOff. Níveis desconhecidos
+// são ignorados.
+func SetLogLevel(level string) {
+	switch level {
+	case LogLevelInfo:
+		logLevel.Store(logInfoLevel)
+	case LogLevelError:
+		logLevel.Store(logErrorLevel)
+	case LogLevelOff:
+		logLevel.Store(logOffLevel)
+	}
+}
+
+func logInfo(v ...interface{}) {
+	if logLevel.Load() <= logInfoLevel {
+		log.Println(v...)
+	}
+}
+
+func logError(v ...interface{}) {
+	if logLevel.Load() <= logErrorLevel {
+		log.Println(v...)
+	}
+}
@@ -0,0 +1,206 @@
+package connection
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Manager agrupa o banco do catálogo, o cache de pools e as opções padrão de
+// um conjunto de tenants. As funções do pacote usam o Manager padrão,
+// configurado por Connect; NewManager cria instâncias independentes para
+// aplicações que atendem mais de um catálogo.
+type Manager struct {
+	dsn     string
+	catalog *sql.DB
+	// Réplica somente leitura do catálogo, opcional
+	replica *sql.DB
+	cache   ConnCache
+	// Serializa a gravação dos pools criados no cache com Close
+	mu *sync.Mutex
+
+	creationsMutex sync.Mutex
+	// Criações de pool em andamento, por poolKey
+	creations map[string]*poolCreation
+
+	poolsMutex sync.RWMutex
+	pools      map[string]Connection
+
+	defaultsMutex sync.RWMutex
+	defaults      TenantConnectOptions
+
+	metrics  catalogMetrics
+	snapshot catalogSnapshot
+
+	replicaLag replicaLagState
+
+	catalogColumnsMutex sync.Mutex
+	// Colunas lidas da tabela catalog; vazio até a primeira consulta
+	catalogColumnList string
+
+	failoverMutex sync.Mutex
+	// Último descarte do pool de cada tenant por failover
+	failovers map[string]time.Time
+
+	maintenanceMutex sync.RWMutex
+	// Tenants em manutenção, que recusam novas conexões
+	maintenance map[string]bool
+
+	usageMutex sync.Mutex
+	// Contadores de uso de cada tenant
+	usage map[string]*usageCounters
+
+	subscribersMutex sync.RWMutex
+	subscribers      []eventSubscriber
+	nextSubscriberID int
+
+	creationLimiter creationLimiter
+
+	overridesMutex sync.RWMutex
+	// Opções por tenant da configuração (ver LoadConfigFile)
+	overrides map[string]TenantDefaults
+
+	// Protege sharedCatalog e sharedCatalogTTL, lidos a cada busca no
+	// catálogo enquanto UseSharedCatalog pode trocá-los
+	sharedCatalogMutex sync.RWMutex
+	sharedCatalog      SharedCatalog
+	sharedCatalogTTL   time.Duration
+
+	// Serializa a criação, o LISTEN e o fechamento dos listeners
+	listenersMutex sync.Mutex
+	// Conexão de LISTEN de cada tenant
+	listeners map[string]*tenantListener
+
+	// StatementCacheSize e RequireTLS do Manager; nil usa as variáveis do
+	// pacote
+	statementCacheSize *int
+	requireTLS         *bool
+}
+
+var defaultManager = &Manager{mu: &Mutex, pools: make(map[string]Connection)}
+
+// ManagerOptions configura um Manager criado por NewManagerWithOptions.
+type ManagerOptions struct {
+	// Cache dos pools e do catálogo; nil usa um novo cache ristretto
+	Cache ConnCache
+	// Tamanho do cache de prepared statements dos pools do Manager; nil usa
+	// StatementCacheSize
+	StatementCacheSize *int
+	// Exige TLS nos pools do Manager; nil usa RequireTLS
+	RequireTLS *bool
+}
+
+// NewManager cria um Manager com banco do catálogo e cache próprios. A DSN é
+// usada como informada, sem o sslmode=disable acrescentado por Connect. O
+// estado de cada tenant (manutenção, contadores de uso, eventos, limite de
+// criação de pools e opções por tenant) é do Manager, então dois Managers
+// podem ter tenants com o mesmo nome. Listen, RotateTenantPassword,
+// CheckHealth, MoveTenant, RunForAllTenants, FlushUsage, UseSharedCatalog e
+// as demais funções do pacote com método equivalente delegam ao Manager
+// padrão; WithTwoPhaseCommit, Diagnose, AutoscalePools, os snapshots de
+// migração e DebugHandler continuam disponíveis apenas nele.
+func NewManager(catalogDSN string) (*Manager, error) {
+	return NewManagerWithOptions(catalogDSN, ManagerOptions{})
+}
+
+func NewManagerWithOptions(catalogDSN string, opts ManagerOptions) (*Manager, error) {
+	cache := opts.Cache
+	if cache == nil {
+		defaultCache, err := newRistrettoCache()
+		if err != nil {
+			return nil, err
+		}
+		cache = defaultCache
+	}
+
+	catalog, err := sql.Open("postgres", catalogDSN)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manager{
+		dsn:     catalogDSN,
+		catalog: catalog,
+		mu:      &sync.Mutex{},
+		pools:   make(map[string]Connection),
+
+		statementCacheSize: opts.StatementCacheSize,
+		requireTLS:         opts.RequireTLS,
+	}
+	m.setCache(cache)
+
+	return m, nil
+}
+
+// CatalogDB retorna o banco do catálogo do Manager.
+func (m *Manager) CatalogDB() *sql.DB {
+	return m.catalog
+}
+
+// InvalidateTenant descarta o registro do catálogo e o pool do tenant neste
+// Manager. Diferente da função do pacote, não avisa as demais instâncias;
+// para isso, use InvalidateTenantContext.
+func (m *Manager) InvalidateTenant(tenant string) {
+	m.cache.Del(prefixCatalog + tenant)
+	m.invalidateConnection(tenant)
+}
+
+// OpenTenants retorna os tenants com pool aberto neste Manager.
+func (m *Manager) OpenTenants() []string {
+	conns := m.openConnections()
+
+	tenants := make([]string, 0, len(conns))
+	seen := make(map[string]bool, len(conns))
+	for _, conn := range conns {
+		// Os pools nomeados são do mesmo tenant
+		if !seen[conn.SearchPath] {
+			seen[conn.SearchPath] = true
+			tenants = append(tenants, conn.SearchPath)
+		}
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+// Close fecha os pools abertos e o banco do catálogo do Manager. O Manager
+// não deve ser usado depois de fechado.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.poolsMutex.Lock()
+	pools := m.pools
+	m.pools = make(map[string]Connection)
+	m.poolsMutex.Unlock()
+
+	m.cache.Range(func(key string, value interface{}) bool {
+		m.cache.Del(key)
+		return true
+	})
+
+	// As inscrições de Listen deixam de receber notificações
+	m.listenersMutex.Lock()
+	for _, tl := range m.listeners {
+		tl.close()
+	}
+	m.listenersMutex.Unlock()
+
+	var errs []error
+	for _, conn := range pools {
+		if err := conn.DB.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, db := range []*sql.DB{m.catalog, m.replica} {
+		if db == nil {
+			continue
+		}
+		if err := db.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,332 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapCache é um ConnCache em memória, sem expiração, para os testes
+type mapCache struct {
+	mu    sync.Mutex
+	items map[string]interface{}
+}
+
+func (c *mapCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, found := c.items[key]
+	return value, found
+}
+
+func (c *mapCache) Set(key string, value interface{}, _ time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items[key] = value
+}
+
+func (c *mapCache) Del(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.items, key)
+}
+
+func (c *mapCache) Range(fn func(key string, value interface{}) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, value := range c.items {
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+func (c *mapCache) OnEvict(func(key string, value interface{}, expired bool)) {}
+
+// newTestManager cria um Manager sem catálogo, com cache em memória
+func newTestManager() *Manager {
+	m := &Manager{mu: &sync.Mutex{}, pools: make(map[string]Connection)}
+	m.setCache(&mapCache{items: make(map[string]interface{})})
+	return m
+}
+
+// addTestPool registra no Manager um pool sem servidor, como se tivesse sido
+// aberto por GetTenantConnection
+func (m *Manager) addTestPool(conn Connection) Connection {
+	conn.DB = sql.OpenDB(errorConnector{})
+	conn.manager = m
+	m.cache.Set(prefixConnection+conn.poolKey(), conn, 0)
+	m.trackConnection(conn)
+	return conn
+}
+
+func TestManagerTenantStateIsolated(t *testing.T) {
+	a, b := newTestManager(), newTestManager()
+	b.addTestPool(Connection{SearchPath: "acme"})
+
+	a.setMaintenance("acme", true)
+	if _, err := a.GetTenantConnection(context.Background(), "acme", TenantConnectOptions{}); !errors.Is(err, ErrTenantInMaintenance) {
+		t.Fatalf("maintenance manager error = %v, want ErrTenantInMaintenance", err)
+	}
+	if _, err := b.GetTenantConnection(context.Background(), "acme", TenantConnectOptions{}); err != nil {
+		t.Fatalf("other manager affected by maintenance: %v", err)
+	}
+
+	var eventsA, eventsB int
+	defer a.Subscribe(func(Event) { eventsA++ })()
+	defer b.Subscribe(func(Event) { eventsB++ })()
+	b.emit(EventCacheHit, "acme", "")
+	if eventsA != 0 || eventsB != 1 {
+		t.Fatalf("events a=%d b=%d, want 0 and 1", eventsA, eventsB)
+	}
+
+	a.newPoolUsage("acme").counters.queries.Add(5)
+	b.newPoolUsage("acme").counters.queries.Add(1)
+	if usage := a.UsageSnapshot(); len(usage) != 1 || usage[0].Queries != 5 {
+		t.Fatalf("usage of a = %+v, want 5 queries", usage)
+	}
+	if usage := b.UsageSnapshot(); len(usage) != 1 || usage[0].Queries != 1 {
+		t.Fatalf("usage of b = %+v, want 1 query", usage)
+	}
+
+	a.SetTenantOverrides(map[string]TenantDefaults{"acme": {MaxOpenConns: 3}})
+	if got := a.tenantOverrides("acme").MaxOpenConns; got != 3 {
+		t.Fatalf("overrides of a = %d, want 3", got)
+	}
+	if got := b.tenantOverrides("acme").MaxOpenConns; got != 0 {
+		t.Fatalf("overrides of b = %d, want 0", got)
+	}
+}
+
+func TestManagerOptionsSettings(t *testing.T) {
+	size, require := 16, true
+	m := newTestManager()
+	m.statementCacheSize, m.requireTLS = &size, &require
+
+	if got := m.stmtCacheSize(); got != 16 {
+		t.Errorf("stmtCacheSize = %d, want 16", got)
+	}
+	if got := newTestManager().stmtCacheSize(); got != StatementCacheSize {
+		t.Errorf("stmtCacheSize without option = %d, want StatementCacheSize", got)
+	}
+
+	catalog := &Catalog{SchemaName: "acme"}
+	params := url.Values{"sslmode": {"disable"}}
+	if err := checkTLS(catalog, params, TenantConnectOptions{requireTLS: m.requireTLS}); !errors.Is(err, ErrInsecureConnection) {
+		t.Errorf("checkTLS with RequireTLS option = %v, want ErrInsecureConnection", err)
+	}
+	optional := false
+	if err := checkTLS(catalog, params, TenantConnectOptions{requireTLS: &optional}); err != nil {
+		t.Errorf("checkTLS with RequireTLS disabled = %v", err)
+	}
+}
+
+func TestCreatePoolPerKey(t *testing.T) {
+	m := newTestManager()
+	blocked := make(chan struct{})
+	defer close(blocked)
+
+	// acme fica preso na criação, como um servidor fora do ar
+	go m.createPool(context.Background(), "acme", func() (Connection, error) {
+		<-blocked
+		return Connection{}, errors.New("unreachable")
+	})
+	waitCreation(m, "acme", 0)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.createPool(context.Background(), "globex", func() (Connection, error) {
+			return Connection{SearchPath: "globex"}, nil
+		})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("globex: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("globex creation waited for acme")
+	}
+
+	// Quem aguarda acme desiste no prazo do próprio contexto
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := m.createPool(ctx, "acme", func() (Connection, error) {
+		t.Error("second creation started while the first is running")
+		return Connection{}, nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("waiter error = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestCreatePoolSharesResult(t *testing.T) {
+	tests := []struct {
+		name string
+		// Erro da primeira criação
+		err error
+		// Criações esperadas: o erro de contexto de quem criava não vale
+		// para quem aguardava, que tenta novamente
+		wantCreates int
+		wantErr     error
+	}{
+		{"success", nil, 1, nil},
+		{"dial error", errUnreachable, 1, errUnreachable},
+		{"creator deadline", context.DeadlineExceeded, 2, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager()
+			release := make(chan struct{})
+			var (
+				mu      sync.Mutex
+				creates int
+			)
+			create := func() (Connection, error) {
+				mu.Lock()
+				creates++
+				first := creates == 1
+				mu.Unlock()
+				if first {
+					<-release
+					return Connection{SearchPath: "acme"}, tt.err
+				}
+				return Connection{SearchPath: "acme"}, nil
+			}
+
+			go m.createPool(context.Background(), "acme", create)
+			waitCreation(m, "acme", 0)
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := m.createPool(context.Background(), "acme", create)
+				done <- err
+			}()
+			waitCreation(m, "acme", 1)
+			close(release)
+
+			if err := <-done; !errors.Is(err, tt.wantErr) {
+				t.Fatalf("waiter error = %v, want %v", err, tt.wantErr)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if creates != tt.wantCreates {
+				t.Fatalf("create called %d times, want %d", creates, tt.wantCreates)
+			}
+		})
+	}
+}
+
+// memorySharedCatalog é um SharedCatalog em memória, sem invalidações
+type memorySharedCatalog struct {
+	mu       sync.Mutex
+	catalogs map[string]*Catalog
+}
+
+func (c *memorySharedCatalog) GetCatalog(_ context.Context, tenant string) (*Catalog, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if catalog, found := c.catalogs[tenant]; found {
+		return catalog, nil
+	}
+	return nil, ErrRecordNotFound
+}
+
+func (c *memorySharedCatalog) SetCatalog(_ context.Context, catalog *Catalog, _ time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.catalogs[catalog.SchemaName] = catalog
+	return nil
+}
+
+func (c *memorySharedCatalog) DeleteCatalog(_ context.Context, tenant string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.catalogs, tenant)
+	return nil
+}
+
+func (c *memorySharedCatalog) PublishInvalidation(context.Context, string) error {
+	return nil
+}
+
+func (c *memorySharedCatalog) SubscribeInvalidations(ctx context.Context, _ func(string)) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestManagerSharedCatalogIsolated(t *testing.T) {
+	a, b := newTestManager(), newTestManager()
+	store := &memorySharedCatalog{catalogs: map[string]*Catalog{"acme": {SchemaName: "acme", Server: "billing:5432"}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a.UseSharedCatalog(ctx, store, time.Minute)
+
+	if catalog, err := a.GetTenant(ctx, "acme"); err != nil || catalog.Server != "billing:5432" {
+		t.Fatalf("a.GetTenant = %+v, %v; want the shared catalog", catalog, err)
+	}
+	if _, found := b.sharedCatalogGet(ctx, "acme"); found {
+		t.Fatal("b read the shared catalog of a")
+	}
+
+	if err := a.InvalidateTenantContext(ctx, "acme"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetCatalog(ctx, "acme"); !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("shared catalog after invalidation: %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestManagerCatalogEventsIsolated(t *testing.T) {
+	a, b := newTestManager(), newTestManager()
+	a.handleCatalogEvent(`{"type": "maintenance", "tenant": "acme"}`)
+	if !a.inMaintenance("acme") || b.inMaintenance("acme") {
+		t.Fatalf("maintenance a=%v b=%v, want true and false", a.inMaintenance("acme"), b.inMaintenance("acme"))
+	}
+	a.handleCatalogEvent(`{"type": "resume", "tenant": "acme"}`)
+	if a.inMaintenance("acme") {
+		t.Fatal("acme still in maintenance after resume")
+	}
+}
+
+func TestManagerCheckHealth(t *testing.T) {
+	m := newTestManager()
+	if status, err := m.CheckHealth(context.Background(), ""); status != HealthNotServing || !errors.Is(err, ErrCatalogNotInitialized) {
+		t.Fatalf("CheckHealth without catalog = %v, %v", status, err)
+	}
+	m.catalog, _ = stubDB(nil)
+	defer m.catalog.Close()
+	if status, err := m.CheckHealth(context.Background(), ""); status != HealthServing || err != nil {
+		t.Fatalf("CheckHealth = %v, %v; want SERVING", status, err)
+	}
+}
+
+var errUnreachable = errors.New("unreachable")
+
+// waitCreation aguarda a criação do pool começar e ter waiters chamadas
+// aguardando
+func waitCreation(m *Manager, key string, waiters int) {
+	for {
+		m.creationsMutex.Lock()
+		creation, started := m.creations[key]
+		ready := started && creation.waiters >= waiters
+		m.creationsMutex.Unlock()
+		if ready {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
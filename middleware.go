@@ -0,0 +1,67 @@
+package connection
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+)
+
+var (
+	panicsMutex sync.Mutex
+	// Panics recuperados por RecoverMiddleware, por tenant ("" sem tenant)
+	panicCounts = make(map[string]uint64)
+)
+
+// RecoverMiddleware recupera os panics dos handlers, responde 500 e registra o
+// panic no log com o tenant e o request ID do contexto, sem credenciais. O
+// panic também é enviado ao PanicReporter, emitido como EventPanic e contado
+// em PanicCounts. Deve ficar depois do middleware que define o tenant
+// (WithRequestConnection ou ContextWithConnection), para que o tenant esteja
+// no contexto do request.
+func RecoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			// Interrupção intencional da resposta, tratada pelo net/http
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			ctx := r.Context()
+			tenant, _ := TenantFromContext(ctx)
+			msg := scrubSecrets(fmt.Sprint(v))
+			stack := []byte(scrubSecrets(string(debug.Stack())))
+
+			logError("Panic in handler for tenant ", tenant, ": ", msg, requestLogFields(ctx), "\n", string(stack))
+			if PanicReporter != nil {
+				PanicReporter(msg, stack)
+			}
+
+			panicsMutex.Lock()
+			panicCounts[tenant]++
+			panicsMutex.Unlock()
+			emit(EventPanic, tenant, msg)
+
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// PanicCounts retorna a quantidade de panics recuperados por
+// RecoverMiddleware em cada tenant; a chave "" reúne os requests sem tenant.
+func PanicCounts() map[string]uint64 {
+	panicsMutex.Lock()
+	defer panicsMutex.Unlock()
+
+	counts := make(map[string]uint64, len(panicCounts))
+	for tenant, count := range panicCounts {
+		counts[tenant] = count
+	}
+	return counts
+}
@@ -0,0 +1,213 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var ErrMigrationDirty = errors.New("dirty migration")
+
+// MigrationDirtyError indica que uma migration do tenant falhou no meio e
+// schema_migrations ficou marcada como dirty. O schema precisa ser corrigido
+// e a versão forçada (migrate force) antes de novas migrations. errors.Is
+// reconhece ErrMigrationDirty.
+type MigrationDirtyError struct {
+	Tenant  string
+	Version int64
+}
+
+func (e *MigrationDirtyError) Error() string {
+	return fmt.Sprintf("%v: tenant %s at version %d", ErrMigrationDirty, e.Tenant, e.Version)
+}
+
+func (e *MigrationDirtyError) Is(target error) bool {
+	return target == ErrMigrationDirty
+}
+
+type MigrateOptions struct {
+	// Executado com SnapshotBeforeMigration quando há migrations pendentes;
+	// nil não faz snapshot
+	Snapshot SnapshotHook
+}
+
+// migrationFile é um arquivo <versão>_<nome>.up.sql
+type migrationFile struct {
+	version int64
+	path    string
+}
+
+// MigrateTenant aplica ao schema do tenant, em ordem de versão, as migrations
+// <versão>_<nome>.up.sql de dir ainda não aplicadas. A versão fica na tabela
+// schema_migrations do schema, no formato do golang-migrate (version, dirty),
+// então as duas ferramentas podem ser usadas no mesmo tenant. Cada arquivo é
+// executado fora de transação, como no golang-migrate, e uma falha deixa a
+// versão marcada como dirty. Retorna as versões aplicadas.
+func MigrateTenant(ctx context.Context, tenant, dir string, opts MigrateOptions) ([]int64, error) {
+	files, err := readMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog, err := GetTenantContext(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	// As migrations podem alterar configurações da sessão, então rodam em um
+	// pool próprio, descartado ao final
+	db, err := openTenantDB(catalog, TenantConnectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	id := advisoryLockID(tenant, "migrate")
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, id); err != nil {
+		return nil, err
+	}
+	defer unlockAdvisory(conn, tenant, "migrate", id)
+
+	migrations := pq.QuoteIdentifier(catalog.SchemaName) + ".schema_migrations"
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+migrations+` (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)`)
+	if err != nil {
+		return nil, err
+	}
+
+	version, dirty, err := migrationState(ctx, conn, catalog.SchemaName)
+	if err != nil {
+		return nil, err
+	}
+	if dirty {
+		return nil, &MigrationDirtyError{Tenant: tenant, Version: version}
+	}
+
+	var pending []migrationFile
+	for _, file := range files {
+		if file.version > version {
+			pending = append(pending, file)
+		}
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	if opts.Snapshot != nil {
+		if _, err := SnapshotBeforeMigration(ctx, tenant, opts.Snapshot); err != nil {
+			return nil, err
+		}
+	}
+
+	var applied []int64
+	for _, file := range pending {
+		query, err := os.ReadFile(file.path)
+		if err != nil {
+			return applied, err
+		}
+
+		if err := setMigrationVersion(ctx, conn, migrations, file.version, true); err != nil {
+			return applied, err
+		}
+		if _, err := conn.ExecContext(ctx, string(query)); err != nil {
+			return applied, fmt.Errorf("migration %s of tenant %s: %w", filepath.Base(file.path), tenant, err)
+		}
+		if err := setMigrationVersion(ctx, conn, migrations, file.version, false); err != nil {
+			return applied, err
+		}
+
+		applied = append(applied, file.version)
+		logInfo("Migration ", filepath.Base(file.path), " applied to tenant ", tenant)
+	}
+	return applied, nil
+}
+
+// readMigrations lista os arquivos .up.sql de dir em ordem de versão
+func readMigrations(dir string) ([]migrationFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []migrationFile
+	seen := make(map[int64]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".up.sql") {
+			continue
+		}
+		prefix, _, _ := strings.Cut(strings.TrimSuffix(name, ".up.sql"), "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || version < 0 {
+			return nil, fmt.Errorf("migration %s: file name must start with the version number", name)
+		}
+		if previous, found := seen[version]; found {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", previous, name)
+		}
+		seen[version] = name
+		files = append(files, migrationFile{version: version, path: filepath.Join(dir, name)})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].version < files[j].version })
+	return files, nil
+}
+
+// rowQuerier é a parte de Connection e de *sql.Conn usada para ler
+// schema_migrations
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// migrationState lê a versão e a flag dirty de schema_migrations do schema,
+// ou -1 quando a tabela não existe ou está vazia
+func migrationState(ctx context.Context, q rowQuerier, schema string) (int64, bool, error) {
+	migrations := pq.QuoteIdentifier(schema) + ".schema_migrations"
+
+	var table sql.NullString
+	if err := q.QueryRowContext(ctx, `SELECT to_regclass($1)::text`, migrations).Scan(&table); err != nil {
+		return 0, false, err
+	}
+	if !table.Valid {
+		return -1, false, nil
+	}
+
+	var (
+		version int64
+		dirty   bool
+	)
+	err := q.QueryRowContext(ctx, "SELECT version, dirty FROM "+migrations+" LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return -1, false, nil
+	}
+	return version, dirty, err
+}
+
+// setMigrationVersion grava a versão como o golang-migrate: uma única linha
+func setMigrationVersion(ctx context.Context, conn *sql.Conn, migrations string, version int64, dirty bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM "+migrations); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO "+migrations+" (version, dirty) VALUES ($1, $2)", version, dirty); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,57 @@
+package connection
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		want    []int64
+		wantErr string
+	}{
+		{"empty", nil, nil, ""},
+		{
+			"sorted by version",
+			[]string{"10_orders.up.sql", "2_users.up.sql", "2_users.down.sql", "1_init.up.sql", "README.md"},
+			[]int64{1, 2, 10},
+			"",
+		},
+		{"timestamp versions", []string{"20240101120000_init.up.sql"}, []int64{20240101120000}, ""},
+		{"no version", []string{"init.up.sql"}, nil, "must start with the version"},
+		{"duplicated version", []string{"1_a.up.sql", "01_b.up.sql"}, nil, "same version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			files, err := readMigrations(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var versions []int64
+			for _, file := range files {
+				versions = append(versions, file.version)
+			}
+			if !reflect.DeepEqual(versions, tt.want) {
+				t.Fatalf("versions = %v, want %v", versions, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SnapshotHook cria um snapshot ou backup do tenant e retorna o seu
+// identificador (ID do snapshot do provedor, caminho do arquivo...).
+type SnapshotHook func(ctx context.Context, tenant string) (id string, err error)
+
+// MigrationSnapshot é o registro do snapshot feito antes das migrations de um
+// tenant, gravado na tabela catalog_migration_snapshot.
+type MigrationSnapshot struct {
+	Tenant     string `json:"tenant"`
+	SnapshotID string `json:"snapshot_id"`
+	// Versão de schema_migrations (golang-migrate) no momento do snapshot;
+	// -1 quando o schema ainda não tem migrations
+	MigrationVersion int64     `json:"migration_version"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// DumpSnapshot é o SnapshotHook embutido: grava um BackupTenant do tenant em
+// um arquivo do diretório e usa o caminho do arquivo como identificador.
+func DumpSnapshot(dir string) SnapshotHook {
+	return func(ctx context.Context, tenant string) (string, error) {
+		path := filepath.Join(dir, fmt.Sprintf("%s-%s.tar", tenant, clock.Now().UTC().Format("20060102T150405Z")))
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return "", err
+		}
+
+		if _, err := BackupTenant(ctx, tenant, f, BackupOptions{}); err != nil {
+			f.Close()
+			os.Remove(path)
+			return "", err
+		}
+		if err := f.Close(); err != nil {
+			os.Remove(path)
+			return "", err
+		}
+		return path, nil
+	}
+}
+
+// SnapshotBeforeMigration executa hook antes das migrations do tenant e
+// registra o identificador do snapshot com a versão atual das migrations no
+// catálogo, para que um rollback tenha de onde restaurar. Deve ser chamada
+// pelo executor de migrations do serviço antes de aplicá-las; um erro indica
+// que as migrations não devem prosseguir.
+func SnapshotBeforeMigration(ctx context.Context, tenant string, hook SnapshotHook) (*MigrationSnapshot, error) {
+	conn, err := GetTenantConnectionWithOptions(ctx, tenant, TenantConnectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	version, err := migrationVersion(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := hook(ctx, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("pre-migration snapshot of tenant %s: %w", tenant, err)
+	}
+
+	snapshot := &MigrationSnapshot{Tenant: tenant, SnapshotID: id, MigrationVersion: version}
+	err = defaultManager.catalog.QueryRowContext(ctx, `
+        INSERT INTO catalog_migration_snapshot (schema_name, snapshot_id, migration_version)
+        VALUES ($1, $2, $3)
+        RETURNING created_at`, tenant, id, version).Scan(&snapshot.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("recording snapshot %s of tenant %s: %w", id, tenant, err)
+	}
+
+	logInfo("Pre-migration snapshot of tenant ", tenant, " at version ", version, ": ", id)
+	return snapshot, nil
+}
+
+// LastMigrationSnapshot retorna o snapshot mais recente feito antes das
+// migrations do tenant, ou ErrRecordNotFound.
+func LastMigrationSnapshot(ctx context.Context, tenant string) (*MigrationSnapshot, error) {
+	snapshot := &MigrationSnapshot{Tenant: tenant}
+	err := defaultManager.catalog.QueryRowContext(ctx, `
+        SELECT snapshot_id, migration_version, created_at
+        FROM catalog_migration_snapshot
+        WHERE schema_name = $1
+        ORDER BY created_at DESC
+        LIMIT 1`, tenant).Scan(&snapshot.SnapshotID, &snapshot.MigrationVersion, &snapshot.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// migrationVersion lê a versão de schema_migrations do schema do tenant, ou
+// -1 quando a tabela não existe ou está vazia. Uma versão dirty, de uma
+// migration interrompida, não corresponde a um estado conhecido do schema e
+// é recusada com *MigrationDirtyError.
+func migrationVersion(ctx context.Context, conn Connection) (int64, error) {
+	version, dirty, err := migrationState(ctx, conn, conn.SearchPath)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, &MigrationDirtyError{Tenant: conn.SearchPath, Version: version}
+	}
+	return version, nil
+}
@@ -0,0 +1,40 @@
+package connection
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+func TestMigrationVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   driver.Value
+		rows    [][]driver.Value
+		want    int64
+		wantErr error
+	}{
+		{"no table", nil, nil, -1, nil},
+		{"empty table", "acme.schema_migrations", nil, -1, nil},
+		{"clean", "acme.schema_migrations", [][]driver.Value{{int64(42), false}}, 42, nil},
+		{"dirty", "acme.schema_migrations", [][]driver.Value{{int64(43), true}}, 0, ErrMigrationDirty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := stubDB(map[string]stubResult{
+				"to_regclass":           {columns: []string{"to_regclass"}, rows: [][]driver.Value{{tt.table}}},
+				"SELECT version, dirty": {columns: []string{"version", "dirty"}, rows: tt.rows},
+			})
+			defer db.Close()
+
+			got, err := migrationVersion(context.Background(), Connection{DB: db, SearchPath: "acme"})
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("version = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package connection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrTenantInMaintenance = errors.New("tenant in maintenance")
+
+// Tempo máximo que MoveTenant aguarda as queries em andamento terminarem
+var MoveDrainTimeout = 30 * time.Second
+
+func (m *Manager) inMaintenance(tenant string) bool {
+	m.maintenanceMutex.RLock()
+	defer m.maintenanceMutex.RUnlock()
+
+	return m.maintenance[tenant]
+}
+
+func (m *Manager) setMaintenance(tenant string, on bool) {
+	m.maintenanceMutex.Lock()
+	defer m.maintenanceMutex.Unlock()
+
+	if !on {
+		delete(m.maintenance, tenant)
+		return
+	}
+	if m.maintenance == nil {
+		m.maintenance = make(map[string]bool)
+	}
+	m.maintenance[tenant] = true
+}
+
+// MoveTenant troca o servidor do tenant no Manager padrão.
+func MoveTenant(ctx context.Context, tenant string, newCatalog Catalog) error {
+	return defaultManager.MoveTenant(ctx, tenant, newCatalog)
+}
+
+// MoveTenant troca o servidor do tenant: coloca o tenant em manutenção,
+// aguarda as queries em andamento, atualiza o registro no catálogo com os
+// dados de newCatalog, descarta os caches em todas as instâncias (via NOTIFY,
+// ver WatchCatalogEvents) e libera o tráfego já apontando para o novo servidor.
+// A cópia dos dados para o novo servidor deve ser feita antes.
+func (m *Manager) MoveTenant(ctx context.Context, tenant string, newCatalog Catalog) error {
+	if newCatalog.SchemaName != "" && newCatalog.SchemaName != tenant {
+		return fmt.Errorf("cannot move tenant %s to schema %s", tenant, newCatalog.SchemaName)
+	}
+	if newCatalog.Driver == "" {
+		newCatalog.Driver = "postgres"
+	}
+	if _, err := parseServer(newCatalog.Server); err != nil {
+		return err
+	}
+
+	// Os pools (o principal e os nomeados) são descartados do cache ao
+	// entrar em manutenção, mas continuam abertos pelo período de carência
+	// enquanto as queries terminam
+	conns := m.tenantConnections(tenant)
+
+	if err := m.setCatalogMaintenance(ctx, tenant, true); err != nil {
+		return err
+	}
+	if len(conns) > 0 {
+		logInfo("Tenant ", tenant, " in maintenance, draining connections")
+		drainConnections(ctx, conns)
+	}
+
+	if err := m.updateCatalog(ctx, tenant, newCatalog); err != nil {
+		// Volta a liberar o tráfego no servidor antigo
+		if resumeErr := m.setCatalogMaintenance(context.Background(), tenant, false); resumeErr != nil {
+			logError("Failed to resume tenant ", tenant, ": ", resumeErr)
+		}
+		return err
+	}
+
+	if err := m.InvalidateTenantContext(ctx, tenant); err != nil {
+		logError("Failed to invalidate shared catalog for tenant ", tenant, ": ", err)
+	}
+	m.setMaintenance(tenant, false)
+	logInfo("Tenant ", tenant, " moved to ", newCatalog.Server)
+
+	return m.notifyCatalogEvent(ctx, catalogEvent{Type: eventResume, Tenant: tenant})
+}
+
+func (m *Manager) setCatalogMaintenance(ctx context.Context, tenant string, on bool) error {
+	result, err := m.catalog.ExecContext(ctx, `UPDATE catalog SET maintenance = $1 WHERE schema_name = $2`, on, tenant)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	m.setMaintenance(tenant, on)
+	m.invalidateLocal(tenant)
+
+	eventType := eventResume
+	if on {
+		eventType = eventMaintenance
+	}
+	return m.notifyCatalogEvent(ctx, catalogEvent{Type: eventType, Tenant: tenant})
+}
+
+func (m *Manager) updateCatalog(ctx context.Context, tenant string, catalog Catalog) error {
+	var options []byte
+	if len(catalog.Options) > 0 {
+		var err error
+		if options, err = json.Marshal(catalog.Options); err != nil {
+			return err
+		}
+	}
+
+	_, err := m.catalog.ExecContext(ctx, `
+        UPDATE catalog
+        SET driver = $1, user_name = $2, password = $3, server = $4, database_name = $5,
+            region = NULLIF($6, ''), options = $7, maintenance = false
+		WHERE schema_name = $8`,
+		catalog.Driver,
+		catalog.UserName,
+		catalog.Password,
+		catalog.Server,
+		catalog.DatabaseName,
+		catalog.Region,
+		options,
+		tenant,
+	)
+	return err
+}
+
+// drainConnections aguarda até que os pools não tenham queries em andamento,
+// limitado por MoveDrainTimeout.
+func drainConnections(ctx context.Context, conns []Connection) {
+	// O prazo usa o clock do pacote, como as esperas entre as verificações
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timer := clock.AfterFunc(MoveDrainTimeout, cancel)
+	defer timer.Stop()
+
+	for _, conn := range conns {
+		for conn.DB.Stats().InUse > 0 {
+			if err := sleepContext(ctx, 100*time.Millisecond); err != nil {
+				logError("Drain timeout for pool ", conn.poolKey(), ", continuing with queries in progress")
+				return
+			}
+		}
+	}
+}
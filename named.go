@@ -0,0 +1,196 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NamedArgs são os valores dos parâmetros :nome usados em ExecNamed e QueryNamed
+type NamedArgs map[string]interface{}
+
+// ExecNamed é como ExecContext, mas com parâmetros :nome no lugar de $1, $2...
+func (c Connection) ExecNamed(ctx context.Context, query string, args NamedArgs) (sql.Result, error) {
+	query, positional, err := bindNamed(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return c.ExecContext(ctx, query, positional...)
+}
+
+// QueryNamed é como QueryContext, mas com parâmetros :nome no lugar de $1, $2...
+func (c Connection) QueryNamed(ctx context.Context, query string, args NamedArgs) (*sql.Rows, error) {
+	query, positional, err := bindNamed(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return c.QueryContext(ctx, query, positional...)
+}
+
+// bindNamed troca os parâmetros :nome por $n, repetindo o mesmo $n quando o
+// nome aparece mais de uma vez. Strings (inclusive E'...' com escapes por
+// barra invertida), identificadores entre aspas, comentários, casts (::tipo)
+// e fatias de arrays (arr[a:b]) são mantidos como estão; entre colchetes,
+// :nome só é parâmetro no início do índice, como em arr[:i]. A query não deve
+// misturar parâmetros :nome com parâmetros posicionais.
+func bindNamed(query string, args NamedArgs) (string, []interface{}, error) {
+	var (
+		out        strings.Builder
+		positional []interface{}
+		positions  = map[string]int{}
+		// Colchetes abertos (índices e fatias de arrays)
+		brackets int
+	)
+
+	for i := 0; i < len(query); {
+		ch := query[i]
+		switch {
+		case (ch == 'E' || ch == 'e') && i+1 < len(query) && query[i+1] == '\'' && (i == 0 || !isNamePart(query[i-1])):
+			end := skipEscaped(query, i+1)
+			out.WriteString(query[i:end])
+			i = end
+		case ch == '\'' || ch == '"':
+			end := skipQuoted(query, i, ch)
+			out.WriteString(query[i:end])
+			i = end
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			out.WriteString(query[i : i+end])
+			i += end
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i
+			} else {
+				end += 4
+			}
+			out.WriteString(query[i : i+end])
+			i += end
+		case ch == '$':
+			end := skipDollarQuoted(query, i)
+			out.WriteString(query[i:end])
+			i = end
+		case strings.HasPrefix(query[i:], "::"):
+			out.WriteString("::")
+			i += 2
+		case ch == '[' || ch == ']':
+			if ch == '[' {
+				brackets++
+			} else if brackets > 0 {
+				brackets--
+			}
+			out.WriteByte(ch)
+			i++
+		case ch == ':' && brackets > 0 && followsOperand(query, i):
+			// Separador da fatia, como em arr[lower:upper]
+			out.WriteByte(ch)
+			i++
+		case ch == ':' && i+1 < len(query) && isNameStart(query[i+1]):
+			end := i + 2
+			for end < len(query) && isNamePart(query[end]) {
+				end++
+			}
+			name := query[i+1 : end]
+
+			position, found := positions[name]
+			if !found {
+				value, ok := args[name]
+				if !ok {
+					return "", nil, fmt.Errorf("missing value for named parameter :%s", name)
+				}
+				positional = append(positional, value)
+				position = len(positional)
+				positions[name] = position
+			}
+			out.WriteString("$" + strconv.Itoa(position))
+			i = end
+		default:
+			out.WriteByte(ch)
+			i++
+		}
+	}
+
+	return out.String(), positional, nil
+}
+
+// skipQuoted retorna a posição após o fechamento de uma string ou
+// identificador iniciado em start, considerando aspas duplicadas como escape
+func skipQuoted(query string, start int, quote byte) int {
+	for i := start + 1; i < len(query); i++ {
+		if query[i] != quote {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(query)
+}
+
+// skipEscaped retorna a posição após o fechamento de uma string E'...'
+// iniciada em start, em que a barra invertida escapa o caractere seguinte
+func skipEscaped(query string, start int) int {
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			i++
+		case '\'':
+			if i+1 < len(query) && query[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(query)
+}
+
+// followsOperand informa se o caractere em pos, ignorando os espaços antes
+// dele, vem depois de um valor (nome, número ou expressão fechada)
+func followsOperand(query string, pos int) bool {
+	for i := pos - 1; i >= 0; i-- {
+		switch ch := query[i]; {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			continue
+		case isNamePart(ch) || ch == ')' || ch == ']' || ch == '\'' || ch == '"':
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// skipDollarQuoted trata strings no formato $tag$...$tag$. Quando o $ não
+// inicia uma delas (por exemplo, $1), avança apenas um caractere.
+func skipDollarQuoted(query string, start int) int {
+	end := start + 1
+	for end < len(query) && isNamePart(query[end]) {
+		end++
+	}
+	if end >= len(query) || query[end] != '$' || (end > start+1 && !isNameStart(query[start+1])) {
+		return start + 1
+	}
+
+	tag := query[start : end+1]
+	closing := strings.Index(query[end+1:], tag)
+	if closing < 0 {
+		return len(query)
+	}
+	return end + 1 + closing + len(tag)
+}
+
+func isNameStart(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isNamePart(ch byte) bool {
+	return isNameStart(ch) || (ch >= '0' && ch <= '9')
+}
@@ -0,0 +1,69 @@
+package connection
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBindNamed(t *testing.T) {
+	tests := []struct {
+		query string
+		args  NamedArgs
+		want  string
+		pos   []interface{}
+	}{
+		{"SELECT * FROM t WHERE id = :id", NamedArgs{"id": 1}, "SELECT * FROM t WHERE id = $1", []interface{}{1}},
+		{"SELECT * FROM t WHERE a = :x OR b = :y OR c = :x", NamedArgs{"x": 1, "y": 2}, "SELECT * FROM t WHERE a = $1 OR b = $2 OR c = $1", []interface{}{1, 2}},
+		{"SELECT :id::int", NamedArgs{"id": "7"}, "SELECT $1::int", []interface{}{"7"}},
+		{"SELECT ':id', \":id\" FROM t", nil, "SELECT ':id', \":id\" FROM t", nil},
+		{"SELECT 'it''s :id' FROM t", nil, "SELECT 'it''s :id' FROM t", nil},
+		{"SELECT $$ :id $$, $tag$ :id $tag$", nil, "SELECT $$ :id $$, $tag$ :id $tag$", nil},
+		{"SELECT 1 -- :id\n, :n", NamedArgs{"n": 2}, "SELECT 1 -- :id\n, $1", []interface{}{2}},
+		{"SELECT /* :id */ :n", NamedArgs{"n": 2}, "SELECT /* :id */ $1", []interface{}{2}},
+		{"SELECT arr[1:2] FROM t", nil, "SELECT arr[1:2] FROM t", nil},
+		{"SELECT arr[a:b], arr[lo : hi] FROM t", nil, "SELECT arr[a:b], arr[lo : hi] FROM t", nil},
+		{"SELECT arr[f(x):n] FROM t", nil, "SELECT arr[f(x):n] FROM t", nil},
+		{"SELECT arr[:i], arr[:lo:hi] FROM t", NamedArgs{"i": 1, "lo": 2}, "SELECT arr[$1], arr[$2:hi] FROM t", []interface{}{1, 2}},
+		{"SELECT E'it\\'s :x' FROM t WHERE a = :a", NamedArgs{"a": 1}, "SELECT E'it\\'s :x' FROM t WHERE a = $1", []interface{}{1}},
+		{"SELECT e'\\\\', :a", NamedArgs{"a": 1}, "SELECT e'\\\\', $1", []interface{}{1}},
+		{"SELECT E'a''b :x' FROM t", nil, "SELECT E'a''b :x' FROM t", nil},
+		{"SELECT name'x' FROM t", nil, "SELECT name'x' FROM t", nil},
+		{"SELECT :user_id2, :_x", NamedArgs{"user_id2": 1, "_x": 2}, "SELECT $1, $2", []interface{}{1, 2}},
+	}
+
+	for _, tt := range tests {
+		got, positional, err := bindNamed(tt.query, tt.args)
+		if err != nil {
+			t.Errorf("bindNamed(%q) error: %v", tt.query, err)
+			continue
+		}
+		if got != tt.want || !reflect.DeepEqual(positional, tt.pos) {
+			t.Errorf("bindNamed(%q) = %q, %v; want %q, %v", tt.query, got, positional, tt.want, tt.pos)
+		}
+	}
+}
+
+func TestBindNamedMissing(t *testing.T) {
+	if _, _, err := bindNamed("SELECT :a, :b", NamedArgs{"a": 1}); err == nil || err.Error() != "missing value for named parameter :b" {
+		t.Fatalf("error = %v, want missing :b", err)
+	}
+}
+
+func TestSkipDollarQuoted(t *testing.T) {
+	tests := []struct {
+		query string
+		start int
+		want  int
+	}{
+		{"$1 + 1", 0, 1},
+		{"$$abc$$ x", 0, 7},
+		{"$tag$a$b$tag$", 0, 13},
+		{"$tag$ unterminated", 0, 18},
+		{"$1tag$", 0, 1},
+	}
+	for _, tt := range tests {
+		if got := skipDollarQuoted(tt.query, tt.start); got != tt.want {
+			t.Errorf("skipDollarQuoted(%q, %d) = %d, want %d", tt.query, tt.start, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Códigos do Postgres para falha de autenticação
+const (
+	pqInvalidPassword      = "28P01"
+	pqInvalidAuthorization = "28000"
+)
+
+// RotateTenantPassword troca a senha do usuário do tenant no Manager padrão.
+func RotateTenantPassword(ctx context.Context, tenant, newPassword string) error {
+	return defaultManager.RotateTenantPassword(ctx, tenant, newPassword)
+}
+
+// RotateTenantPassword troca a senha do usuário do tenant no servidor e no
+// catálogo e descarta os caches em todas as instâncias. Tenants que
+// compartilham o mesmo usuário no mesmo servidor também são atualizados.
+//
+// Não há período de carência: o Postgres mantém uma única senha por usuário,
+// e a anterior deixa de valer no ALTER ROLE. As sessões já autenticadas
+// continuam válidas enquanto o pool antigo é aposentado, mas as novas
+// conexões de instâncias com a senha anterior em cache falham na
+// autenticação; a instância então recarrega o catálogo e tenta de novo. O
+// catálogo é atualizado em uma transação confirmada logo após o ALTER ROLE,
+// para que esse intervalo seja o menor possível.
+func (m *Manager) RotateTenantPassword(ctx context.Context, tenant, newPassword string) error {
+	if newPassword == "" {
+		return errors.New("empty password")
+	}
+
+	catalog, err := m.queryTenant(ctx, tenant)
+	if err != nil {
+		return err
+	}
+
+	conn, err := m.GetTenantConnection(ctx, tenant, TenantConnectOptions{})
+	if err != nil {
+		return err
+	}
+
+	// A atualização do catálogo só é confirmada depois do ALTER ROLE, e é
+	// desfeita se ele falhar
+	tx, err := m.catalog.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	tenants, err := updateCatalogPassword(ctx, tx, catalog, newPassword)
+	if err != nil {
+		return fmt.Errorf("updating catalog password: %w", err)
+	}
+
+	// ALTER ROLE não aceita parâmetros, então a senha vai como literal
+	alter := "ALTER ROLE " + pq.QuoteIdentifier(catalog.UserName) + " WITH PASSWORD "
+	if _, err := conn.DB.ExecContext(ctx, alter+pq.QuoteLiteral(newPassword)); err != nil {
+		return fmt.Errorf("altering role password: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		// Sem o catálogo atualizado, ninguém conseguiria conectar com a nova
+		// senha. Sem senha no catálogo (como na autenticação por token), não
+		// há senha anterior para restaurar.
+		if catalog.Password == "" {
+			logError("Password of tenant ", tenant, " changed but the catalog was not updated; set the password manually")
+		} else if _, revertErr := conn.DB.ExecContext(context.Background(), alter+pq.QuoteLiteral(catalog.Password)); revertErr != nil {
+			logError("Failed to revert password of tenant ", tenant, ": ", revertErr)
+		}
+		return fmt.Errorf("updating catalog password: %w", err)
+	}
+
+	for _, name := range tenants {
+		logInfo("Password rotated for tenant ", name)
+		if err := m.InvalidateTenantContext(ctx, name); err != nil {
+			logError("Failed to invalidate shared catalog for tenant ", name, ": ", err)
+		}
+		if err := m.notifyCatalogEvent(ctx, catalogEvent{Type: eventInvalidate, Tenant: name}); err != nil {
+			logError("Failed to notify invalidation of tenant ", name, ": ", err)
+		}
+	}
+
+	return nil
+}
+
+func updateCatalogPassword(ctx context.Context, tx *sql.Tx, catalog *Catalog, password string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+        UPDATE catalog SET password = $1
+        WHERE user_name = $2 AND server = $3 AND database_name = $4
+        RETURNING schema_name`,
+		password, catalog.UserName, catalog.Server, catalog.DatabaseName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []string
+	for rows.Next() {
+		var tenant string
+		if err := rows.Scan(&tenant); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, tenant)
+	}
+
+	return tenants, rows.Err()
+}
+
+func isAuthFailure(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == pqInvalidPassword || pqErr.Code == pqInvalidAuthorization
+}
+
+// forgetCatalog descarta o registro do tenant nos caches do catálogo, para
+// que a próxima busca consulte o banco
+func (m *Manager) forgetCatalog(ctx context.Context, tenant string) {
+	m.cache.Del(prefixCatalog + tenant)
+
+	if store, _ := m.currentSharedCatalog(); store != nil {
+		if err := store.DeleteCatalog(ctx, tenant); err != nil {
+			logError("Shared catalog delete failed: ", err)
+		}
+	}
+}
+
+// authFailed descarta o pool e o catálogo em cache quando novas conexões do
+// pool falham na autenticação, como após uma rotação de senha feita por outra
+// instância. A próxima chamada a GetTenantConnection cria o pool novamente.
+func (c Connection) authFailed() {
+	m := c.manager
+	if m == nil {
+		m = defaultManager
+	}
+
+	current, found := m.trackedConnection(c.poolKey())
+	if !found || current.DB != c.DB {
+		return
+	}
+
+	logError("Authentication failed for tenant ", c.SearchPath, ", discarding pool")
+	m.forgetCatalog(context.Background(), c.SearchPath)
+	m.invalidateConnection(c.SearchPath)
+}
@@ -0,0 +1,128 @@
+package connection
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strconv"
+)
+
+var ErrNoServers = errors.New("no servers available for placement")
+
+// Pontos no anel por unidade de peso de cada servidor
+const placementPointsPerWeight = 160
+
+// ServerWeight é um servidor disponível para novos tenants e o seu peso,
+// proporcional à capacidade. Peso zero ou negativo retira o servidor da
+// distribuição.
+type ServerWeight struct {
+	Server string
+	Weight int
+}
+
+// PlacementRing distribui os tenants entre os servidores com hashing
+// consistente: cada servidor ocupa pontos no anel proporcionais ao peso, e o
+// tenant fica no primeiro ponto após o hash do seu nome. Adicionar ou
+// remover um servidor muda apenas a posição dos tenants da parte do anel
+// afetada.
+type PlacementRing struct {
+	points  []uint64
+	servers map[uint64]string
+}
+
+// NewPlacementRing cria o anel com os servidores informados.
+func NewPlacementRing(servers ...ServerWeight) *PlacementRing {
+	r := &PlacementRing{servers: make(map[uint64]string)}
+	for _, server := range servers {
+		for i := 0; i < server.Weight*placementPointsPerWeight; i++ {
+			point := placementHash(server.Server + "#" + strconv.Itoa(i))
+			if _, taken := r.servers[point]; taken {
+				continue
+			}
+			r.servers[point] = server.Server
+			r.points = append(r.points, point)
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// Server retorna o servidor do tenant, ou "" quando o anel está vazio.
+func (r *PlacementRing) Server(tenant string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+
+	hash := placementHash(tenant)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.servers[r.points[i]]
+}
+
+func placementHash(value string) uint64 {
+	sum := sha256.Sum256([]byte(value))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// LoadPlacementRing cria o anel com os servidores da tabela catalog_server do
+// Manager padrão.
+func LoadPlacementRing(ctx context.Context) (*PlacementRing, error) {
+	return defaultManager.LoadPlacementRing(ctx)
+}
+
+// LoadPlacementRing cria o anel com os servidores e pesos da tabela
+// catalog_server.
+func (m *Manager) LoadPlacementRing(ctx context.Context) (*PlacementRing, error) {
+	var servers []ServerWeight
+	err := m.readCatalog(ctx, func(ctx context.Context, db *sql.DB) error {
+		servers = servers[:0]
+		rows, err := db.QueryContext(ctx, `SELECT server, weight FROM catalog_server WHERE weight > 0`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var server ServerWeight
+			if err := rows.Scan(&server.Server, &server.Weight); err != nil {
+				return err
+			}
+			servers = append(servers, server)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPlacementRing(servers...), nil
+}
+
+// ProvisionTenant escolhe o servidor do novo tenant no anel de catalog_server
+// e o cadastra com SeedTenant, no Manager padrão.
+func ProvisionTenant(ctx context.Context, catalog Catalog) (string, error) {
+	return defaultManager.ProvisionTenant(ctx, catalog)
+}
+
+// ProvisionTenant escolhe o servidor do novo tenant no anel de catalog_server,
+// quando catalog.Server está vazio, e cadastra o tenant no catálogo com
+// SeedTenant, criando o seu schema. Retorna o servidor escolhido.
+func (m *Manager) ProvisionTenant(ctx context.Context, catalog Catalog) (string, error) {
+	if catalog.Server == "" {
+		ring, err := m.LoadPlacementRing(ctx)
+		if err != nil {
+			return "", err
+		}
+		if catalog.Server = ring.Server(catalog.SchemaName); catalog.Server == "" {
+			return "", ErrNoServers
+		}
+	}
+
+	logInfo("Provisioning tenant ", catalog.SchemaName, " on server ", catalog.Server)
+	return catalog.Server, m.SeedTenant(ctx, catalog)
+}
@@ -0,0 +1,91 @@
+package connection
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func placementTenants(n int) []string {
+	tenants := make([]string, n)
+	for i := range tenants {
+		tenants[i] = fmt.Sprintf("tenant-%04d", i)
+	}
+	return tenants
+}
+
+func TestPlacementRingEmpty(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers []ServerWeight
+	}{
+		{"no servers", nil},
+		{"zero weight", []ServerWeight{{Server: "db1", Weight: 0}}},
+		{"negative weight", []ServerWeight{{Server: "db1", Weight: -2}}},
+	}
+	for _, tt := range tests {
+		if got := NewPlacementRing(tt.servers...).Server("acme"); got != "" {
+			t.Errorf("%s: Server = %q, want empty", tt.name, got)
+		}
+	}
+}
+
+func TestPlacementRingDeterministic(t *testing.T) {
+	a := NewPlacementRing(ServerWeight{"db1", 1}, ServerWeight{"db2", 1})
+	b := NewPlacementRing(ServerWeight{"db2", 1}, ServerWeight{"db1", 1})
+	for _, tenant := range placementTenants(200) {
+		if a.Server(tenant) != b.Server(tenant) {
+			t.Fatalf("tenant %s placed on %s and %s depending on server order", tenant, a.Server(tenant), b.Server(tenant))
+		}
+	}
+}
+
+func TestPlacementRingWeights(t *testing.T) {
+	tests := []struct {
+		servers []ServerWeight
+		// Fração esperada de tenants por servidor
+		want map[string]float64
+	}{
+		{[]ServerWeight{{"db1", 1}}, map[string]float64{"db1": 1}},
+		{[]ServerWeight{{"db1", 1}, {"db2", 1}}, map[string]float64{"db1": 0.5, "db2": 0.5}},
+		{[]ServerWeight{{"db1", 3}, {"db2", 1}}, map[string]float64{"db1": 0.75, "db2": 0.25}},
+		{[]ServerWeight{{"db1", 1}, {"db2", 1}, {"db3", 0}}, map[string]float64{"db1": 0.5, "db2": 0.5}},
+	}
+
+	tenants := placementTenants(4000)
+	for _, tt := range tests {
+		ring := NewPlacementRing(tt.servers...)
+		counts := make(map[string]int)
+		for _, tenant := range tenants {
+			counts[ring.Server(tenant)]++
+		}
+		for server, count := range counts {
+			share := float64(count) / float64(len(tenants))
+			if math.Abs(share-tt.want[server]) > 0.07 {
+				t.Errorf("servers %v: %s has %.2f of the tenants, want about %.2f", tt.servers, server, share, tt.want[server])
+			}
+		}
+	}
+}
+
+func TestPlacementRingMinimalMovement(t *testing.T) {
+	before := NewPlacementRing(ServerWeight{"db1", 1}, ServerWeight{"db2", 1}, ServerWeight{"db3", 1})
+	after := NewPlacementRing(ServerWeight{"db1", 1}, ServerWeight{"db2", 1}, ServerWeight{"db3", 1}, ServerWeight{"db4", 1})
+
+	tenants := placementTenants(4000)
+	moved := 0
+	for _, tenant := range tenants {
+		from, to := before.Server(tenant), after.Server(tenant)
+		if from == to {
+			continue
+		}
+		// Só podem mudar os tenants que passaram para o servidor novo
+		if to != "db4" {
+			t.Fatalf("tenant %s moved from %s to %s, want only moves to db4", tenant, from, to)
+		}
+		moved++
+	}
+	if share := float64(moved) / float64(len(tenants)); share < 0.15 || share > 0.35 {
+		t.Fatalf("%.2f of the tenants moved, want about 0.25", share)
+	}
+}
@@ -0,0 +1,70 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrPoolExhausted = errors.New("connection pool exhausted")
+
+// Tempo mínimo de uma chamada que terminou por deadline, com o pool saturado,
+// para que o erro seja reportado como PoolExhaustedError
+var PoolWaitThreshold = time.Second
+
+// PoolExhaustedError substitui o context deadline exceeded quando a chamada
+// expirou com todas as conexões do pool (MaxOpenConns) em uso, indicando que o
+// tempo foi gasto aguardando uma conexão livre. errors.Is reconhece tanto
+// ErrPoolExhausted quanto o erro original do contexto.
+type PoolExhaustedError struct {
+	Tenant string
+	Wait   time.Duration
+	Stats  sql.DBStats
+	Err    error
+}
+
+func (e *PoolExhaustedError) Error() string {
+	return fmt.Sprintf("%v: tenant %s waited %s (in use %d/%d, wait count %d, total wait %s): %v",
+		ErrPoolExhausted, e.Tenant, e.Wait, e.Stats.InUse, e.Stats.MaxOpenConnections, e.Stats.WaitCount, e.Stats.WaitDuration, e.Err)
+}
+
+func (e *PoolExhaustedError) Is(target error) bool {
+	return target == ErrPoolExhausted
+}
+
+func (e *PoolExhaustedError) Unwrap() error {
+	return e.Err
+}
+
+// queryError trata o erro de uma chamada iniciada em start: descarta o pool
+// quando a senha do tenant foi alterada, o servidor deixou de ser o primário
+// ou as conexões falham seguidamente, e anota o erro quando ele indica espera por conexão em um pool
+// saturado
+func (c Connection) queryError(start time.Time, err error) error {
+	c.countDialErrors(err)
+	if isAuthFailure(err) {
+		c.authFailed()
+		return err
+	}
+	if c.detectFailover && isFailoverError(err) {
+		c.failoverDetected(err)
+		return err
+	}
+	if err == nil || !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	wait := clock.Now().Sub(start)
+	if wait < PoolWaitThreshold {
+		return err
+	}
+
+	stats := c.DB.Stats()
+	if stats.MaxOpenConnections == 0 || stats.InUse < stats.MaxOpenConnections {
+		return err
+	}
+
+	return &PoolExhaustedError{Tenant: c.SearchPath, Wait: wait, Stats: stats, Err: err}
+}
@@ -0,0 +1,229 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrPoolOptionsMismatch = errors.New("cached pool created with different options")
+
+// PoolLimits é o tamanho de um pool nomeado (TenantConnectOptions.Pool).
+type PoolLimits struct {
+	MaxOpenConns int
+	MaxIdleConns int
+}
+
+var (
+	poolLimitsMutex sync.RWMutex
+	poolLimits      = make(map[string]PoolLimits)
+)
+
+// SetPoolLimits define o tamanho dos pools com o nome informado, usado quando
+// o chamador não informa MaxOpenConns/MaxIdleConns. Tem precedência sobre
+// SetDefaultTenantOptions e o catálogo, que valem para o pool principal.
+// Deve ser chamada na inicialização: não altera os pools já abertos.
+func SetPoolLimits(name string, limits PoolLimits) {
+	poolLimitsMutex.Lock()
+	defer poolLimitsMutex.Unlock()
+
+	poolLimits[name] = limits
+}
+
+// applyPoolLimits preenche o tamanho do pool nomeado de opts
+func applyPoolLimits(opts TenantConnectOptions) TenantConnectOptions {
+	if opts.Pool == "" {
+		return opts
+	}
+
+	poolLimitsMutex.RLock()
+	limits := poolLimits[opts.Pool]
+	poolLimitsMutex.RUnlock()
+
+	if opts.MaxOpenConns == 0 {
+		opts.MaxOpenConns = limits.MaxOpenConns
+	}
+	if opts.MaxIdleConns == 0 {
+		opts.MaxIdleConns = limits.MaxIdleConns
+	}
+	return opts
+}
+
+// ConnectOption altera as opções de GetTenantConnectionV2.
+type ConnectOption func(*connectConfig)
+
+type connectConfig struct {
+	tenant string
+	opts   TenantConnectOptions
+}
+
+// WithTenant define o tenant da conexão, no lugar do tenant do contexto.
+func WithTenant(tenant string) ConnectOption {
+	return func(c *connectConfig) {
+		c.tenant = tenant
+	}
+}
+
+// WithPool escolhe o pool nomeado, como TenantConnectOptions.Pool.
+func WithPool(name string) ConnectOption {
+	return func(c *connectConfig) {
+		c.opts.Pool = name
+	}
+}
+
+// GetTenantConnectionV2 retorna o pool do tenant do contexto (ver
+// TenantFromContext) ou de WithTenant, com as opções informadas:
+//
+//	conn, err := connection.GetTenantConnectionV2(ctx, connection.WithPool("batch"))
+//
+// Sem tenant, retorna ErrMissingTenant.
+func GetTenantConnectionV2(ctx context.Context, options ...ConnectOption) (Connection, error) {
+	var config connectConfig
+	config.tenant, _ = TenantFromContext(ctx)
+	for _, option := range options {
+		option(&config)
+	}
+	if config.tenant == "" {
+		return Connection{}, ErrMissingTenant
+	}
+	return GetTenantConnectionWithOptions(ctx, config.tenant, config.opts)
+}
+
+// PoolOptionsMismatchError indica que o pool em cache foi criado sem uma
+// opção de sessão pedida pelo chamador, como ForceUTC. errors.Is reconhece
+// ErrPoolOptionsMismatch.
+type PoolOptionsMismatchError struct {
+	Tenant  string
+	Pool    string
+	Options []string
+}
+
+func (e *PoolOptionsMismatchError) Error() string {
+	return fmt.Sprintf("%v: pool %s: %s; use a named pool (Pool) for different settings",
+		ErrPoolOptionsMismatch, poolKey(e.Tenant, e.Pool), strings.Join(e.Options, ", "))
+}
+
+func (e *PoolOptionsMismatchError) Is(target error) bool {
+	return target == ErrPoolOptionsMismatch
+}
+
+// Opções que mudam o comportamento das sessões: receber o pool em cache sem
+// elas é um erro. As demais, como o tamanho do pool, são apenas registradas
+// no log.
+var sessionPoolOptions = map[string]bool{
+	"ForceUTC":           true,
+	"SessionSettings":    true,
+	"StatementTimeout":   true,
+	"BinaryParameters":   true,
+	"TargetSessionAttrs": true,
+	"ExtraParams":        true,
+}
+
+// poolOptions são as opções aplicadas na criação do pool, já com os padrões
+// do tenant. O pool em cache é compartilhado; para configurações diferentes,
+// use um pool nomeado.
+type poolOptions struct {
+	ForceUTC           Toggle
+	SessionSettings    map[string]string
+	StatementTimeout   time.Duration
+	MaxOpenConns       int
+	MaxIdleConns       int
+	BinaryParameters   Toggle
+	KeepAlive          time.Duration
+	TargetSessionAttrs string
+	ExtraParams        map[string]string
+	ConnMaxLifetime    time.Duration
+	DialTimeout        time.Duration
+}
+
+func newPoolOptions(opts TenantConnectOptions) *poolOptions {
+	return &poolOptions{
+		ForceUTC:           opts.ForceUTC,
+		SessionSettings:    opts.SessionSettings,
+		StatementTimeout:   opts.StatementTimeout,
+		MaxOpenConns:       opts.MaxOpenConns,
+		MaxIdleConns:       opts.MaxIdleConns,
+		BinaryParameters:   opts.BinaryParameters,
+		KeepAlive:          opts.KeepAlive,
+		TargetSessionAttrs: opts.TargetSessionAttrs,
+		ExtraParams:        opts.ExtraParams,
+		ConnMaxLifetime:    opts.ConnMaxLifetime,
+		DialTimeout:        opts.DialTimeout,
+	}
+}
+
+// mismatch devolve os nomes das opções que requested define com valores
+// diferentes dos do pool. Opções com o valor zero aceitam o pool como ele é;
+// nos mapas, basta que as chaves pedidas tenham o mesmo valor no pool.
+func (p *poolOptions) mismatch(requested *poolOptions) []string {
+	var fields []string
+	pool, want := reflect.ValueOf(*p), reflect.ValueOf(*requested)
+	for i := 0; i < want.NumField(); i++ {
+		x, y := pool.Field(i), want.Field(i)
+		if y.IsZero() {
+			continue
+		}
+
+		differs := false
+		if y.Kind() == reflect.Map {
+			for iter := y.MapRange(); iter.Next(); {
+				value := x.MapIndex(iter.Key())
+				differs = differs || !value.IsValid() || value.Interface() != iter.Value().Interface()
+			}
+		} else {
+			differs = x.Interface() != y.Interface()
+		}
+		if differs {
+			fields = append(fields, want.Type().Field(i).Name)
+		}
+	}
+	return fields
+}
+
+// checkPoolOptions recusa o pool em cache quando opts pede opções de sessão
+// diferentes das usadas na sua criação. Diferenças nas demais opções são
+// registradas no log, uma vez por pool.
+func (c Connection) checkPoolOptions(opts TenantConnectOptions) error {
+	if c.poolOptions == nil {
+		return nil
+	}
+
+	var session, other []string
+	for _, field := range c.poolOptions.mismatch(newPoolOptions(opts)) {
+		if sessionPoolOptions[field] {
+			session = append(session, field)
+		} else {
+			other = append(other, field)
+		}
+	}
+	if len(session) > 0 {
+		return &PoolOptionsMismatchError{Tenant: c.SearchPath, Pool: c.pool, Options: session}
+	}
+	if len(other) > 0 && c.poolOptionsWarned.CompareAndSwap(false, true) {
+		logError("Ignoring pool options ", strings.Join(other, ", "), " for tenant ", c.SearchPath,
+			": the cached pool ", poolKey(c.SearchPath, c.pool), " was created with different values; use a named pool (Pool) for different settings")
+	}
+	return nil
+}
+
+// poolKey identifica o pool no cache: o tenant, seguido do nome nos pools
+// nomeados
+func poolKey(tenant, pool string) string {
+	if pool == "" {
+		return tenant
+	}
+	return tenant + "/" + pool
+}
+
+func (c Connection) poolKey() string {
+	return poolKey(c.SearchPath, c.pool)
+}
+
+// Pool retorna o nome do pool da conexão; vazio no pool principal.
+func (c Connection) Pool() string {
+	return c.pool
+}
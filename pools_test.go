@@ -0,0 +1,168 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolKey(t *testing.T) {
+	tests := []struct {
+		tenant, pool, want string
+	}{
+		{"acme", "", "acme"},
+		{"acme", "batch", "acme/batch"},
+		{"acme-br", "reports", "acme-br/reports"},
+	}
+	for _, tt := range tests {
+		if got := poolKey(tt.tenant, tt.pool); got != tt.want {
+			t.Errorf("poolKey(%q, %q) = %q, want %q", tt.tenant, tt.pool, got, tt.want)
+		}
+		if got := (Connection{SearchPath: tt.tenant, pool: tt.pool}).poolKey(); got != tt.want {
+			t.Errorf("Connection.poolKey() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestApplyPoolLimits(t *testing.T) {
+	SetPoolLimits("test-batch", PoolLimits{MaxOpenConns: 4, MaxIdleConns: 1})
+	defer func() {
+		poolLimitsMutex.Lock()
+		delete(poolLimits, "test-batch")
+		poolLimitsMutex.Unlock()
+	}()
+
+	tests := []struct {
+		name     string
+		opts     TenantConnectOptions
+		wantOpen int
+		wantIdle int
+	}{
+		{"main pool", TenantConnectOptions{}, 0, 0},
+		{"named pool", TenantConnectOptions{Pool: "test-batch"}, 4, 1},
+		{"caller wins", TenantConnectOptions{Pool: "test-batch", MaxOpenConns: 8}, 8, 1},
+		{"unknown pool", TenantConnectOptions{Pool: "other"}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := applyPoolLimits(tt.opts)
+			if got.MaxOpenConns != tt.wantOpen || got.MaxIdleConns != tt.wantIdle {
+				t.Fatalf("limits = %d/%d, want %d/%d", got.MaxOpenConns, got.MaxIdleConns, tt.wantOpen, tt.wantIdle)
+			}
+		})
+	}
+}
+
+func TestTenantConnections(t *testing.T) {
+	m := newTestManager()
+	m.addTestPool(Connection{SearchPath: "acme"})
+	m.addTestPool(Connection{SearchPath: "acme", pool: "batch"})
+	m.addTestPool(Connection{SearchPath: "acme-br"})
+
+	conns := m.tenantConnections("acme")
+	if len(conns) != 2 || conns[0].poolKey() != "acme" || conns[1].poolKey() != "acme/batch" {
+		t.Fatalf("tenantConnections = %v", conns)
+	}
+}
+
+func TestGetTenantConnectionV2WithoutTenant(t *testing.T) {
+	_, err := GetTenantConnectionV2(context.Background(), WithPool("batch"))
+	if !errors.Is(err, ErrMissingTenant) {
+		t.Fatalf("err = %v, want ErrMissingTenant", err)
+	}
+}
+
+func TestPoolOptionsMismatch(t *testing.T) {
+	pool := newPoolOptions(TenantConnectOptions{ForceUTC: ToggleOn, SessionSettings: map[string]string{"work_mem": "64MB", "app.tenant_id": "42"}, MaxOpenConns: 10})
+	tests := []struct {
+		name string
+		opts TenantConnectOptions
+		want string
+	}{
+		{"same", TenantConnectOptions{ForceUTC: ToggleOn, SessionSettings: map[string]string{"work_mem": "64MB", "app.tenant_id": "42"}, MaxOpenConns: 10}, ""},
+		{"no preference", TenantConnectOptions{}, ""},
+		{"run options only", TenantConnectOptions{DefaultQueryTimeout: time.Second, GuardStatements: ToggleOn}, ""},
+		{"settings subset", TenantConnectOptions{SessionSettings: map[string]string{"app.tenant_id": "42"}}, ""},
+		{"empty settings", TenantConnectOptions{SessionSettings: map[string]string{}}, ""},
+		{"statement timeout", TenantConnectOptions{StatementTimeout: time.Second, ForceUTC: ToggleOn}, "StatementTimeout"},
+		{"force utc off", TenantConnectOptions{ForceUTC: ToggleOff}, "ForceUTC"},
+		{"other setting value", TenantConnectOptions{SessionSettings: map[string]string{"app.tenant_id": "7"}}, "SessionSettings"},
+		{"missing setting", TenantConnectOptions{SessionSettings: map[string]string{"search_path": "x"}}, "SessionSettings"},
+		{"settings and size", TenantConnectOptions{SessionSettings: map[string]string{"work_mem": "1MB"}, MaxOpenConns: 2}, "SessionSettings,MaxOpenConns"},
+	}
+	for _, tt := range tests {
+		got := strings.Join(pool.mismatch(newPoolOptions(tt.opts)), ",")
+		if got != tt.want {
+			t.Errorf("%s: mismatch = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCachedConnectionPoolOptionsMismatch(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	m := newTestManager()
+	m.addTestPool(Connection{
+		SearchPath:        "pool-options-test",
+		poolOptions:       newPoolOptions(TenantConnectOptions{MaxOpenConns: 10}),
+		poolOptionsWarned: new(atomic.Bool),
+	})
+
+	if _, found, err := m.cachedConnection("pool-options-test", TenantConnectOptions{MaxOpenConns: 10}); !found || err != nil {
+		t.Fatalf("cachedConnection = %v, %v; want the cached pool", found, err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("unexpected log for equal options: %s", out.String())
+	}
+
+	// Um pool criado sem UTC não pode ser entregue a quem pediu ForceUTC
+	_, found, err := m.cachedConnection("pool-options-test", TenantConnectOptions{ForceUTC: ToggleOn})
+	var mismatch *PoolOptionsMismatchError
+	if !found || !errors.As(err, &mismatch) || !errors.Is(err, ErrPoolOptionsMismatch) || strings.Join(mismatch.Options, ",") != "ForceUTC" {
+		t.Fatalf("ForceUTC: cachedConnection error = %v, want PoolOptionsMismatchError", err)
+	}
+
+	// Diferenças de tamanho apenas são registradas, uma vez por pool
+	for i := 0; i < 2; i++ {
+		if _, _, err := m.cachedConnection("pool-options-test", TenantConnectOptions{MaxOpenConns: 2}); err != nil {
+			t.Fatalf("size mismatch returned %v", err)
+		}
+	}
+	if got := strings.Count(out.String(), "Ignoring pool options"); got != 1 || !strings.Contains(out.String(), "MaxOpenConns") {
+		t.Fatalf("mismatch logged %d times, want 1: %s", got, out.String())
+	}
+}
+
+func TestCachedConnectionSessionSettingsMismatch(t *testing.T) {
+	m := newTestManager()
+	m.addTestPool(Connection{
+		SearchPath:        "settings-test",
+		poolOptions:       newPoolOptions(TenantConnectOptions{SessionSettings: map[string]string{"app.tenant_id": "42"}}),
+		poolOptionsWarned: new(atomic.Bool),
+	})
+
+	tests := []struct {
+		name     string
+		settings map[string]string
+		wantErr  bool
+	}{
+		{"same value", map[string]string{"app.tenant_id": "42"}, false},
+		{"no settings", nil, false},
+		{"other value", map[string]string{"app.tenant_id": "7"}, true},
+		{"setting the pool lacks", map[string]string{"app.tenant_id": "42", "app.user_id": "1"}, true},
+	}
+	for _, tt := range tests {
+		_, _, err := m.cachedConnection("settings-test", TenantConnectOptions{SessionSettings: tt.settings})
+		if got := errors.Is(err, ErrPoolOptionsMismatch); got != tt.wantErr {
+			t.Errorf("%s: cachedConnection error = %v, want mismatch %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
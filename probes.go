@@ -0,0 +1,82 @@
+package connection
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// Tenants que precisam estar acessíveis para que a instância receba tráfego,
+// verificados pelo ReadinessHandler além do catálogo
+var ReadinessTenants []string
+
+type readinessCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type readinessReport struct {
+	Ready   bool                      `json:"ready"`
+	Catalog readinessCheck            `json:"catalog"`
+	Tenants map[string]readinessCheck `json:"tenants,omitempty"`
+}
+
+// ReadinessHandler responde 200 quando o catálogo e todos os
+// ReadinessTenants estão acessíveis e 503 caso contrário, com o resultado de
+// cada verificação em JSON. Para o readinessProbe do Kubernetes.
+func ReadinessHandler() http.Handler {
+	return defaultManager.ReadinessHandler()
+}
+
+// ReadinessHandler equivale ao do pacote, verificando o catálogo e os
+// ReadinessTenants neste Manager.
+func (m *Manager) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := readinessReport{Ready: true, Tenants: make(map[string]readinessCheck, len(ReadinessTenants))}
+
+		var (
+			wg sync.WaitGroup
+			mu sync.Mutex
+		)
+		check := func(service string, store func(readinessCheck)) {
+			defer wg.Done()
+
+			status, err := m.CheckHealth(r.Context(), service)
+			result := readinessCheck{Status: status.String()}
+			if err != nil {
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			store(result)
+			if status != HealthServing {
+				report.Ready = false
+			}
+		}
+
+		wg.Add(1 + len(ReadinessTenants))
+		go check("", func(result readinessCheck) { report.Catalog = result })
+		for _, tenant := range ReadinessTenants {
+			tenant := tenant
+			go check(healthTenantPrefix+tenant, func(result readinessCheck) { report.Tenants[tenant] = result })
+		}
+		wg.Wait()
+
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+// LivenessHandler responde sempre 200. A indisponibilidade do catálogo ou de
+// um tenant não é resolvida reiniciando o processo, então o livenessProbe não
+// deve depender do banco; essas falhas ficam no ReadinessHandler.
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n"))
+	})
+}
@@ -0,0 +1,297 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrNoPrimaryKey = errors.New("table has no primary key")
+
+// Tamanho padrão dos lotes de PurgeTenantData
+var PurgeBatchSize = 1000
+
+// PurgeTarget define os registros do titular em uma tabela e o que fazer com
+// eles.
+type PurgeTarget struct {
+	Table string
+	// Condição SQL que seleciona os registros do titular, com os parâmetros
+	// $1, $2... de PurgeSpec.Args (ex.: "customer_id = $1")
+	Where string
+	// Regras de mascaramento das colunas; vazio apaga os registros. A tabela
+	// precisa de chave primária para ser anonimizada.
+	Anonymize map[string]MaskRule
+}
+
+type PurgeSpec struct {
+	// Tabelas na ordem de execução (as que referenciam antes das
+	// referenciadas, quando os registros são apagados)
+	Targets []PurgeTarget
+	Args    []interface{}
+	// Registros por lote; padrão PurgeBatchSize
+	BatchSize int
+	// Pausa entre os lotes, para limitar a carga no banco
+	Delay time.Duration
+	// Motivo registrado no relatório, como o número do pedido do titular
+	Reason string
+}
+
+// PurgeTableReport é o resultado de um PurgeTarget.
+type PurgeTableReport struct {
+	Table string `json:"table"`
+	// "delete" ou "anonymize"
+	Action  string   `json:"action"`
+	Columns []string `json:"columns,omitempty"`
+	Rows    int64    `json:"rows"`
+	Batches int      `json:"batches"`
+}
+
+// PurgeReport é o relatório auditável de PurgeTenantData.
+type PurgeReport struct {
+	Tenant string `json:"tenant"`
+	Reason string `json:"reason,omitempty"`
+	// Request ID e usuário do contexto (WithRequestID, WithUserID)
+	RequestID  string             `json:"request_id,omitempty"`
+	UserID     string             `json:"user_id,omitempty"`
+	StartedAt  time.Time          `json:"started_at"`
+	FinishedAt time.Time          `json:"finished_at"`
+	Tables     []PurgeTableReport `json:"tables"`
+	Error      string             `json:"error,omitempty"`
+}
+
+// PurgeTenantData apaga ou anonimiza os registros de um titular no Manager
+// padrão.
+func PurgeTenantData(ctx context.Context, tenant string, spec PurgeSpec) (*PurgeReport, error) {
+	return defaultManager.PurgeTenantData(ctx, tenant, spec)
+}
+
+// PurgeTenantData apaga ou anonimiza os registros de um titular (direito ao
+// esquecimento, LGPD/GDPR) nas tabelas de spec, em lotes com pausas entre
+// eles. Cada lote é confirmado separadamente, então uma falha deixa os lotes
+// anteriores aplicados; o relatório, retornado também com erro, registra o
+// que foi feito e a execução pode ser repetida.
+func (m *Manager) PurgeTenantData(ctx context.Context, tenant string, spec PurgeSpec) (*PurgeReport, error) {
+	report := &PurgeReport{Tenant: tenant, Reason: spec.Reason, StartedAt: clock.Now().UTC()}
+	report.RequestID, report.UserID = requestInfo(ctx)
+
+	err := m.purgeTenantData(ctx, tenant, spec, report)
+	report.FinishedAt = clock.Now().UTC()
+	if err != nil {
+		report.Error = err.Error()
+		logError("Purge of tenant ", tenant, " failed: ", err, requestLogFields(ctx))
+		return report, err
+	}
+
+	logInfo("Purge of tenant ", tenant, " finished (", spec.Reason, ")", requestLogFields(ctx))
+	return report, nil
+}
+
+func (m *Manager) purgeTenantData(ctx context.Context, tenant string, spec PurgeSpec, report *PurgeReport) error {
+	if spec.BatchSize <= 0 {
+		spec.BatchSize = PurgeBatchSize
+	}
+
+	conn, err := m.GetTenantConnection(ctx, tenant, TenantConnectOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, target := range spec.Targets {
+		if strings.TrimSpace(target.Where) == "" {
+			return fmt.Errorf("purge of %s: empty condition", target.Table)
+		}
+		if err := (AnonymizationRules{target.Table: target.Anonymize}).check(ctx, conn); err != nil {
+			return err
+		}
+	}
+
+	for _, target := range spec.Targets {
+		table := PurgeTableReport{Table: target.Table, Action: "delete"}
+		if len(target.Anonymize) > 0 {
+			table.Action = "anonymize"
+			for column := range target.Anonymize {
+				table.Columns = append(table.Columns, column)
+			}
+			sort.Strings(table.Columns)
+		}
+
+		if table.Action == "delete" {
+			err = purgeDelete(ctx, conn, target, spec, &table)
+		} else {
+			err = purgeAnonymize(ctx, conn, target, spec, &table)
+		}
+		report.Tables = append(report.Tables, table)
+		if err != nil {
+			return fmt.Errorf("purge of %s: %w", target.Table, err)
+		}
+		logInfo("Purge of ", tenant, ".", target.Table, ": ", table.Action, " ", table.Rows, " rows")
+	}
+	return nil
+}
+
+// purgeDelete apaga os registros em lotes até a condição não selecionar mais
+// nenhum
+func purgeDelete(ctx context.Context, conn Connection, target PurgeTarget, spec PurgeSpec, report *PurgeTableReport) error {
+	table := conn.qualified(target.Table)
+	query := fmt.Sprintf("DELETE FROM %s WHERE ctid = ANY(ARRAY(SELECT ctid FROM %s WHERE (%s) LIMIT %d))",
+		table, table, target.Where, spec.BatchSize)
+
+	for {
+		result, err := conn.ExecContext(ctx, query, spec.Args...)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return nil
+		}
+		report.Rows += rows
+		report.Batches++
+
+		if rows < int64(spec.BatchSize) {
+			return nil
+		}
+		if err := sleepContext(ctx, spec.Delay); err != nil {
+			return err
+		}
+	}
+}
+
+// purgeAnonymize lê as chaves primárias dos registros e os atualiza em lotes
+// pela chave, já que os registros mascarados continuam atendendo à condição
+func purgeAnonymize(ctx context.Context, conn Connection, target PurgeTarget, spec PurgeSpec, report *PurgeTableReport) error {
+	keys, err := conn.primaryKey(ctx, target.Table)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return ErrNoPrimaryKey
+	}
+	types, err := conn.columnTypes(ctx, target.Table)
+	if err != nil {
+		return err
+	}
+
+	quotedKeys := make([]string, len(keys))
+	selects := make([]string, len(keys))
+	unnest := make([]string, len(keys))
+	casts := make([]string, len(keys))
+	for i, key := range keys {
+		quotedKeys[i] = pq.QuoteIdentifier(key)
+		selects[i] = quotedKeys[i] + "::text"
+		unnest[i] = fmt.Sprintf("$%d::text[]", i+1)
+		casts[i] = fmt.Sprintf("CAST(k.c%d AS %s)", i+1, types[key])
+	}
+
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE (%s) ORDER BY %s",
+		strings.Join(selects, ", "), conn.qualified(target.Table), target.Where, strings.Join(quotedKeys, ", ")), spec.Args...)
+	if err != nil {
+		return err
+	}
+	var values [][]string
+	for rows.Next() {
+		row := make([]string, len(keys))
+		dest := make([]interface{}, len(keys))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return err
+		}
+		values = append(values, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	columns := make([]string, 0, len(target.Anonymize))
+	for column := range target.Anonymize {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	expressions, err := (AnonymizationRules{target.Table: target.Anonymize}).selectList(ctx, conn, target.Table, columns)
+	if err != nil {
+		return err
+	}
+	assignments := make([]string, len(columns))
+	for i, column := range columns {
+		assignments[i] = pq.QuoteIdentifier(column) + " = " + expressions[i]
+	}
+
+	aliases := make([]string, len(keys))
+	for i := range aliases {
+		aliases[i] = fmt.Sprintf("c%d", i+1)
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE (%s) IN (SELECT %s FROM unnest(%s) AS k(%s))",
+		conn.qualified(target.Table), strings.Join(assignments, ", "), strings.Join(quotedKeys, ", "),
+		strings.Join(casts, ", "), strings.Join(unnest, ", "), strings.Join(aliases, ", "))
+
+	for start := 0; start < len(values); start += spec.BatchSize {
+		if start > 0 {
+			if err := sleepContext(ctx, spec.Delay); err != nil {
+				return err
+			}
+		}
+
+		end := start + spec.BatchSize
+		if end > len(values) {
+			end = len(values)
+		}
+		args := make([]interface{}, len(keys))
+		for i := range keys {
+			column := make([]string, 0, end-start)
+			for _, row := range values[start:end] {
+				column = append(column, row[i])
+			}
+			args[i] = pq.Array(column)
+		}
+
+		result, err := conn.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		report.Rows += rows
+		report.Batches++
+	}
+	return nil
+}
+
+// primaryKey devolve as colunas da chave primária da tabela, na ordem da
+// chave
+func (c Connection) primaryKey(ctx context.Context, table string) ([]string, error) {
+	rows, err := c.QueryContext(ctx, `
+        SELECT a.attname
+        FROM pg_index i
+        CROSS JOIN LATERAL unnest(i.indkey) WITH ORDINALITY AS k(attnum, position)
+        JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+        WHERE i.indrelid = $1::regclass AND i.indisprimary
+        ORDER BY k.position`, c.qualified(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
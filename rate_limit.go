@@ -0,0 +1,71 @@
+package connection
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Quantidade máxima de pools de tenant criados por segundo. Após um deploy,
+// com o cache vazio, evita que centenas de tenants reconectem ao mesmo tempo
+// e estourem o max_connections do Postgres. As criações excedentes aguardam
+// na fila, respeitando o SetupTimeout. Quando zero, não há limite.
+var MaxPoolCreationsPerSecond = 0.0
+
+type PoolCreationStats struct {
+	// Criações aguardando na fila neste momento
+	Queued int64 `json:"queued"`
+	// Total de criações que precisaram aguardar
+	Delayed uint64 `json:"delayed"`
+}
+
+type creationLimiter struct {
+	mu   sync.Mutex
+	next time.Time
+
+	queued  atomic.Int64
+	delayed atomic.Uint64
+}
+
+// wait reserva o próximo horário livre e aguarda até ele. O horário reservado
+// não é devolvido quando o contexto é cancelado.
+func (l *creationLimiter) wait(ctx context.Context) error {
+	rate := MaxPoolCreationsPerSecond
+	if rate <= 0 {
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / rate)
+
+	l.mu.Lock()
+	now := clock.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(interval)
+	l.mu.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+
+	l.queued.Add(1)
+	defer l.queued.Add(-1)
+	l.delayed.Add(1)
+
+	ready := make(chan struct{})
+	timer := clock.AfterFunc(delay, func() { close(ready) })
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		timer.Stop()
+		return ctx.Err()
+	}
+}
+
+func (l *creationLimiter) stats() PoolCreationStats {
+	return PoolCreationStats{Queued: l.queued.Load(), Delayed: l.delayed.Load()}
+}
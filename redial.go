@@ -0,0 +1,46 @@
+package connection
+
+import (
+	"errors"
+	"net"
+)
+
+// isDialError indica falhas ao abrir a conexão de rede com o servidor,
+// inclusive na resolução do nome
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+// countDialErrors conta as falhas de conexão consecutivas do pool e o
+// descarta ao atingir DialErrorThreshold. As conexões abertas continuam
+// presas ao IP antigo quando o servidor do tenant muda atrás do mesmo nome;
+// o pool novo resolve o nome novamente.
+func (c Connection) countDialErrors(err error) {
+	if c.dialErrors == nil || c.dialErrorThreshold <= 0 {
+		return
+	}
+	if !isDialError(err) {
+		c.dialErrors.Store(0)
+		return
+	}
+	if c.dialErrors.Add(1) != int64(c.dialErrorThreshold) {
+		return
+	}
+
+	m := c.manager
+	if m == nil {
+		m = defaultManager
+	}
+	current, found := m.trackedConnection(c.poolKey())
+	if !found || current.DB != c.DB {
+		return
+	}
+
+	logError("Connection to tenant ", c.SearchPath, " failed ", c.dialErrorThreshold, " times in a row, discarding pool: ", err)
+	m.invalidateConnection(c.SearchPath)
+}
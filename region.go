@@ -0,0 +1,71 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// regionalCatalog devolve o catálogo apontando para a réplica do tenant na
+// região informada, ou o próprio catálogo quando não há réplica nessa região.
+func (m *Manager) regionalCatalog(ctx context.Context, catalog *Catalog, region string) (*Catalog, error) {
+	if region == "" || region == catalog.Region {
+		return catalog, nil
+	}
+	if m.replicaLag.excluded(catalog.SchemaName, region) {
+		logInfo("Replica in region ", region, " for tenant ", catalog.SchemaName, " excluded by replication lag, using primary region ", catalog.Region)
+		return catalog, nil
+	}
+
+	replica, err := m.replicaCatalog(ctx, catalog, region)
+	if errors.Is(err, sql.ErrNoRows) {
+		logInfo("No replica in region ", region, " for tenant ", catalog.SchemaName, ", using primary region ", catalog.Region)
+		return catalog, nil
+	}
+	return replica, err
+}
+
+// replicaCatalog monta o catálogo da réplica do tenant na região a partir de
+// catalog_replica, ou retorna sql.ErrNoRows quando não há réplica nela
+func (m *Manager) replicaCatalog(ctx context.Context, catalog *Catalog, region string) (*Catalog, error) {
+	query := `
+        SELECT server, COALESCE(options, '{}')
+        FROM catalog_replica
+		WHERE schema_name = $1 AND region = $2`
+
+	var (
+		server  string
+		options []byte
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+
+	defer cancel()
+
+	err := m.readCatalog(ctx, func(ctx context.Context, db *sql.DB) error {
+		return db.QueryRowContext(ctx, query, catalog.SchemaName, region).Scan(&server, &options)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	replicaOptions, err := parseCatalogOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	replica := *catalog
+	replica.Server = server
+	replica.Region = region
+	replica.readReplica = true
+	replica.Options = make(map[string]string, len(catalog.Options)+len(replicaOptions))
+	for key, value := range catalog.Options {
+		replica.Options[key] = value
+	}
+	for key, value := range replicaOptions {
+		replica.Options[key] = value
+	}
+
+	return &replica, nil
+}
@@ -0,0 +1,130 @@
+package connection
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ConfigLoader lê a configuração a cada recarga, como LoadConfigFromEnv ou
+// uma função que chama LoadConfig com o caminho do arquivo.
+type ConfigLoader func() (Config, error)
+
+var (
+	configMutex       sync.Mutex
+	currentConfig     Config
+	configSubscribers []func(old, new Config)
+)
+
+// OnConfigChange registra fn, chamada após cada recarga que altera a
+// configuração.
+func OnConfigChange(fn func(old, new Config)) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	configSubscribers = append(configSubscribers, fn)
+}
+
+// ReloadConfig lê a configuração com load e aplica as alterações sem
+// reiniciar a aplicação: nível de log, TLS obrigatório, limite de queries
+// lentas, opções por tenant e os padrões de tamanho e tempo dos pools, que
+// valem para os pools criados depois. A troca do catálogo ou da sua réplica
+// exige reiniciar a aplicação e é ignorada. Com erro na leitura, a
+// configuração atual é mantida.
+func ReloadConfig(load ConfigLoader) error {
+	cfg, err := load()
+	if err != nil {
+		return err
+	}
+
+	configMutex.Lock()
+	old := currentConfig
+	if old.CatalogURL != "" && cfg.CatalogURL != old.CatalogURL {
+		logError("Catalog URL change ignored, restart required")
+		cfg.CatalogURL = old.CatalogURL
+	}
+	if cfg.CatalogReplicaURL != old.CatalogReplicaURL {
+		logError("Catalog replica URL change ignored, restart required")
+		cfg.CatalogReplicaURL = old.CatalogReplicaURL
+	}
+	if reflect.DeepEqual(old, cfg) {
+		configMutex.Unlock()
+		return nil
+	}
+
+	cfg.applySettings()
+	currentConfig = cfg
+	subscribers := append([]func(old, new Config){}, configSubscribers...)
+	configMutex.Unlock()
+
+	logInfo("Configuration reloaded")
+	for _, fn := range subscribers {
+		fn(old, cfg)
+	}
+
+	return nil
+}
+
+// ReloadOnSignal recarrega a configuração a cada SIGHUP recebido, até o
+// contexto ser cancelado.
+func ReloadOnSignal(ctx context.Context, load ConfigLoader) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(signals)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				if err := ReloadConfig(load); err != nil {
+					logError("Configuration reload failed: ", err)
+				}
+			}
+		}
+	}()
+}
+
+// WatchConfigFile verifica a cada interval se o arquivo de configuração foi
+// alterado e, nesse caso, o recarrega com LoadConfig, até o contexto ser
+// cancelado.
+func WatchConfigFile(ctx context.Context, path string, interval time.Duration) {
+	load := func() (Config, error) { return LoadConfig(path) }
+
+	var modified time.Time
+	if info, err := os.Stat(path); err == nil {
+		modified = info.ModTime()
+	}
+
+	go func() {
+		ticker := clock.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				info, err := os.Stat(path)
+				if err != nil {
+					logError("Configuration file check failed: ", err)
+					continue
+				}
+				if info.ModTime().Equal(modified) {
+					continue
+				}
+				modified = info.ModTime()
+
+				if err := ReloadConfig(load); err != nil {
+					logError("Configuration reload failed: ", err)
+				}
+			}
+		}
+	}()
+}
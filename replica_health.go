@@ -0,0 +1,147 @@
+package connection
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Peso das novas medições nas médias móveis de latência e de erros das
+// réplicas
+var ReplicaHealthDecay = 0.2
+
+// Latência atribuída a uma conexão que falhou, para que um host que recusa
+// conexões rapidamente não pareça mais rápido que as réplicas saudáveis
+var ReplicaDialFailureLatency = 5 * time.Second
+
+// replicaHealth acompanha a latência de conexão e a taxa de erros de um host
+// de réplica
+type replicaHealth struct {
+	// Médias móveis exponenciais
+	latency   float64
+	errorRate float64
+	dials     uint64
+	errors    uint64
+}
+
+var (
+	replicaHealthMutex sync.Mutex
+	replicaHealths     = make(map[string]*replicaHealth)
+)
+
+// ReplicaStats é o estado de um host de réplica usado na escolha das novas
+// conexões.
+type ReplicaStats struct {
+	Address string `json:"address"`
+	// Peso relativo entre os hosts, de 0 a 1
+	Weight         float64 `json:"weight"`
+	LatencySeconds float64 `json:"latency_seconds"`
+	ErrorRate      float64 `json:"error_rate"`
+	Dials          uint64  `json:"dials"`
+	Errors         uint64  `json:"errors"`
+}
+
+// recordReplicaDial registra o resultado de uma conexão com o host
+func recordReplicaDial(address string, elapsed time.Duration, err error) {
+	replicaHealthMutex.Lock()
+	defer replicaHealthMutex.Unlock()
+
+	failed := 0.0
+	if err != nil {
+		failed = 1
+		if elapsed < ReplicaDialFailureLatency {
+			elapsed = ReplicaDialFailureLatency
+		}
+	}
+
+	health, found := replicaHealths[address]
+	if !found {
+		// A primeira medição inicia as médias
+		health = &replicaHealth{latency: elapsed.Seconds(), errorRate: failed}
+		replicaHealths[address] = health
+	} else {
+		health.latency += ReplicaHealthDecay * (elapsed.Seconds() - health.latency)
+		health.errorRate += ReplicaHealthDecay * (failed - health.errorRate)
+	}
+	health.dials++
+	if err != nil {
+		health.errors++
+	}
+}
+
+// weight favorece os hosts com menos erros e menor latência. Um host que só
+// falha mantém uma fração do peso, para voltar a ser tentado e se recuperar.
+// Hosts sem medições recebem o maior peso, para serem medidos.
+func (h *replicaHealth) weight() float64 {
+	if h == nil {
+		return 1000
+	}
+	latency := h.latency
+	if latency < 0.001 {
+		latency = 0.001
+	}
+	return (1 - 0.9*h.errorRate) / latency
+}
+
+// weightedOrder devolve os endereços em ordem de tentativa, sorteados
+// proporcionalmente ao peso de cada um
+func weightedOrder(addresses []string) []string {
+	replicaHealthMutex.Lock()
+	weights := make([]float64, len(addresses))
+	for i, address := range addresses {
+		weights[i] = replicaHealths[address].weight()
+	}
+	replicaHealthMutex.Unlock()
+
+	remaining := append([]string(nil), addresses...)
+	ordered := make([]string, 0, len(addresses))
+	for len(remaining) > 0 {
+		total := 0.0
+		for _, w := range weights {
+			total += w
+		}
+
+		i, pick := 0, rand.Float64()*total
+		for ; i < len(weights)-1; i++ {
+			if pick -= weights[i]; pick < 0 {
+				break
+			}
+		}
+
+		ordered = append(ordered, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+		weights = append(weights[:i], weights[i+1:]...)
+	}
+	return ordered
+}
+
+// ReplicaWeights retorna o estado dos hosts das réplicas usadas com
+// PreferredRegion, com o peso de cada um normalizado pelo maior peso.
+func ReplicaWeights() []ReplicaStats {
+	replicaHealthMutex.Lock()
+	defer replicaHealthMutex.Unlock()
+
+	stats := make([]ReplicaStats, 0, len(replicaHealths))
+	highest := 0.0
+	for address, health := range replicaHealths {
+		weight := health.weight()
+		if weight > highest {
+			highest = weight
+		}
+		stats = append(stats, ReplicaStats{
+			Address:        address,
+			Weight:         weight,
+			LatencySeconds: health.latency,
+			ErrorRate:      health.errorRate,
+			Dials:          health.dials,
+			Errors:         health.errors,
+		})
+	}
+	for i := range stats {
+		stats[i].Weight /= highest
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Address < stats[j].Address })
+	return stats
+}
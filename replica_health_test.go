@@ -0,0 +1,115 @@
+package connection
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetReplicaHealth remove as medições dos endereços ao final do teste
+func resetReplicaHealth(t *testing.T, addresses ...string) {
+	t.Cleanup(func() {
+		replicaHealthMutex.Lock()
+		defer replicaHealthMutex.Unlock()
+		for _, address := range addresses {
+			delete(replicaHealths, address)
+		}
+	})
+}
+
+func TestReplicaHealthWeight(t *testing.T) {
+	tests := []struct {
+		name   string
+		health *replicaHealth
+		want   float64
+	}{
+		{"unmeasured", nil, 1000},
+		{"10ms healthy", &replicaHealth{latency: 0.01}, 100},
+		{"100ms healthy", &replicaHealth{latency: 0.1}, 10},
+		{"10ms half errors", &replicaHealth{latency: 0.01, errorRate: 0.5}, 55},
+		{"always failing keeps a fraction", &replicaHealth{latency: 0.01, errorRate: 1}, 10},
+		{"latency floor", &replicaHealth{latency: 0}, 1000},
+	}
+	for _, tt := range tests {
+		if got := tt.health.weight(); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: weight = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecordReplicaDial(t *testing.T) {
+	const address = "weights-test-record:5432"
+	resetReplicaHealth(t, address)
+
+	recordReplicaDial(address, 100*time.Millisecond, nil)
+	recordReplicaDial(address, 200*time.Millisecond, nil)
+	recordReplicaDial(address, time.Millisecond, errors.New("refused"))
+
+	replicaHealthMutex.Lock()
+	health := *replicaHealths[address]
+	replicaHealthMutex.Unlock()
+
+	// A falha conta como ReplicaDialFailureLatency, mesmo sendo rápida
+	wantLatency := 0.1 + ReplicaHealthDecay*(0.2-0.1)
+	wantLatency += ReplicaHealthDecay * (ReplicaDialFailureLatency.Seconds() - wantLatency)
+	wantErrors := ReplicaHealthDecay * 1
+	if math.Abs(health.latency-wantLatency) > 1e-9 || math.Abs(health.errorRate-wantErrors) > 1e-9 {
+		t.Fatalf("latency %v error rate %v, want %v and %v", health.latency, health.errorRate, wantLatency, wantErrors)
+	}
+	if health.dials != 3 || health.errors != 1 {
+		t.Fatalf("dials %d errors %d, want 3 and 1", health.dials, health.errors)
+	}
+}
+
+func TestWeightedOrder(t *testing.T) {
+	fast, slow, failing, fresh := "weights-test-fast:5432", "weights-test-slow:5432", "weights-test-failing:5432", "weights-test-fresh:5432"
+	healthy, refusing := "weights-test-healthy:5432", "weights-test-refusing:5432"
+	resetReplicaHealth(t, fast, slow, failing, fresh, healthy, refusing)
+	recordReplicaDial(fast, 10*time.Millisecond, nil)
+	recordReplicaDial(slow, 500*time.Millisecond, nil)
+	recordReplicaDial(failing, 5*time.Millisecond, errors.New("refused"))
+	// Um host que recusa conexões em 0,5ms contra uma réplica saudável de 20ms
+	for i := 0; i < 5; i++ {
+		recordReplicaDial(refusing, 500*time.Microsecond, errors.New("refused"))
+		recordReplicaDial(healthy, 20*time.Millisecond, nil)
+	}
+
+	tests := []struct {
+		name      string
+		addresses []string
+		// Endereço que deve ser o primeiro na maioria dos sorteios
+		first string
+	}{
+		{"fast before slow", []string{slow, fast}, fast},
+		{"healthy before failing", []string{failing, fast}, fast},
+		{"slower healthy before fast refusal", []string{refusing, healthy}, healthy},
+		{"unmeasured first", []string{fast, slow, fresh}, fresh},
+	}
+
+	for _, tt := range tests {
+		firsts := 0
+		for i := 0; i < 1000; i++ {
+			ordered := weightedOrder(tt.addresses)
+			sorted := append([]string(nil), ordered...)
+			sort.Strings(sorted)
+			want := append([]string(nil), tt.addresses...)
+			sort.Strings(want)
+			if strings.Join(sorted, ",") != strings.Join(want, ",") {
+				t.Fatalf("%s: weightedOrder = %v, not a permutation of %v", tt.name, ordered, tt.addresses)
+			}
+			if ordered[0] == tt.first {
+				firsts++
+			}
+		}
+		if firsts < 800 {
+			t.Errorf("%s: %s first in %d of 1000 orders, want most", tt.name, tt.first, firsts)
+		}
+	}
+
+	if got := weightedOrder(nil); len(got) != 0 {
+		t.Errorf("weightedOrder(nil) = %v", got)
+	}
+}
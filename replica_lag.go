@@ -0,0 +1,246 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var errNoReplayTimestamp = errors.New("replica has not replayed any transaction")
+
+type ReplicaLagOptions struct {
+	// Intervalo entre as medições; padrão de 30s
+	Interval time.Duration
+	// Atraso a partir do qual a réplica deixa de receber conexões; padrão
+	// de 30s
+	MaxLag time.Duration
+	// Tempo máximo de cada medição; padrão de 5s
+	Timeout time.Duration
+}
+
+// ReplicaLagStats é a última medição do atraso de uma réplica regional.
+type ReplicaLagStats struct {
+	Tenant     string    `json:"tenant"`
+	Region     string    `json:"region"`
+	LagSeconds float64   `json:"lag_seconds"`
+	Excluded   bool      `json:"excluded"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+type replicaLagState struct {
+	mu sync.RWMutex
+	// Por tenant e região
+	replicas map[string]ReplicaLagStats
+}
+
+func replicaLagKey(tenant, region string) string {
+	return tenant + "\x00" + region
+}
+
+func (s *replicaLagState) excluded(tenant, region string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.replicas[replicaLagKey(tenant, region)].Excluded
+}
+
+// MonitorReplicaLag mede periodicamente o atraso das réplicas de
+// catalog_replica no Manager padrão, até o contexto ser cancelado.
+func MonitorReplicaLag(ctx context.Context, opts ReplicaLagOptions) {
+	defaultManager.MonitorReplicaLag(ctx, opts)
+}
+
+// MonitorReplicaLag mede periodicamente o atraso de replicação de cada réplica
+// de catalog_replica, até o contexto ser cancelado. Réplicas com atraso acima
+// de MaxLag deixam de ser usadas por PreferredRegion, que passa a conectar no
+// servidor principal, até o atraso voltar ao limite. As mudanças são emitidas
+// como EventReplicaExcluded e EventReplicaRestored, e as medições ficam em
+// ReplicaLags.
+func (m *Manager) MonitorReplicaLag(ctx context.Context, opts ReplicaLagOptions) {
+	if opts.Interval <= 0 {
+		opts.Interval = 30 * time.Second
+	}
+	if opts.MaxLag <= 0 {
+		opts.MaxLag = 30 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+
+	ticker := clock.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.checkReplicaLag(ctx, opts)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+func (m *Manager) checkReplicaLag(ctx context.Context, opts ReplicaLagOptions) {
+	var replicas [][2]string
+	err := m.readCatalog(ctx, func(ctx context.Context, db *sql.DB) error {
+		replicas = replicas[:0]
+		rows, err := db.QueryContext(ctx, `SELECT schema_name, region FROM catalog_replica`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var tenant, region string
+			if err := rows.Scan(&tenant, &region); err != nil {
+				return err
+			}
+			replicas = append(replicas, [2]string{tenant, region})
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		logError("Replica lag check failed: ", err)
+		return
+	}
+
+	// Tenants na mesma réplica compartilham a medição
+	measured := make(map[string]time.Duration)
+	for _, replica := range replicas {
+		if ctx.Err() != nil {
+			return
+		}
+		tenant, region := replica[0], replica[1]
+
+		// Sem medição não há como saber se a réplica está em dia, então ela é
+		// excluída até a próxima medição bem-sucedida
+		lag, err := m.measureReplicaLag(ctx, tenant, region, opts.Timeout, measured)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logError("Replica lag check for tenant ", tenant, " in region ", region, " failed: ", err)
+		}
+		m.recordReplicaLag(tenant, region, lag, opts.MaxLag, err)
+	}
+}
+
+func (m *Manager) measureReplicaLag(ctx context.Context, tenant, region string, timeout time.Duration, measured map[string]time.Duration) (time.Duration, error) {
+	catalog, err := m.GetTenant(ctx, tenant)
+	if err != nil {
+		return 0, err
+	}
+	replica, err := m.replicaCatalog(ctx, catalog, region)
+	if err != nil {
+		return 0, err
+	}
+
+	key := replica.Server + "/" + replica.DatabaseName
+	if lag, found := measured[key]; found {
+		return lag, nil
+	}
+
+	db, err := openTenantDB(replica, TenantConnectOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Sem escritas no primário, o último replay fica antigo mesmo sem
+	// atraso; a réplica com todo o WAL recebido aplicado está em dia. Isso só
+	// vale com o wal receiver conectado: desconectado, o último LSN recebido
+	// para de avançar e o atraso é medido pelo último replay, sem o qual não
+	// há medição.
+	var seconds sql.NullFloat64
+	err = db.QueryRowContext(ctx, `
+        SELECT CASE
+            WHEN NOT pg_is_in_recovery() THEN 0
+            WHEN EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE status = 'streaming')
+                AND pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+            ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+        END`).Scan(&seconds)
+	if err != nil {
+		return 0, err
+	}
+	if !seconds.Valid {
+		return 0, errNoReplayTimestamp
+	}
+
+	lag := time.Duration(seconds.Float64 * float64(time.Second))
+	measured[key] = lag
+	return lag, nil
+}
+
+// recordReplicaLag guarda a medição, excluindo a réplica quando o atraso
+// passa de maxLag ou não pôde ser medido, e, quando a réplica entra ou sai do
+// roteamento, descarta os pools do tenant que preferem a região para que ela
+// seja escolhida novamente
+func (m *Manager) recordReplicaLag(tenant, region string, lag, maxLag time.Duration, measureErr error) {
+	excluded := measureErr != nil || lag > maxLag
+	key := replicaLagKey(tenant, region)
+
+	m.replicaLag.mu.Lock()
+	if m.replicaLag.replicas == nil {
+		m.replicaLag.replicas = make(map[string]ReplicaLagStats)
+	}
+	previous := m.replicaLag.replicas[key]
+	m.replicaLag.replicas[key] = ReplicaLagStats{
+		Tenant:     tenant,
+		Region:     region,
+		LagSeconds: lag.Seconds(),
+		Excluded:   excluded,
+		CheckedAt:  clock.Now(),
+	}
+	m.replicaLag.mu.Unlock()
+
+	if excluded == previous.Excluded {
+		return
+	}
+
+	reason := fmt.Sprintf("region %s lag %s", region, lag.Round(time.Millisecond))
+	if measureErr != nil {
+		reason = fmt.Sprintf("region %s lag unknown: %v", region, measureErr)
+	}
+	if excluded {
+		logError("Replica for tenant ", tenant, " excluded: ", reason)
+		m.emit(EventReplicaExcluded, tenant, reason)
+	} else {
+		logInfo("Replica for tenant ", tenant, " restored: ", reason)
+		m.emit(EventReplicaRestored, tenant, reason)
+	}
+	m.invalidatePools(tenant, func(conn Connection) bool {
+		return conn.preferredRegion == region
+	})
+}
+
+// ReplicaLags retorna a última medição de cada réplica do Manager padrão.
+func ReplicaLags() []ReplicaLagStats {
+	return defaultManager.ReplicaLags()
+}
+
+// ReplicaLags retorna a última medição de cada réplica feita por
+// MonitorReplicaLag, ordenadas por tenant e região.
+func (m *Manager) ReplicaLags() []ReplicaLagStats {
+	m.replicaLag.mu.RLock()
+	defer m.replicaLag.mu.RUnlock()
+
+	stats := make([]ReplicaLagStats, 0, len(m.replicaLag.replicas))
+	for _, replica := range m.replicaLag.replicas {
+		stats = append(stats, replica)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Tenant != stats[j].Tenant {
+			return stats[i].Tenant < stats[j].Tenant
+		}
+		return stats[i].Region < stats[j].Region
+	})
+	return stats
+}
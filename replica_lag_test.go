@@ -0,0 +1,50 @@
+package connection
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRecordReplicaLag(t *testing.T) {
+	tests := []struct {
+		name     string
+		lag      time.Duration
+		err      error
+		excluded bool
+	}{
+		{"within limit", time.Second, nil, false},
+		{"above limit", time.Minute, nil, true},
+		{"measurement failed", 0, errors.New("connection refused"), true},
+		{"no replay yet", 0, errNoReplayTimestamp, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager()
+			m.recordReplicaLag("acme", "eu", tt.lag, 30*time.Second, tt.err)
+			if got := m.replicaLag.excluded("acme", "eu"); got != tt.excluded {
+				t.Fatalf("excluded = %v, want %v", got, tt.excluded)
+			}
+		})
+	}
+}
+
+func TestReplicaExclusionKeepsOtherPools(t *testing.T) {
+	m := newTestManager()
+	m.addTestPool(Connection{SearchPath: "acme"})
+	m.addTestPool(Connection{SearchPath: "acme", pool: "batch"})
+	m.addTestPool(Connection{SearchPath: "acme", pool: "reports", preferredRegion: "eu"})
+	m.addTestPool(Connection{SearchPath: "other", preferredRegion: "eu"})
+
+	m.recordReplicaLag("acme", "eu", time.Minute, 30*time.Second, nil)
+
+	for key, want := range map[string]bool{"acme": true, "acme/batch": true, "acme/reports": false, "other": true} {
+		if _, found := m.trackedConnection(key); found != want {
+			t.Errorf("pool %s tracked = %v, want %v", key, found, want)
+		}
+		if _, found := m.cache.Get(prefixConnection + key); found != want {
+			t.Errorf("pool %s cached = %v, want %v", key, found, want)
+		}
+	}
+}
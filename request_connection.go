@@ -0,0 +1,127 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrRequestConnectionReleased = errors.New("request connection already released")
+
+type requestConnectionKey struct{}
+
+// requestConnection é a conexão do tenant obtida uma única vez por request
+type requestConnection struct {
+	tenant string
+	// Obtém a conexão do tenant; GetTenantConnectionWithOptions fora dos
+	// testes
+	acquire func(ctx context.Context, tenant string) (Connection, error)
+
+	// Serializa as tentativas de obter a conexão
+	acquireMutex sync.Mutex
+	mu           sync.Mutex
+	conn         Connection
+	// A conexão foi obtida; erros não são guardados, e a próxima chamada
+	// tenta novamente
+	acquired bool
+	done     bool
+	// Valores criados a partir da conexão, como os de TenantRepo
+	values map[interface{}]interface{}
+}
+
+// WithRequestConnection retorna um contexto em que a conexão do tenant é
+// obtida na primeira chamada a RequestConnection (ou ConnectionFromContext)
+// bem-sucedida e reutilizada por todos os repositórios do mesmo request, e a função que a
+// libera ao final do request. As opções vêm de SetDefaultTenantOptions.
+//
+//	ctx, release := connection.WithRequestConnection(r.Context(), tenant)
+//	defer release()
+func WithRequestConnection(ctx context.Context, tenant string) (context.Context, func()) {
+	holder := &requestConnection{tenant: tenant, acquire: acquireRequestConnection}
+	return context.WithValue(ctx, requestConnectionKey{}, holder), holder.release
+}
+
+// RequestConnection retorna a conexão do request criado com
+// WithRequestConnection, obtendo-a na primeira chamada. Sem
+// WithRequestConnection no contexto, retorna ErrMissingTenant.
+func RequestConnection(ctx context.Context) (Connection, error) {
+	holder, ok := ctx.Value(requestConnectionKey{}).(*requestConnection)
+	if !ok {
+		return Connection{}, ErrMissingTenant
+	}
+	return holder.get(ctx)
+}
+
+func acquireRequestConnection(ctx context.Context, tenant string) (Connection, error) {
+	return GetTenantConnectionWithOptions(ctx, tenant, TenantConnectOptions{})
+}
+
+func (h *requestConnection) get(ctx context.Context) (Connection, error) {
+	h.acquireMutex.Lock()
+	defer h.acquireMutex.Unlock()
+
+	h.mu.Lock()
+	if h.done || h.acquired {
+		conn, done := h.conn, h.done
+		h.mu.Unlock()
+		if done {
+			return Connection{}, ErrRequestConnectionReleased
+		}
+		return conn, nil
+	}
+	h.mu.Unlock()
+
+	// Um erro, como o contexto de uma chamada cancelado ou o banco fora do
+	// ar por um instante, não é guardado para o resto do request
+	conn, err := h.acquire(ctx, h.tenant)
+	if err != nil {
+		return Connection{}, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.done {
+		// Liberado enquanto a conexão era obtida
+		conn.Close()
+		return Connection{}, ErrRequestConnectionReleased
+	}
+	h.conn, h.acquired = conn, true
+	return conn, nil
+}
+
+// release fecha a conexão quando ela é exclusiva do request (Detached ou
+// CacheDisabled nas opções padrão); pools do cache continuam abertos
+func (h *requestConnection) release() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.done {
+		return
+	}
+	h.done = true
+	if h.acquired && h.conn.DB != nil {
+		h.conn.Close()
+	}
+}
+
+// value retorna o valor guardado em key ou o cria com build a partir da
+// conexão do request
+func (h *requestConnection) value(ctx context.Context, key interface{}, build func(Connection) interface{}) (interface{}, error) {
+	conn, err := h.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if value, found := h.values[key]; found {
+		return value, nil
+	}
+	if h.values == nil {
+		h.values = make(map[interface{}]interface{})
+	}
+	value := build(conn)
+	h.values[key] = value
+	return value, nil
+}
@@ -0,0 +1,39 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRequestConnectionRetriesAfterError(t *testing.T) {
+	var calls int
+	holder := &requestConnection{tenant: "acme", acquire: func(ctx context.Context, tenant string) (Connection, error) {
+		calls++
+		if calls == 1 {
+			return Connection{}, context.Canceled
+		}
+		return Connection{SearchPath: tenant}, nil
+	}}
+
+	if _, err := holder.get(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("first get error = %v, want Canceled", err)
+	}
+	for i := 0; i < 2; i++ {
+		conn, err := holder.get(context.Background())
+		if err != nil || conn.SearchPath != "acme" {
+			t.Fatalf("get after error = %+v, %v", conn, err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("acquire called %d times, want 2", calls)
+	}
+
+	holder.release()
+	if _, err := holder.get(context.Background()); !errors.Is(err, ErrRequestConnectionReleased) {
+		t.Fatalf("get after release error = %v, want ErrRequestConnectionReleased", err)
+	}
+	if calls != 2 {
+		t.Fatalf("acquire called after release")
+	}
+}
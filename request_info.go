@@ -0,0 +1,51 @@
+package connection
+
+import (
+	"context"
+)
+
+type (
+	requestIDContextKey struct{}
+	userIDContextKey    struct{}
+)
+
+// RequestInfoExtractor permite usar o request ID e o usuário que a aplicação
+// já guarda no contexto com as suas próprias chaves. Quando nil, são usados os
+// valores definidos por WithRequestID e WithUserID.
+var RequestInfoExtractor func(ctx context.Context) (requestID, userID string)
+
+// WithRequestID guarda no contexto o ID da requisição, incluído nos logs do
+// pacote para acompanhar a requisição entre os serviços até o banco.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// WithUserID guarda no contexto o usuário da requisição, incluído nos logs.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDContextKey{}, userID)
+}
+
+func requestInfo(ctx context.Context) (requestID, userID string) {
+	if RequestInfoExtractor != nil {
+		return RequestInfoExtractor(ctx)
+	}
+
+	requestID, _ = ctx.Value(requestIDContextKey{}).(string)
+	userID, _ = ctx.Value(userIDContextKey{}).(string)
+	return requestID, userID
+}
+
+// requestLogFields retorna os campos da requisição para o final das linhas de
+// log, ou "" quando o contexto não os possui
+func requestLogFields(ctx context.Context) string {
+	requestID, userID := requestInfo(ctx)
+
+	var fields string
+	if requestID != "" {
+		fields += " request_id=" + requestID
+	}
+	if userID != "" {
+		fields += " user_id=" + userID
+	}
+	return fields
+}
@@ -0,0 +1,117 @@
+package connection
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const prefixResult = "result-"
+
+// ResultCache armazena os resultados de QueryCached. Por padrão é usado o
+// mesmo cache ristretto das conexões; outra implementação (por exemplo, um
+// cache compartilhado entre instâncias) pode ser registrada com UseResultCache.
+type ResultCache interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{}, ttl time.Duration)
+	Del(key string)
+}
+
+var (
+	resultCache ResultCache = ristrettoResultCache{}
+
+	// O ristretto não permite listar as chaves de um tenant, então a
+	// invalidação por tenant troca a geração usada na chave
+	resultGenerationsMutex sync.Mutex
+	resultGenerations      = make(map[string]uint64)
+)
+
+// UseResultCache troca o cache usado por QueryCached.
+func UseResultCache(cache ResultCache) {
+	resultCache = cache
+}
+
+// QueryCached executa a query e guarda as linhas por ttl sob a chave key,
+// devolvendo o resultado em cache nas chamadas seguintes. Deve ser usada
+// apenas em consultas idempotentes, como configurações do tenant. Cada linha
+// é um mapa com o nome da coluna e o valor retornado pelo driver.
+func (c Connection) QueryCached(ctx context.Context, key string, ttl time.Duration, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	cacheKey := resultCacheKey(c.SearchPath, key)
+	if value, found := resultCache.Get(cacheKey); found {
+		return value.([]map[string]interface{}), nil
+	}
+
+	rows, err := c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			row[column] = values[i]
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	resultCache.Set(cacheKey, result, ttl)
+	return result, nil
+}
+
+// InvalidateResult remove do cache o resultado guardado sob key para o tenant.
+func InvalidateResult(tenant, key string) {
+	resultCache.Del(resultCacheKey(tenant, key))
+}
+
+// InvalidateTenantResults descarta todos os resultados em cache do tenant
+// nesta instância.
+func InvalidateTenantResults(tenant string) {
+	resultGenerationsMutex.Lock()
+	defer resultGenerationsMutex.Unlock()
+
+	resultGenerations[tenant]++
+}
+
+func resultCacheKey(tenant, key string) string {
+	resultGenerationsMutex.Lock()
+	generation := resultGenerations[tenant]
+	resultGenerationsMutex.Unlock()
+
+	// Separador que não aparece em nomes de tenant, evitando colisões entre
+	// tenants e chaves que contenham "-"
+	return prefixResult + tenant + "\x00" + strconv.FormatUint(generation, 10) + "\x00" + key
+}
+
+type ristrettoResultCache struct{}
+
+func (ristrettoResultCache) Get(key string) (interface{}, bool) {
+	return Connections.Get(key)
+}
+
+func (ristrettoResultCache) Set(key string, value interface{}, ttl time.Duration) {
+	Connections.SetWithTTL(key, value, 1, ttl)
+}
+
+func (ristrettoResultCache) Del(key string) {
+	Connections.Del(key)
+}
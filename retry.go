@@ -0,0 +1,174 @@
+package connection
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RetryPolicy define as novas tentativas de leituras (SELECT) que falharam
+// por erros transitórios de rede, como o reinício ou o failover do servidor
+// do tenant, ou por Restart read required no YugabyteDB. Escritas nunca são
+// repetidas.
+type RetryPolicy struct {
+	// Total de tentativas, incluindo a primeira; até 1 desabilita
+	MaxAttempts int
+	// Espera antes da segunda tentativa, dobrada a cada nova tentativa até
+	// MaxDelay, com jitter; padrão de 50ms
+	BaseDelay time.Duration
+	// Padrão de 1s
+	MaxDelay time.Duration
+}
+
+// delay retorna a espera antes da tentativa attempt (a partir de 1), entre
+// metade e o total do backoff exponencial
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	base, limit := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = 50 * time.Millisecond
+	}
+	if limit <= 0 {
+		limit = time.Second
+	}
+
+	d := base
+	for i := 1; i < attempt && d < limit; i++ {
+		d *= 2
+	}
+	if d > limit {
+		d = limit
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryRead executa fn e, quando a query é um SELECT e a política permite,
+// repete a execução nos erros transitórios
+func (c Connection) retryRead(ctx context.Context, query string, fn func() error) error {
+	err := fn()
+	if c.retryReads.MaxAttempts <= 1 || !isReadQuery(ctx, query) {
+		return err
+	}
+
+	for attempt := 1; attempt < c.retryReads.MaxAttempts && (isTransient(err) || isReadRestart(err)); attempt++ {
+		logError("Retrying read for tenant ", c.SearchPath, " after transient error: ", err)
+		if waitErr := sleepContext(ctx, c.retryReads.delay(attempt)); waitErr != nil {
+			return err
+		}
+		err = fn()
+	}
+	return err
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	done := make(chan struct{})
+	timer := clock.AfterFunc(d, func() { close(done) })
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		timer.Stop()
+		return ctx.Err()
+	}
+}
+
+// RetryableFunctions são as funções que não alteram dados e podem ser
+// repetidas por RetryReads. Um SELECT que chama outra função (nextval, uma
+// função da aplicação) só é repetido com WithRetryableRead. A lista pode ser
+// ampliada na inicialização da aplicação.
+var RetryableFunctions = []string{
+	"count", "sum", "avg", "min", "max", "array_agg", "string_agg", "bool_and", "bool_or",
+	"json_agg", "jsonb_agg", "json_build_object", "jsonb_build_object", "row_number", "rank",
+	"coalesce", "nullif", "greatest", "least", "lower", "upper", "length", "trim", "substring",
+	"concat", "replace", "round", "abs", "floor", "ceil", "date_trunc", "extract", "to_char",
+	"now", "current_date", "current_timestamp", "to_regclass",
+}
+
+type retryableReadKey struct{}
+
+// WithRetryableRead marca as queries executadas com o contexto como seguras
+// para repetir, mesmo chamando funções fora de RetryableFunctions. SELECTs
+// com FOR UPDATE/FOR SHARE, INTO ou vários comandos continuam sem repetição.
+func WithRetryableRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryableReadKey{}, true)
+}
+
+var (
+	lockingClause = regexp.MustCompile(`\bFOR (NO KEY |KEY )?(UPDATE|SHARE)\b|\bINTO\b`)
+	// Nome ou identificador entre aspas, com o schema opcional, seguido de (
+	functionCall = regexp.MustCompile(`((?:[A-Z_][A-Z0-9_$]*|"(?:[^"]|"")*")(?:\.(?:[A-Z_][A-Z0-9_$]*|"(?:[^"]|"")*"))*) ?\(`)
+)
+
+// Palavras-chave que aparecem antes de ( sem ser uma chamada de função
+var parenKeywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "AND": true, "OR": true, "NOT": true, "IN": true,
+	"EXISTS": true, "ANY": true, "ALL": true, "SOME": true, "VALUES": true, "AS": true, "ON": true,
+	"USING": true, "JOIN": true, "LATERAL": true, "OVER": true, "FILTER": true, "WITHIN": true,
+	"ROW": true, "ARRAY": true, "CAST": true, "CASE": true, "WHEN": true, "THEN": true, "ELSE": true,
+	"BY": true, "HAVING": true, "UNION": true, "EXCEPT": true, "INTERSECT": true, "IS": true,
+	"LIKE": true, "ILIKE": true, "BETWEEN": true, "DISTINCT": true, "LIMIT": true, "OFFSET": true,
+	"GROUP": true,
+}
+
+// isReadQuery indica se a query é um único SELECT que pode ser repetido: sem
+// cláusulas de bloqueio (FOR UPDATE, FOR SHARE...), sem INTO e chamando apenas
+// as funções de RetryableFunctions, a menos que o contexto tenha
+// WithRetryableRead. CTEs (WITH) ficam de fora, pois podem conter escritas.
+func isReadQuery(ctx context.Context, query string) bool {
+	statement := strings.TrimLeft(normalizeStatement(query), "( ")
+	if !strings.HasPrefix(statement, "SELECT") || (len(statement) > 6 && isNamePart(statement[6])) {
+		return false
+	}
+	if strings.Contains(statement, "; ") || lockingClause.MatchString(statement) {
+		return false
+	}
+	if optIn, _ := ctx.Value(retryableReadKey{}).(bool); optIn {
+		return true
+	}
+
+	safe := make(map[string]bool, len(RetryableFunctions))
+	for _, name := range RetryableFunctions {
+		safe[strings.ToUpper(name)] = true
+	}
+	for _, match := range functionCall.FindAllStringSubmatch(statement, -1) {
+		if name := match[1]; !parenKeywords[name] && !safe[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// isTransient indica falhas de conexão que podem ser resolvidas com uma nova
+// tentativa: conexão inválida ou encerrada, servidor reiniciando ou em
+// failover
+func isTransient(err error) bool {
+	if err == nil || isCancellation(err) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "57P01", "57P02", "57P03":
+			// admin_shutdown, crash_shutdown, cannot_connect_now
+			return true
+		}
+		// connection_exception e derivados
+		return pqErr.Code.Class() == "08"
+	}
+
+	var netErr *net.OpError
+	return errors.As(err, &netErr)
+}
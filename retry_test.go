@@ -0,0 +1,57 @@
+package connection
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIsReadQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"SELECT 1", true},
+		{"  select id, name from users where id = $1", true},
+		{"-- comentário\n/* bloco */ SELECT id FROM t", true},
+		{"(SELECT id FROM a) UNION (SELECT id FROM b)", true},
+		{"SELECT count(*), max(created_at) FROM orders", true},
+		{"SELECT COALESCE(sum(total), 0) FROM orders WHERE id IN (SELECT id FROM t)", true},
+		{"SELECT id FROM t WHERE EXISTS (SELECT 1 FROM u)", true},
+		{"SELECT x::int FROM t WHERE name = 'nextval(1)' -- nextval(2)", true},
+		{"SELECT row_number() OVER (PARTITION BY a ORDER BY b) FROM t", true},
+		{"SELECT id FROM t; DELETE FROM t", false},
+		{"SELECT id FROM t FOR UPDATE", false},
+		{"select id from t for no key update skip locked", false},
+		{"SELECT id FROM t FOR SHARE", false},
+		{"SELECT id FROM t FOR KEY SHARE", false},
+		{"SELECT id INTO backup FROM t", false},
+		{"SELECT nextval('orders_id_seq')", false},
+		{"SELECT write_fn($1)", false},
+		{"SELECT app.close_order($1)", false},
+		{`SELECT "Notify" (1)`, false},
+		{"SELECT pg_catalog.count(*) FROM t", false},
+		{"WITH x AS (DELETE FROM t RETURNING id) SELECT id FROM x", false},
+		{"SELECTED", false},
+		{"UPDATE t SET x = 1", false},
+		{"-- SELECT 1", false},
+	}
+
+	for _, test := range tests {
+		if got := isReadQuery(context.Background(), test.query); got != test.want {
+			t.Errorf("isReadQuery(%q) = %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestIsReadQueryOptIn(t *testing.T) {
+	ctx := WithRetryableRead(context.Background())
+
+	if !isReadQuery(ctx, "SELECT report_totals($1)") {
+		t.Error("function call not retried with WithRetryableRead")
+	}
+	for _, query := range []string{"SELECT id FROM t FOR UPDATE", "SELECT 1; SELECT nextval('s')", "UPDATE t SET x = f()"} {
+		if isReadQuery(ctx, query) {
+			t.Errorf("isReadQuery(%q) with WithRetryableRead = true", query)
+		}
+	}
+}
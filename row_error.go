@@ -0,0 +1,61 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+)
+
+// errorDB devolve em um *sql.Row um erro que não veio do banco, como uma query
+// recusada ou um panic, já que o *sql.Row não pode ser criado fora do
+// database/sql. As suas conexões não acessam a rede: a query apenas falha com
+// o erro guardado no contexto.
+var errorDB = sql.OpenDB(errorConnector{})
+
+type rowErrorKey struct{}
+
+// errorRow devolve um *sql.Row cujo Scan e Err retornam err
+func errorRow(err error) *sql.Row {
+	ctx := context.WithValue(context.Background(), rowErrorKey{}, err)
+	return errorDB.QueryRowContext(ctx, "")
+}
+
+type errorConnector struct{}
+
+func (errorConnector) Connect(context.Context) (driver.Conn, error) {
+	return errorConn{}, nil
+}
+
+func (errorConnector) Driver() driver.Driver {
+	return errorDriver{}
+}
+
+type errorDriver struct{}
+
+func (errorDriver) Open(string) (driver.Conn, error) {
+	return errorConn{}, nil
+}
+
+var errNotSupported = errors.New("not supported")
+
+type errorConn struct{}
+
+func (errorConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errNotSupported
+}
+
+func (errorConn) Close() error {
+	return nil
+}
+
+func (errorConn) Begin() (driver.Tx, error) {
+	return nil, errNotSupported
+}
+
+func (errorConn) QueryContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	if err, ok := ctx.Value(rowErrorKey{}).(error); ok {
+		return nil, err
+	}
+	return nil, errNotSupported
+}
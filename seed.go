@@ -0,0 +1,70 @@
+package connection
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// SeedTenant cadastra o tenant no catálogo e cria o seu schema no servidor
+// do tenant. É idempotente: registros e schemas existentes são mantidos.
+func SeedTenant(ctx context.Context, catalog Catalog) error {
+	return defaultManager.SeedTenant(ctx, catalog)
+}
+
+func (m *Manager) SeedTenant(ctx context.Context, catalog Catalog) error {
+	var options []byte
+	if len(catalog.Options) > 0 {
+		var err error
+		if options, err = json.Marshal(catalog.Options); err != nil {
+			return err
+		}
+	}
+	if catalog.Driver == "" {
+		catalog.Driver = "postgres"
+	}
+
+	_, err := m.catalog.ExecContext(ctx, `
+        INSERT INTO catalog (driver, user_name, password, server, database_name, schema_name, region, options)
+        VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8)
+        ON CONFLICT (schema_name) DO NOTHING`,
+		catalog.Driver,
+		catalog.UserName,
+		catalog.Password,
+		catalog.Server,
+		catalog.DatabaseName,
+		catalog.SchemaName,
+		catalog.Region,
+		options,
+	)
+	if err != nil {
+		return fmt.Errorf("seeding catalog for %s: %w", catalog.SchemaName, err)
+	}
+
+	db, err := openTenantDB(&catalog, TenantConnectOptions{})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+pq.QuoteIdentifier(catalog.SchemaName)); err != nil {
+		return fmt.Errorf("creating schema %s: %w", catalog.SchemaName, err)
+	}
+
+	return nil
+}
+
+func SeedTenants(ctx context.Context, catalogs []Catalog) error {
+	return defaultManager.SeedTenants(ctx, catalogs)
+}
+
+func (m *Manager) SeedTenants(ctx context.Context, catalogs []Catalog) error {
+	for _, catalog := range catalogs {
+		if err := m.SeedTenant(ctx, catalog); err != nil {
+			return err
+		}
+	}
+	return nil
+}
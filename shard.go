@@ -0,0 +1,194 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const prefixShards = "shards-"
+
+// Tempo em que a lista de shards de um tenant fica em cache
+const shardsTTL = 5 * time.Minute
+
+var ErrTenantNotSharded = errors.New("tenant not sharded")
+
+// GetShardConnection retorna a conexão do shard do tenant ao qual a chave
+// pertence, no Manager padrão.
+func GetShardConnection(ctx context.Context, tenant, shardKey string) (Connection, error) {
+	return defaultManager.GetShardConnection(ctx, tenant, shardKey)
+}
+
+// GetShardConnection retorna a conexão do shard do tenant ao qual a chave
+// pertence. Os shards são schemas cadastrados como tenants no catálogo e
+// listados na tabela catalog_shard, e a chave é distribuída pelo hash entre
+// eles na ordem da coluna shard. Alterar a quantidade de shards muda o shard
+// da maioria das chaves, então exige a redistribuição dos dados.
+func (m *Manager) GetShardConnection(ctx context.Context, tenant, shardKey string) (Connection, error) {
+	shard, err := m.ShardFor(ctx, tenant, shardKey)
+	if err != nil {
+		return Connection{}, err
+	}
+	return m.GetTenantConnection(ctx, shard, TenantConnectOptions{})
+}
+
+// ShardFor retorna o schema do shard do tenant ao qual a chave pertence, no
+// Manager padrão.
+func ShardFor(ctx context.Context, tenant, shardKey string) (string, error) {
+	return defaultManager.ShardFor(ctx, tenant, shardKey)
+}
+
+func (m *Manager) ShardFor(ctx context.Context, tenant, shardKey string) (string, error) {
+	shards, err := m.TenantShards(ctx, tenant)
+	if err != nil {
+		return "", err
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(shardKey))
+	return shards[h.Sum32()%uint32(len(shards))], nil
+}
+
+// TenantShards retorna os schemas dos shards do tenant, no Manager padrão.
+func TenantShards(ctx context.Context, tenant string) ([]string, error) {
+	return defaultManager.TenantShards(ctx, tenant)
+}
+
+// TenantShards retorna os schemas dos shards do tenant na ordem da coluna
+// shard, ou ErrTenantNotSharded quando o tenant não tem shards.
+func (m *Manager) TenantShards(ctx context.Context, tenant string) ([]string, error) {
+	cacheKey := prefixShards + tenant
+	if shards, found := m.cache.Get(cacheKey); found {
+		return append([]string(nil), shards.([]string)...), nil
+	}
+
+	query := `
+        SELECT shard_schema
+        FROM catalog_shard
+        WHERE schema_name = $1
+        ORDER BY shard`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var shards []string
+	err := m.readCatalog(ctx, func(ctx context.Context, db *sql.DB) error {
+		shards = shards[:0]
+		rows, err := db.QueryContext(ctx, query, tenant)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var shard string
+			if err := rows.Scan(&shard); err != nil {
+				return err
+			}
+			shards = append(shards, shard)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(shards) == 0 {
+		return nil, ErrTenantNotSharded
+	}
+
+	m.cache.Set(cacheKey, append([]string(nil), shards...), shardsTTL)
+
+	return shards, nil
+}
+
+// ShardError é a falha da consulta em um shard.
+type ShardError struct {
+	Shard string
+	Err   error
+}
+
+// ShardQueryError reúne as falhas de QueryAllShards por shard. errors.Is e
+// errors.As consultam os erros de cada shard.
+type ShardQueryError struct {
+	Tenant string
+	Shards int
+	Errors []ShardError
+}
+
+func (e *ShardQueryError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "tenant %s: query failed on %d of %d shards", e.Tenant, len(e.Errors), e.Shards)
+	for _, shardErr := range e.Errors {
+		fmt.Fprintf(&b, "; %s: %v", shardErr.Shard, shardErr.Err)
+	}
+	return b.String()
+}
+
+func (e *ShardQueryError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, shardErr := range e.Errors {
+		errs[i] = shardErr.Err
+	}
+	return errs
+}
+
+// QueryAllShards executa a consulta em todos os shards do tenant ao mesmo
+// tempo e junta as linhas lidas com scan, na ordem dos shards. Quando algum
+// shard falha, retorna as linhas dos demais junto com um ShardQueryError.
+func QueryAllShards[T any](ctx context.Context, tenant string, scan func(*sql.Rows) (T, error), query string, args ...interface{}) ([]T, error) {
+	return QueryAllShardsOn(ctx, defaultManager, tenant, scan, query, args...)
+}
+
+// QueryAllShardsOn é o QueryAllShards nos shards do tenant no Manager m.
+// Métodos não aceitam parâmetros de tipo, então o Manager é um argumento.
+func QueryAllShardsOn[T any](ctx context.Context, m *Manager, tenant string, scan func(*sql.Rows) (T, error), query string, args ...interface{}) ([]T, error) {
+	shards, err := m.TenantShards(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([][]T, len(shards))
+	errs := make([]error, len(shards))
+
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i int, shard string) {
+			defer wg.Done()
+
+			conn, err := m.GetTenantConnection(ctx, shard, TenantConnectOptions{})
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			rows, err := conn.QueryContext(ctx, query, args...)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			results[i], errs[i] = CollectRows(rows, scan)
+		}(i, shard)
+	}
+	wg.Wait()
+
+	var (
+		items     []T
+		shardErrs []ShardError
+	)
+	for i, shard := range shards {
+		if errs[i] != nil {
+			shardErrs = append(shardErrs, ShardError{Shard: shard, Err: errs[i]})
+			continue
+		}
+		items = append(items, results[i]...)
+	}
+	if len(shardErrs) > 0 {
+		return items, &ShardQueryError{Tenant: tenant, Shards: len(shards), Errors: shardErrs}
+	}
+	return items, nil
+}
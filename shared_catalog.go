@@ -0,0 +1,116 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// SharedCatalog é um cache do catálogo compartilhado entre as instâncias do
+// serviço, normalmente implementado sobre o Redis (GET/SET com TTL e pub/sub).
+// O pacote não depende de um client específico; a implementação é fornecida
+// pela aplicação através de UseSharedCatalog. O módulo connectionredis traz a
+// implementação sobre o go-redis.
+type SharedCatalog interface {
+	// GetCatalog retorna ErrRecordNotFound quando o tenant não está no cache
+	GetCatalog(ctx context.Context, tenant string) (*Catalog, error)
+	SetCatalog(ctx context.Context, catalog *Catalog, ttl time.Duration) error
+	DeleteCatalog(ctx context.Context, tenant string) error
+	// PublishInvalidation avisa as demais instâncias que o tenant mudou
+	PublishInvalidation(ctx context.Context, tenant string) error
+	// SubscribeInvalidations chama fn para cada invalidação publicada, até
+	// o contexto ser cancelado
+	SubscribeInvalidations(ctx context.Context, fn func(tenant string)) error
+}
+
+func (m *Manager) currentSharedCatalog() (SharedCatalog, time.Duration) {
+	m.sharedCatalogMutex.RLock()
+	defer m.sharedCatalogMutex.RUnlock()
+
+	return m.sharedCatalog, m.sharedCatalogTTL
+}
+
+// UseSharedCatalog passa a usar o cache compartilhado no Manager padrão.
+func UseSharedCatalog(ctx context.Context, store SharedCatalog, ttl time.Duration) {
+	defaultManager.UseSharedCatalog(ctx, store, ttl)
+}
+
+// UseSharedCatalog passa a consultar o cache compartilhado antes do banco do
+// catálogo e a aplicar as invalidações publicadas por outras instâncias.
+// Deve ser chamada na inicialização, antes do uso das conexões. Os registros
+// são indexados apenas pelo tenant, então Managers com catálogos diferentes
+// precisam de caches separados (em connectionredis, prefixos diferentes).
+func (m *Manager) UseSharedCatalog(ctx context.Context, store SharedCatalog, ttl time.Duration) {
+	m.sharedCatalogMutex.Lock()
+	m.sharedCatalog = store
+	m.sharedCatalogTTL = ttl
+	m.sharedCatalogMutex.Unlock()
+
+	go func() {
+		for ctx.Err() == nil {
+			err := store.SubscribeInvalidations(ctx, m.invalidateLocal)
+			if ctx.Err() != nil {
+				return
+			}
+			logError("Shared catalog subscription failed: ", err)
+			sleepContext(ctx, time.Second)
+		}
+	}()
+}
+
+// InvalidateTenant descarta o registro do catálogo e o pool de conexões do
+// tenant nesta e, quando configurado o SharedCatalog, nas demais instâncias.
+// Deve ser chamada após alterar o registro do tenant no catálogo, por exemplo
+// na troca de senha.
+func InvalidateTenant(ctx context.Context, tenant string) error {
+	return defaultManager.InvalidateTenantContext(ctx, tenant)
+}
+
+// InvalidateTenantContext equivale ao InvalidateTenant do pacote: além deste
+// Manager, avisa as demais instâncias pelo SharedCatalog.
+func (m *Manager) InvalidateTenantContext(ctx context.Context, tenant string) error {
+	m.invalidateLocal(tenant)
+
+	store, _ := m.currentSharedCatalog()
+	if store == nil {
+		return nil
+	}
+
+	if err := store.DeleteCatalog(ctx, tenant); err != nil {
+		return err
+	}
+	return store.PublishInvalidation(ctx, tenant)
+}
+
+func (m *Manager) invalidateLocal(tenant string) {
+	logInfo("Invalidating tenant ", tenant)
+	m.InvalidateTenant(tenant)
+}
+
+func (m *Manager) sharedCatalogGet(ctx context.Context, tenant string) (*Catalog, bool) {
+	store, _ := m.currentSharedCatalog()
+	if store == nil {
+		return nil, false
+	}
+
+	catalog, err := store.GetCatalog(ctx, tenant)
+	if err != nil {
+		// Falhas no cache compartilhado não impedem a consulta ao catálogo
+		if !errors.Is(err, ErrRecordNotFound) {
+			logError("Shared catalog get failed: ", err)
+		}
+		return nil, false
+	}
+	return catalog, true
+}
+
+func (m *Manager) sharedCatalogSet(ctx context.Context, catalog *Catalog) {
+	store, ttl := m.currentSharedCatalog()
+	if store == nil {
+		return
+	}
+
+	if err := store.SetCatalog(ctx, catalog, ttl); err != nil {
+		logError("Shared catalog set failed: ", err)
+	}
+}
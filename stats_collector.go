@@ -0,0 +1,201 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+)
+
+type TableStats struct {
+	Table    string `json:"table"`
+	Rows     int64  `json:"rows"`
+	DeadRows int64  `json:"dead_rows"`
+	// Tamanho da tabela com índices e TOAST
+	TotalBytes int64 `json:"total_bytes"`
+	// Fração de linhas mortas (dead/(live+dead)), estimativa do inchaço da
+	// tabela a partir de pg_stat_user_tables
+	BloatRatio float64 `json:"bloat_ratio"`
+}
+
+type TenantStats struct {
+	Tenant      string       `json:"tenant"`
+	TotalBytes  int64        `json:"total_bytes"`
+	Tables      []TableStats `json:"tables"`
+	CollectedAt time.Time    `json:"collected_at"`
+	// Limites de StorageLimits excedidos nesta coleta; quando os limites não
+	// puderam ser lidos do catálogo, os da coleta anterior
+	LimitsExceeded []string `json:"limits_exceeded,omitempty"`
+}
+
+type StatsCollectorOptions struct {
+	// Intervalo entre as coletas de todos os tenants; padrão de 1h
+	Interval time.Duration
+	// Pausa entre um tenant e o próximo, para não sobrecarregar os servidores;
+	// padrão de 1s
+	Delay time.Duration
+	// Tempo máximo da coleta de cada tenant; padrão de 30s
+	Timeout time.Duration
+	// Chamada com as estatísticas de cada tenant coletado
+	OnStats func(TenantStats)
+	// Limites dos tenants sem linha em catalog_storage_limit
+	DefaultLimits StorageLimits
+}
+
+var (
+	tenantStatsMutex sync.RWMutex
+	// Última coleta de cada tenant
+	tenantStats = make(map[string]TenantStats)
+)
+
+// CollectStats coleta periodicamente o tamanho e o inchaço das tabelas de
+// cada tenant do catálogo, um tenant por vez, até o contexto ser cancelado.
+// O resultado é entregue a OnStats e fica disponível em LastTenantStats.
+func CollectStats(ctx context.Context, opts StatsCollectorOptions) {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+	if opts.Delay <= 0 {
+		opts.Delay = time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+
+	ticker := clock.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		collectAllStats(ctx, opts)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+func collectAllStats(ctx context.Context, opts StatsCollectorOptions) {
+	catalogs, err := ListTenants(ctx)
+	if err != nil {
+		logError("Stats collector: listing tenants failed: ", err)
+		return
+	}
+
+	// Sem os limites, a verificação é pulada nesta coleta: com um mapa vazio,
+	// os limites do catálogo seriam dados como liberados e voltariam a ser
+	// excedidos na coleta seguinte
+	limits, limitsErr := loadStorageLimits(ctx)
+	if limitsErr != nil {
+		logError("Stats collector: loading storage limits failed, skipping limit checks: ", limitsErr)
+	}
+
+	for i, catalog := range catalogs {
+		if catalog.Maintenance {
+			continue
+		}
+		if i > 0 {
+			if err := sleepContext(ctx, opts.Delay); err != nil {
+				return
+			}
+		}
+
+		stats, err := tenantStorageStats(ctx, catalog.SchemaName, opts.Timeout)
+		if err != nil {
+			logError("Stats collector failed for tenant ", catalog.SchemaName, ": ", err)
+			continue
+		}
+
+		if limitsErr == nil {
+			tenantLimits, found := limits[catalog.SchemaName]
+			if !found {
+				tenantLimits = opts.DefaultLimits
+			}
+			checkStorageLimits(&stats, tenantLimits)
+		}
+
+		tenantStatsMutex.Lock()
+		if limitsErr != nil {
+			stats.LimitsExceeded = tenantStats[stats.Tenant].LimitsExceeded
+		}
+		tenantStats[stats.Tenant] = stats
+		tenantStatsMutex.Unlock()
+
+		if opts.OnStats != nil {
+			opts.OnStats(stats)
+		}
+	}
+}
+
+// TenantStorageStats coleta o tamanho e o inchaço das tabelas do tenant.
+func TenantStorageStats(ctx context.Context, tenant string) (TenantStats, error) {
+	return tenantStorageStats(ctx, tenant, 30*time.Second)
+}
+
+func tenantStorageStats(ctx context.Context, tenant string, timeout time.Duration) (TenantStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := GetTenantConnectionWithOptions(ctx, tenant, TenantConnectOptions{})
+	if err != nil {
+		return TenantStats{}, err
+	}
+
+	rows, err := conn.QueryContext(ctx, `
+        SELECT c.relname, COALESCE(s.n_live_tup, 0), COALESCE(s.n_dead_tup, 0), pg_total_relation_size(c.oid)
+        FROM pg_class c
+        JOIN pg_namespace n ON n.oid = c.relnamespace
+        LEFT JOIN pg_stat_user_tables s ON s.relid = c.oid
+        WHERE n.nspname = $1 AND c.relkind IN ('r', 'm', 'p')
+        ORDER BY 4 DESC`, tenant)
+	if err != nil {
+		return TenantStats{}, err
+	}
+
+	tables, err := CollectRows(rows, func(rows *sql.Rows) (TableStats, error) {
+		var table TableStats
+		err := rows.Scan(&table.Table, &table.Rows, &table.DeadRows, &table.TotalBytes)
+		if total := table.Rows + table.DeadRows; total > 0 {
+			table.BloatRatio = float64(table.DeadRows) / float64(total)
+		}
+		return table, err
+	})
+	if err != nil {
+		return TenantStats{}, err
+	}
+
+	stats := TenantStats{Tenant: tenant, Tables: tables, CollectedAt: clock.Now()}
+	for _, table := range tables {
+		stats.TotalBytes += table.TotalBytes
+	}
+	return stats, nil
+}
+
+// LastTenantStats retorna a última coleta de cada tenant feita por
+// CollectStats, ordenada pelo tenant.
+func LastTenantStats() []TenantStats {
+	tenantStatsMutex.RLock()
+	defer tenantStatsMutex.RUnlock()
+
+	stats := make([]TenantStats, 0, len(tenantStats))
+	for _, s := range tenantStats {
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Tenant < stats[j].Tenant })
+	return stats
+}
+
+// tenantStorageBytes resume LastTenantStats no tamanho total de cada tenant,
+// publicado no expvar
+func tenantStorageBytes() map[string]int64 {
+	tenantStatsMutex.RLock()
+	defer tenantStatsMutex.RUnlock()
+
+	sizes := make(map[string]int64, len(tenantStats))
+	for tenant, s := range tenantStats {
+		sizes[tenant] = s.TotalBytes
+	}
+	return sizes
+}
@@ -0,0 +1,180 @@
+package connection
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// Quantidade máxima de prepared statements mantidos por tenant, usados por
+// ExecContext, QueryContext e QueryRowContext. Quando zero, as queries são
+// executadas sem prepare. Vale para os pools criados após a alteração.
+var StatementCacheSize = 0
+
+// stmtCacheSize é o StatementCacheSize do Manager, ou o do pacote
+func (m *Manager) stmtCacheSize() int {
+	if m.statementCacheSize != nil {
+		return *m.statementCacheSize
+	}
+	return StatementCacheSize
+}
+
+type StatementCacheStats struct {
+	Size      int     `json:"size"`
+	Hits      uint64  `json:"hits"`
+	Misses    uint64  `json:"misses"`
+	Evictions uint64  `json:"evictions"`
+	Ratio     float64 `json:"ratio"`
+}
+
+// stmtCache é um LRU de prepared statements de um pool, indexado pelo texto
+// da query. O database/sql prepara o statement em cada conexão do pool sob
+// demanda, então um único *sql.Stmt atende o pool inteiro.
+type stmtCache struct {
+	mu      sync.Mutex
+	db      *sql.DB
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type stmtCacheEntry struct {
+	query string
+	stmt  *sql.Stmt
+	// Chamadas usando o statement; um statement removido do cache só é
+	// fechado quando não está mais em uso
+	refs    int
+	evicted bool
+}
+
+// release devolve o statement obtido por get; deve ser chamada com s.mu
+func (s *stmtCache) release(entry *stmtCacheEntry) {
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+func newStmtCache(db *sql.DB, size int) *stmtCache {
+	if size <= 0 {
+		return nil
+	}
+	return &stmtCache{
+		db:      db,
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+// get retorna o statement da query, preparando-o quando não está no cache, e
+// a função que o devolve depois de executado. Até lá, o statement não é
+// fechado, mesmo que seja removido do cache por outra chamada.
+func (s *stmtCache) get(ctx context.Context, query string) (*sql.Stmt, func(), error) {
+	s.mu.Lock()
+	if element, found := s.entries[query]; found {
+		s.hits++
+		s.order.MoveToFront(element)
+		entry := element.Value.(*stmtCacheEntry)
+		entry.refs++
+		s.mu.Unlock()
+		return entry.stmt, s.releaseFunc(entry), nil
+	}
+	s.misses++
+	s.mu.Unlock()
+
+	// O prepare acontece fora do lock para não bloquear as outras queries
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Outra goroutine pode ter preparado a mesma query nesse meio tempo
+	if element, found := s.entries[query]; found {
+		stmt.Close()
+		entry := element.Value.(*stmtCacheEntry)
+		entry.refs++
+		return entry.stmt, s.releaseFunc(entry), nil
+	}
+
+	entry := &stmtCacheEntry{query: query, stmt: stmt, refs: 1}
+	s.entries[query] = s.order.PushFront(entry)
+	for s.order.Len() > s.size {
+		oldest := s.order.Remove(s.order.Back()).(*stmtCacheEntry)
+		delete(s.entries, oldest.query)
+		oldest.evicted = true
+		// Sem uso em andamento, fecha agora; senão, no último release. Rows
+		// abertas com o statement continuam válidas até serem fechadas.
+		oldest.refs++
+		s.release(oldest)
+		s.evictions++
+	}
+
+	return stmt, s.releaseFunc(entry), nil
+}
+
+func (s *stmtCache) releaseFunc(entry *stmtCacheEntry) func() {
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.release(entry)
+	}
+}
+
+func (s *stmtCache) stats() StatementCacheStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := StatementCacheStats{
+		Size:      s.order.Len(),
+		Hits:      s.hits,
+		Misses:    s.misses,
+		Evictions: s.evictions,
+	}
+	if total := s.hits + s.misses; total > 0 {
+		stats.Ratio = float64(s.hits) / float64(total)
+	}
+	return stats
+}
+
+// As funções abaixo usam o statement em cache quando disponível. Se o prepare
+// falhar (por exemplo, em queries com vários comandos), a query é executada
+// diretamente no pool.
+
+func (c Connection) execContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if c.stmts != nil {
+		if stmt, release, err := c.stmts.get(ctx, query); err == nil {
+			defer release()
+			return stmt.ExecContext(ctx, args...)
+		}
+	}
+	return c.DB.ExecContext(ctx, query, args...)
+}
+
+func (c Connection) queryRows(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if c.stmts != nil {
+		if stmt, release, err := c.stmts.get(ctx, query); err == nil {
+			defer release()
+			return stmt.QueryContext(ctx, args...)
+		}
+	}
+	return c.DB.QueryContext(ctx, query, args...)
+}
+
+func (c Connection) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if c.stmts != nil {
+		if stmt, release, err := c.stmts.get(ctx, query); err == nil {
+			defer release()
+			return stmt.QueryRowContext(ctx, args...)
+		}
+	}
+	return c.DB.QueryRowContext(ctx, query, args...)
+}
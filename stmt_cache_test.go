@@ -0,0 +1,111 @@
+package connection
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestStmtCacheEviction(t *testing.T) {
+	db, _ := stubDB(map[string]stubResult{"SELECT": {columns: []string{"n"}, rows: [][]driver.Value{{int64(1)}}}})
+	defer db.Close()
+	cache := newStmtCache(db, 2)
+
+	tests := []struct {
+		query     string
+		wantHit   bool
+		wantSize  int
+		evictions uint64
+	}{
+		{"SELECT 1", false, 1, 0},
+		{"SELECT 2", false, 2, 0},
+		{"SELECT 1", true, 2, 0},
+		// SELECT 2 é o menos usado
+		{"SELECT 3", false, 2, 1},
+		{"SELECT 1", true, 2, 1},
+		{"SELECT 2", false, 2, 2},
+	}
+	for _, tt := range tests {
+		before := cache.stats()
+		stmt, release, err := cache.get(context.Background(), tt.query)
+		if err != nil {
+			t.Fatalf("get(%q): %v", tt.query, err)
+		}
+		if _, err := stmt.Exec(); err != nil {
+			t.Fatalf("Exec(%q): %v", tt.query, err)
+		}
+		release()
+
+		stats := cache.stats()
+		if hit := stats.Hits > before.Hits; hit != tt.wantHit || stats.Size != tt.wantSize || stats.Evictions != tt.evictions {
+			t.Errorf("get(%q): hit %v size %d evictions %d, want %v %d %d", tt.query, hit, stats.Size, stats.Evictions, tt.wantHit, tt.wantSize, tt.evictions)
+		}
+	}
+}
+
+func TestStmtCacheEvictionWhileInUse(t *testing.T) {
+	db, _ := stubDB(map[string]stubResult{"SELECT": {columns: []string{"n"}, rows: [][]driver.Value{{int64(1)}}}})
+	defer db.Close()
+	cache := newStmtCache(db, 1)
+
+	stmt, release, err := cache.get(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Remove SELECT 1 do cache enquanto ele ainda não foi executado
+	if _, other, err := cache.get(context.Background(), "SELECT 2"); err != nil {
+		t.Fatal(err)
+	} else {
+		other()
+	}
+	if _, err := stmt.Exec(); err != nil {
+		t.Fatalf("evicted statement in use: %v", err)
+	}
+	release()
+	if _, err := stmt.Exec(); err == nil {
+		t.Fatal("evicted statement still open after release")
+	}
+}
+
+func TestStmtCacheConcurrentEviction(t *testing.T) {
+	db, _ := stubDB(map[string]stubResult{"SELECT": {columns: []string{"n"}, rows: [][]driver.Value{{int64(1)}}}})
+	defer db.Close()
+	// Cache menor que o número de queries, removendo statements o tempo todo
+	conn := Connection{DB: db, SearchPath: "stmt-cache-test", stmts: newStmtCache(db, 2)}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				query := fmt.Sprintf("SELECT %d", (i+j)%5)
+				if _, err := conn.execContext(context.Background(), query); err != nil {
+					errs <- fmt.Errorf("exec %q: %w", query, err)
+					return
+				}
+				rows, err := conn.queryRows(context.Background(), query)
+				if err != nil {
+					errs <- fmt.Errorf("query %q: %w", query, err)
+					return
+				}
+				for rows.Next() {
+				}
+				rows.Close()
+				var n int64
+				if err := conn.queryRow(context.Background(), query).Scan(&n); err != nil {
+					errs <- fmt.Errorf("query row %q: %w", query, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
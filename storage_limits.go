@@ -0,0 +1,119 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// StorageLimits são os limites de crescimento de um tenant verificados pelo
+// CollectStats. São limites de aviso: nada é bloqueado, apenas são emitidos
+// os eventos EventStorageLimitExceeded e EventStorageLimitCleared. Zero
+// desabilita o limite.
+type StorageLimits struct {
+	// Tamanho total das tabelas do tenant
+	MaxBytes int64
+	// Linhas e tamanho de cada tabela
+	MaxTableRows  int64
+	MaxTableBytes int64
+}
+
+var (
+	storageLimitsMutex sync.Mutex
+	// Limites excedidos na última coleta de cada tenant, pela medida
+	exceededLimits = make(map[string]map[string]bool)
+)
+
+// loadStorageLimits lê os limites da tabela catalog_storage_limit
+func loadStorageLimits(ctx context.Context) (map[string]StorageLimits, error) {
+	limits := make(map[string]StorageLimits)
+	err := defaultManager.readCatalog(ctx, func(ctx context.Context, db *sql.DB) error {
+		for tenant := range limits {
+			delete(limits, tenant)
+		}
+		rows, err := db.QueryContext(ctx, `SELECT schema_name, max_bytes, max_table_rows, max_table_bytes FROM catalog_storage_limit`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				tenant                           string
+				maxBytes, maxRows, maxTableBytes sql.NullInt64
+			)
+			if err := rows.Scan(&tenant, &maxBytes, &maxRows, &maxTableBytes); err != nil {
+				return err
+			}
+			limits[tenant] = StorageLimits{MaxBytes: maxBytes.Int64, MaxTableRows: maxRows.Int64, MaxTableBytes: maxTableBytes.Int64}
+		}
+		return rows.Err()
+	})
+	return limits, err
+}
+
+// exceeded devolve os limites excedidos pelas estatísticas, identificados
+// pela medida ("total bytes", "table orders rows") e com a descrição dos
+// valores
+func (l StorageLimits) exceeded(stats TenantStats) map[string]string {
+	exceeded := make(map[string]string)
+	check := func(measure string, value, limit int64) {
+		if limit > 0 && value > limit {
+			exceeded[measure] = fmt.Sprintf("%s %d > %d", measure, value, limit)
+		}
+	}
+
+	check("total bytes", stats.TotalBytes, l.MaxBytes)
+	for _, table := range stats.Tables {
+		check("table "+table.Table+" rows", table.Rows, l.MaxTableRows)
+		check("table "+table.Table+" bytes", table.TotalBytes, l.MaxTableBytes)
+	}
+	return exceeded
+}
+
+// checkStorageLimits emite os eventos dos limites que passaram a ser
+// excedidos ou deixaram de ser desde a coleta anterior, para que o evento não
+// se repita a cada coleta enquanto o tenant continua acima do limite
+func checkStorageLimits(stats *TenantStats, limits StorageLimits) {
+	current := limits.exceeded(*stats)
+
+	storageLimitsMutex.Lock()
+	previous := exceededLimits[stats.Tenant]
+	exceeded := make(map[string]bool, len(current))
+	for key := range current {
+		exceeded[key] = true
+	}
+	if len(exceeded) == 0 {
+		delete(exceededLimits, stats.Tenant)
+	} else {
+		exceededLimits[stats.Tenant] = exceeded
+	}
+	storageLimitsMutex.Unlock()
+
+	keys := make([]string, 0, len(current))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	stats.LimitsExceeded = stats.LimitsExceeded[:0]
+	for _, key := range keys {
+		stats.LimitsExceeded = append(stats.LimitsExceeded, current[key])
+		if !previous[key] {
+			logInfo("Storage limit exceeded for tenant ", stats.Tenant, ": ", current[key])
+			emit(EventStorageLimitExceeded, stats.Tenant, current[key])
+		}
+	}
+
+	cleared := make([]string, 0, len(previous))
+	for key := range previous {
+		if !exceeded[key] {
+			cleared = append(cleared, key)
+		}
+	}
+	sort.Strings(cleared)
+	for _, key := range cleared {
+		emit(EventStorageLimitCleared, stats.Tenant, key)
+	}
+}
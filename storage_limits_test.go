@@ -0,0 +1,60 @@
+package connection
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStorageLimitsExceeded(t *testing.T) {
+	stats := TenantStats{
+		Tenant:     "acme",
+		TotalBytes: 1000,
+		Tables: []TableStats{
+			{Table: "orders", Rows: 500, TotalBytes: 800},
+			{Table: "users", Rows: 10, TotalBytes: 200},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		limits StorageLimits
+		want   map[string]string
+	}{
+		{"no limits", StorageLimits{}, map[string]string{}},
+		{"below limits", StorageLimits{MaxBytes: 1000, MaxTableRows: 500, MaxTableBytes: 800}, map[string]string{}},
+		{"total bytes", StorageLimits{MaxBytes: 999}, map[string]string{"total bytes": "total bytes 1000 > 999"}},
+		{"table rows", StorageLimits{MaxTableRows: 100}, map[string]string{"table orders rows": "table orders rows 500 > 100"}},
+		{"every table", StorageLimits{MaxTableBytes: 100}, map[string]string{
+			"table orders bytes": "table orders bytes 800 > 100",
+			"table users bytes":  "table users bytes 200 > 100",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.limits.exceeded(stats); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("exceeded = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckStorageLimitsEventsOnChange(t *testing.T) {
+	var events []EventType
+	unsubscribe := Subscribe(func(e Event) {
+		if e.Tenant == "limits-test" {
+			events = append(events, e.Type)
+		}
+	})
+	defer unsubscribe()
+
+	limits := StorageLimits{MaxBytes: 100}
+	for _, total := range []int64{50, 150, 200, 80, 90} {
+		stats := TenantStats{Tenant: "limits-test", TotalBytes: total}
+		checkStorageLimits(&stats, limits)
+	}
+
+	want := []EventType{EventStorageLimitExceeded, EventStorageLimitCleared}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+}
@@ -0,0 +1,140 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+)
+
+// stubResult é o resultado de uma query de stubConnector
+type stubResult struct {
+	columns []string
+	rows    [][]driver.Value
+	err     error
+}
+
+// stubConnector responde às queries com o resultado da primeira chave contida
+// no texto da query, sem acessar a rede, e registra as queries recebidas
+type stubConnector struct {
+	mu      sync.Mutex
+	results map[string]stubResult
+	queries []string
+	// Contexto da última query
+	lastContext context.Context
+}
+
+func stubDB(results map[string]stubResult) (*sql.DB, *stubConnector) {
+	connector := &stubConnector{results: results}
+	return sql.OpenDB(connector), connector
+}
+
+func (c *stubConnector) Connect(context.Context) (driver.Conn, error) {
+	return stubConn{c}, nil
+}
+
+func (c *stubConnector) Driver() driver.Driver {
+	return errorDriver{}
+}
+
+func (c *stubConnector) result(query string) stubResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.queries = append(c.queries, query)
+	for key, result := range c.results {
+		if strings.Contains(query, key) {
+			return result
+		}
+	}
+	return stubResult{err: errNotSupported}
+}
+
+type stubConn struct {
+	c *stubConnector
+}
+
+func (s stubConn) Prepare(query string) (driver.Stmt, error) {
+	return stubStmt{conn: s, query: query}, nil
+}
+
+func (stubConn) Close() error {
+	return nil
+}
+
+func (stubConn) Begin() (driver.Tx, error) {
+	return nil, errNotSupported
+}
+
+func (s stubConn) QueryContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	s.c.mu.Lock()
+	s.c.lastContext = ctx
+	s.c.mu.Unlock()
+	result := s.c.result(query)
+	if result.err != nil {
+		return nil, result.err
+	}
+	return &stubRows{columns: result.columns, rows: result.rows}, nil
+}
+
+func (s stubConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	result := s.c.result(query)
+	if result.err != nil {
+		return nil, result.err
+	}
+	return driver.RowsAffected(len(result.rows)), nil
+}
+
+// stubStmt executa a query na conexão, como um prepare sem efeito
+type stubStmt struct {
+	conn  stubConn
+	query string
+}
+
+func (stubStmt) Close() error {
+	return nil
+}
+
+func (stubStmt) NumInput() int {
+	return -1
+}
+
+func (stubStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errNotSupported
+}
+
+func (stubStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errNotSupported
+}
+
+func (s stubStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s stubStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+type stubRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *stubRows) Columns() []string {
+	return r.columns
+}
+
+func (r *stubRows) Close() error {
+	return nil
+}
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,163 @@
+package connection
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrInvalidSessionAttrs = errors.New("invalid target_session_attrs")
+	ErrNoMatchingHost      = errors.New("no host matches target_session_attrs")
+)
+
+// Valores de target_session_attrs aceitos, os mesmos da libpq
+var sessionAttrsValues = map[string]bool{
+	"any":            true,
+	"read-write":     true,
+	"read-only":      true,
+	"primary":        true,
+	"standby":        true,
+	"prefer-standby": true,
+}
+
+// sessionAttrsConnector aplica o target_session_attrs, que o lib/pq ignora:
+// cada host do catálogo é tentado na ordem e a conexão é descartada quando o
+// servidor não tem o tipo de sessão exigido. Assim um cluster Patroni ou
+// outro cluster HA com todos os nós em server é atendido pelo primário atual
+// sem código de failover na aplicação.
+type sessionAttrsConnector struct {
+	hosts []*pq.Connector
+	attrs string
+}
+
+// newSessionAttrsConnector cria um connector por host, a partir da DSN e do
+// dialer montados por tenantConnector
+func newSessionAttrsConnector(dsn string, dialer pq.Dialer, attrs string) (*sessionAttrsConnector, error) {
+	if !sessionAttrsValues[attrs] {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSessionAttrs, attrs)
+	}
+
+	dialers := []pq.Dialer{dialer}
+	if failover, ok := dialer.(failoverDialer); ok && len(failover.addresses) > 1 {
+		dialers = dialers[:0]
+		for _, address := range failover.addresses {
+			host := failover
+			host.addresses = []string{address}
+			dialers = append(dialers, host)
+		}
+	}
+
+	c := &sessionAttrsConnector{attrs: attrs}
+	for _, dialer := range dialers {
+		connector, err := pq.NewConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		if dialer != nil {
+			connector.Dialer(dialer)
+		}
+		c.hosts = append(c.hosts, connector)
+	}
+
+	return c, nil
+}
+
+func (c *sessionAttrsConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	var (
+		errs []error
+		// Primeira conexão disponível, usada com prefer-standby quando
+		// nenhum host é standby
+		fallback driver.Conn
+	)
+
+	for _, host := range c.hosts {
+		conn, err := host.Connect(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if c.attrs == "any" {
+			return conn, nil
+		}
+
+		readOnly, recovery, err := sessionState(ctx, conn)
+		if err != nil {
+			conn.Close()
+			errs = append(errs, err)
+			continue
+		}
+
+		switch c.attrs {
+		case "read-write":
+			if !readOnly {
+				return c.use(conn, fallback)
+			}
+		case "read-only":
+			if readOnly {
+				return c.use(conn, fallback)
+			}
+		case "primary":
+			if !recovery {
+				return c.use(conn, fallback)
+			}
+		case "standby", "prefer-standby":
+			if recovery {
+				return c.use(conn, fallback)
+			}
+			if c.attrs == "prefer-standby" && fallback == nil {
+				fallback = conn
+				continue
+			}
+		}
+		conn.Close()
+	}
+
+	if fallback != nil {
+		return fallback, nil
+	}
+	errs = append(errs, fmt.Errorf("%w: %s", ErrNoMatchingHost, c.attrs))
+	return nil, errors.Join(errs...)
+}
+
+// use devolve a conexão escolhida, fechando a reservada para prefer-standby
+func (c *sessionAttrsConnector) use(conn, fallback driver.Conn) (driver.Conn, error) {
+	if fallback != nil {
+		fallback.Close()
+	}
+	return conn, nil
+}
+
+func (c *sessionAttrsConnector) Driver() driver.Driver {
+	return c.hosts[0].Driver()
+}
+
+// sessionState consulta se a sessão é somente leitura e se o servidor está
+// em recuperação (standby)
+func sessionState(ctx context.Context, conn driver.Conn) (readOnly, recovery bool, err error) {
+	queryer, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return false, false, errors.New("driver does not support queries")
+	}
+
+	rows, err := queryer.QueryContext(ctx, "SELECT current_setting('transaction_read_only') = 'on', pg_is_in_recovery()", nil)
+	if err != nil {
+		return false, false, err
+	}
+	defer rows.Close()
+
+	values := make([]driver.Value, 2)
+	if err := rows.Next(values); err != nil {
+		return false, false, err
+	}
+
+	readOnly, _ = values[0].(bool)
+	recovery, _ = values[1].(bool)
+	return readOnly, recovery, nil
+}
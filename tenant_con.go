@@ -1,54 +1,692 @@
 package connection
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"log"
+	"errors"
+	"runtime/pprof"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 const prefixConnection = "con-"
 
+// Tempo máximo padrão para buscar o tenant no catálogo e abrir a conexão
+var DefaultSetupTimeout = 30 * time.Second
+
+// Tamanho padrão dos pools, usado quando nem o chamador nem o catálogo
+// definem MaxOpenConns/MaxIdleConns. Quando zero, são usados os padrões do
+// database/sql.
+var (
+	DefaultMaxOpenConns = 0
+	DefaultMaxIdleConns = 0
+)
+
+var (
+	// Tempo que o pool do tenant permanece no cache antes de ser recriado
+	ConnectionTTL = 55 * time.Minute
+	// SetConnMaxLifetime e SetConnMaxIdleTime das conexões de cada pool
+	ConnMaxLifetime = time.Hour
+	ConnMaxIdleTime = time.Hour
+)
+
 type Connection struct {
 	DB         *sql.DB
 	SearchPath string
+
+	queryTimeout       time.Duration
+	slowQueryThreshold time.Duration
+	retryReads         RetryPolicy
+	guardStatements    bool
+	injectionCheck     InjectionPolicy
+	allowedQueries     *QueryAllowlist
+	detectFailover     bool
+	dialErrorThreshold int
+	// DefaultQueryTimeout vindo do catálogo, usado quando o chamador não
+	// informa um
+	defaultQueryTimeout time.Duration
+	stmts               *stmtCache
+	usage               *poolUsage
+	// Falhas de conexão consecutivas do pool
+	dialErrors *atomic.Int64
+	// Pool exclusivo de quem o criou, fora do cache
+	detached bool
+	// Nome do pool nomeado; vazio no pool principal
+	pool string
+	// PreferredRegion com que o pool foi criado
+	preferredRegion string
+	// Opções de criação do pool e se a diferença para elas já foi registrada
+	poolOptions       *poolOptions
+	poolOptionsWarned *atomic.Bool
+	manager           *Manager
+}
+
+// CachePolicy controla o uso do cache por GetTenantConnectionWithOptions. O
+// valor zero (CacheDefault) segue o padrão de SetDefaultTenantOptions, que
+// por sua vez usa o cache.
+type CachePolicy int
+
+const (
+	CacheDefault CachePolicy = iota
+	CacheEnabled
+	CacheDisabled
+)
+
+// Toggle é uma opção liga/desliga de TenantConnectOptions. Assim como em
+// CachePolicy, o valor zero (ToggleDefault) segue SetDefaultTenantOptions, e
+// ToggleOff desliga a opção mesmo quando ela está ligada no padrão.
+type Toggle int
+
+const (
+	ToggleDefault Toggle = iota
+	ToggleOn
+	ToggleOff
+)
+
+// On informa se a opção está ligada.
+func (t Toggle) On() bool {
+	return t == ToggleOn
+}
+
+// effective resolve ToggleDefault como desligado
+func (t Toggle) effective() Toggle {
+	if t == ToggleOn {
+		return ToggleOn
+	}
+	return ToggleOff
+}
+
+type TenantConnectOptions struct {
+	// Tempo máximo para buscar o tenant no catálogo e abrir a conexão.
+	// Quando zero, usa DefaultSetupTimeout. O prazo efetivo é o menor entre
+	// este valor e o deadline do contexto recebido.
+	SetupTimeout time.Duration
+	// Ignora o deadline e o cancelamento do contexto recebido durante a
+	// criação da conexão, útil para jobs em background.
+	IgnoreCallerDeadline Toggle
+	// Tempo máximo para estabelecer cada conexão física com o servidor do
+	// tenant, enviado na DSN como connect_timeout (arredondado para segundos).
+	DialTimeout time.Duration
+	// Tempo máximo do ping executado logo após abrir o pool. Quando zero,
+	// a primeira conexão é validada sem limite além do SetupTimeout.
+	PingTimeout time.Duration
+	// Timeout aplicado pelos métodos ExecContext, QueryContext, etc. quando o
+	// contexto recebido não possui deadline. Quando zero, nenhum é aplicado.
+	DefaultQueryTimeout time.Duration
+	// DSN usada no lugar do catálogo, útil para apontar um tenant para um
+	// Postgres local durante o desenvolvimento. Também pode ser definida pela
+	// variável de ambiente TENANT_<NOME>_DSN.
+	DSNOverride string
+	// Região em que o serviço está rodando. Quando o tenant possui uma réplica
+	// nessa região (tabela catalog_replica), ela é usada no lugar do servidor
+	// principal. O pool continua sendo um por tenant no cache.
+	PreferredRegion string
+	// Usa UTC como timezone das sessões. O parâmetro é enviado na DSN, então
+	// vale para todas as conexões físicas do pool, e não apenas para a
+	// primeira. Com DSNOverride, timezone=UTC deve ser incluído na própria DSN.
+	ForceUTC Toggle
+	// Parâmetros de sessão (GUCs) aplicados em todas as conexões do pool,
+	// como {"app.tenant_id": "42", "work_mem": "64MB"}. São enviados na DSN
+	// como options=-c nome=valor, na criação do pool; um pool em cache criado
+	// sem eles é recusado com PoolOptionsMismatchError.
+	SessionSettings map[string]string
+	// Timeout dos comandos no servidor (statement_timeout), enviado na DSN
+	// junto com SessionSettings, na criação do pool.
+	StatementTimeout time.Duration
+	// Tamanho do pool (sql.DB.SetMaxOpenConns/SetMaxIdleConns), aplicado na
+	// criação do pool. Quando zero, são usados os padrões do database/sql.
+	MaxOpenConns int
+	MaxIdleConns int
+	// Envia os parâmetros das queries em formato binário (binary_parameters
+	// do lib/pq), evitando o prepare implícito das queries com parâmetros.
+	BinaryParameters Toggle
+	// Intervalo de keepalive TCP das conexões com o servidor do tenant.
+	KeepAlive time.Duration
+	// Tipo de sessão exigido do servidor (any, read-write, read-only,
+	// primary, standby ou prefer-standby). Com vários hosts em server, cada
+	// um é tentado na ordem até encontrar um servidor com o tipo exigido. É
+	// incluído na DSN de BuildDSN para ferramentas baseadas na libpq.
+	TargetSessionAttrs string
+	// Parâmetros adicionais da DSN, com precedência sobre os demais.
+	ExtraParams map[string]string
+	// Função usada para abrir as conexões de rede com o servidor do tenant,
+	// permitindo rotear o tráfego por SOCKS, Teleport, Cloud SQL Auth Proxy,
+	// etc. Tem precedência sobre o dialer definido no catálogo.
+	DialFunc DialFunc
+	// Uso do cache de pools e do catálogo. Com CacheDisabled, cada chamada
+	// busca o tenant no catálogo e cria um pool novo, que não é compartilhado
+	// e deve ser fechado pelo chamador (conn.Close).
+	Cache CachePolicy
+	// Cria um pool exclusivo, fora do cache e sem compartilhamento com as
+	// demais chamadas, fechado de fato por conn.Close. Para migrations, jobs
+	// em massa e testes que não podem interferir nos pools compartilhados.
+	Detached Toggle
+	// Tempo do pool no cache quando ele é criado por esta chamada. Quando
+	// zero, usa ConnectionTTL. Não tem efeito com CacheDisabled.
+	CacheTTL time.Duration
+	// Queries executadas pelos métodos da Connection que demorarem mais que
+	// este tempo são escritas no log. Quando zero, nenhuma é registrada.
+	SlowQueryThreshold time.Duration
+	// Novas tentativas dos SELECTs executados por QueryContext e
+	// QueryRowContext que falharem por erros transitórios de rede. Quando
+	// MaxAttempts é zero, não há novas tentativas.
+	RetryReads RetryPolicy
+	// Recusa com StatementDeniedError os comandos de DeniedStatements (DROP,
+	// TRUNCATE, DELETE sem WHERE...) em ExecContext, PrepareContext e
+	// ExecBatch. Para serviços que não deveriam alterar a estrutura do banco.
+	GuardStatements Toggle
+	// Verificação das queries que parecem ter valores concatenados em vez de
+	// parâmetros ($1, $2...). Com InjectionDeny, ExecContext, PrepareContext,
+	// QueryContext e ExecBatch recusam a query; QueryRowContext apenas
+	// escreve no log.
+	InjectionCheck InjectionPolicy
+	// Restringe a conexão às queries da lista; as demais são recusadas com
+	// QueryNotAllowedError; em QueryRowContext, o erro é retornado pelo Scan.
+	AllowedQueries *QueryAllowlist
+	// Descarta o pool quando uma chamada falha com erro de failover (escrita
+	// em servidor somente leitura ou servidor encerrado), para que as novas
+	// conexões alcancem o novo primário em segundos. Para clusters Aurora e
+	// outros com failover por DNS.
+	DetectFailover Toggle
+	// SetConnMaxLifetime do pool do tenant, aplicado na criação do pool.
+	// Quando zero, usa ConnMaxLifetime. Um valor curto renova as conexões, e
+	// a resolução do nome do servidor, com mais frequência.
+	ConnMaxLifetime time.Duration
+	// Falhas de conexão consecutivas (dial ou DNS) que descartam o pool, para
+	// que o próximo GetTenantConnection resolva o nome do servidor novamente.
+	// Quando zero, o pool não é descartado.
+	DialErrorThreshold int
+	// Pool nomeado do tenant, separado do pool principal e com o seu próprio
+	// tamanho (SetPoolLimits), como "batch" para exportações e jobs pesados,
+	// que assim não esgotam as conexões das requisições interativas. Vazio
+	// usa o pool principal. Os pools nomeados são descartados junto com o
+	// principal em failovers e rotações de senha.
+	Pool string
+
+	// RequireTLS do Manager que abre o pool; nil usa a variável do pacote
+	requireTLS *bool
 }
 
 func GetTenantConnection(tenant string) (Connection, error) {
-	Mutex.Lock()
-	defer Mutex.Unlock()
+	return GetTenantConnectionWithOptions(context.Background(), tenant, TenantConnectOptions{})
+}
+
+func GetTenantConnectionWithOptions(ctx context.Context, tenant string, opts TenantConnectOptions) (Connection, error) {
+	return defaultManager.GetTenantConnection(ctx, tenant, opts)
+}
+
+// GetTenantConnection retorna o pool do tenant no catálogo do Manager,
+// criando-o quando não estiver no cache. Equivale ao
+// GetTenantConnectionWithOptions do pacote.
+func (m *Manager) GetTenantConnection(ctx context.Context, tenant string, opts TenantConnectOptions) (_ Connection, err error) {
+	defer scrubPanic(&err)
+
+	opts = applyPoolLimits(opts)
+	opts = m.defaultOptions().apply(opts)
+
+	if m.inMaintenance(tenant) {
+		return Connection{}, ErrTenantInMaintenance
+	}
+
+	if opts.Detached.On() || opts.Cache == CacheDisabled {
+		return m.uncachedConnection(ctx, tenant, opts)
+	}
 
 	// Verifica se já existe uma conexão no cache para o tenant
-	if conn, found := Connections.Get(prefixConnection + tenant); found {
-		return conn.(Connection), nil
+	if conn, found, err := m.cachedConnection(tenant, opts); found {
+		return conn, err
 	}
 
-	catalog, err := GetTenant(tenant)
+	ctx, cancel := setupContext(ctx, opts)
+	defer cancel()
+
+	key := poolKey(tenant, opts.Pool)
+	connection, err := m.createPool(ctx, key, func() (Connection, error) {
+		// Outra chamada pode ter terminado a criação antes desta começar
+		if conn, found := m.cache.Get(prefixConnection + key); found {
+			return conn.(Connection), nil
+		}
+
+		if err := m.creationLimiter.wait(ctx); err != nil {
+			return Connection{}, err
+		}
+		connection, err := m.newConnection(ctx, tenant, opts)
+		if err != nil {
+			return Connection{}, err
+		}
+
+		// Salva a conexão no cache
+		ttl := opts.CacheTTL
+		if ttl <= 0 {
+			ttl = currentSettings().connectionTTL
+		}
+		m.mu.Lock()
+		m.cache.Set(prefixConnection+key, connection, ttl)
+		m.trackConnection(connection)
+		m.mu.Unlock()
+		m.emit(EventCreated, tenant, "")
+		return connection, nil
+	})
 	if err != nil {
 		return Connection{}, err
 	}
 
-	uri := fmt.Sprintf("%s://%s:%s@%s/%s?sslmode=disable", catalog.Driver, catalog.UserName, catalog.Password, catalog.Server, catalog.DatabaseName)
-	dbCon, err := sql.Open("postgres", uri)
+	// O pool pode ter sido criado por outra chamada, com outras opções
+	if err := connection.checkPoolOptions(opts); err != nil {
+		return Connection{}, err
+	}
+	return connection.withOptions(opts), nil
+}
+
+// poolCreation é a criação de um pool em andamento, aguardada pelas demais
+// chamadas para o mesmo pool
+type poolCreation struct {
+	done chan struct{}
+	conn Connection
+	err  error
+	// Chamadas aguardando, protegido por creationsMutex
+	waiters int
+}
+
+// createPool executa create uma vez por pool de cada vez: as demais chamadas
+// para o mesmo pool aguardam o resultado, ou o fim do próprio contexto, e os
+// pools de outros tenants são criados em paralelo, sem esperar por um
+// servidor fora do ar.
+func (m *Manager) createPool(ctx context.Context, key string, create func() (Connection, error)) (Connection, error) {
+	for {
+		m.creationsMutex.Lock()
+		creation, waiting := m.creations[key]
+		if waiting {
+			creation.waiters++
+		} else {
+			creation = &poolCreation{done: make(chan struct{})}
+			if m.creations == nil {
+				m.creations = make(map[string]*poolCreation)
+			}
+			m.creations[key] = creation
+		}
+		m.creationsMutex.Unlock()
+
+		if !waiting {
+			creation.conn, creation.err = create()
+
+			m.creationsMutex.Lock()
+			delete(m.creations, key)
+			m.creationsMutex.Unlock()
+			close(creation.done)
+			return creation.conn, creation.err
+		}
+
+		select {
+		case <-creation.done:
+		case <-ctx.Done():
+			return Connection{}, ctx.Err()
+		}
+
+		// O prazo de quem criava o pool não vale para esta chamada, que tenta
+		// criá-lo novamente
+		if creation.err != nil && ctx.Err() == nil &&
+			(errors.Is(creation.err, context.Canceled) || errors.Is(creation.err, context.DeadlineExceeded)) {
+			continue
+		}
+		return creation.conn, creation.err
+	}
+}
+
+// uncachedConnection cria um pool que não é lido nem salvo no cache. Com
+// CacheDisabled, o tenant também é buscado diretamente no catálogo.
+func (m *Manager) uncachedConnection(ctx context.Context, tenant string, opts TenantConnectOptions) (Connection, error) {
+	ctx, cancel := setupContext(ctx, opts)
+	defer cancel()
+
+	if err := m.creationLimiter.wait(ctx); err != nil {
+		return Connection{}, err
+	}
+
+	connection, err := m.newConnection(ctx, tenant, opts)
 	if err != nil {
 		return Connection{}, err
 	}
+	connection.detached = true
+	return connection.withOptions(opts), nil
+}
 
-	log.Println("Connection create for tenant ", tenant)
-	// Configura o search_path para usar o tenant
-	_, err = dbCon.Exec(fmt.Sprintf("SET search_path TO %s", tenant))
+// Close fecha o pool quando ele foi criado com Detached ou CacheDisabled. Os
+// pools do cache são compartilhados e fechados pelo próprio pacote, então
+// para eles Close não faz nada.
+func (c Connection) Close() error {
+	if !c.detached {
+		return nil
+	}
+	return c.DB.Close()
+}
+
+// newConnection cria e configura o pool do tenant
+func (m *Manager) newConnection(ctx context.Context, tenant string, opts TenantConnectOptions) (Connection, error) {
+	dbCon, effective, err := m.createConnection(ctx, tenant, opts)
+	if isAuthFailure(err) {
+		// O catálogo em cache pode estar com a senha anterior a uma rotação
+		logError("Authentication failed for tenant ", tenant, ", reloading catalog")
+		m.forgetCatalog(ctx, tenant)
+		dbCon, effective, err = m.createConnection(ctx, tenant, opts)
+	}
 	if err != nil {
-		log.Println("Connection create for error  ", err)
 		return Connection{}, err
 	}
+	opts = effective
 
-	// Salva a conexão no cache
-	connection := Connection{DB: dbCon, SearchPath: tenant}
-	Connections.SetWithTTL(prefixConnection+tenant, connection, 1, 55*time.Minute)
-	connection.DB.SetConnMaxLifetime(1 * time.Hour)
-	connection.DB.SetConnMaxIdleTime(1 * time.Hour)
+	connection := Connection{
+		DB:                  dbCon,
+		SearchPath:          tenant,
+		defaultQueryTimeout: opts.DefaultQueryTimeout,
+		stmts:               newStmtCache(dbCon, m.stmtCacheSize()),
+		usage:               m.newPoolUsage(tenant),
+		dialErrors:          new(atomic.Int64),
+		pool:                opts.Pool,
+		preferredRegion:     opts.PreferredRegion,
+		poolOptionsWarned:   new(atomic.Bool),
+		manager:             m,
+	}
+	defaults := currentSettings()
+	if opts.ConnMaxLifetime <= 0 {
+		opts.ConnMaxLifetime = defaults.connMaxLifetime
+	}
+	connection.DB.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	connection.DB.SetConnMaxIdleTime(defaults.connMaxIdleTime)
+	if opts.MaxOpenConns <= 0 {
+		opts.MaxOpenConns = defaults.maxOpenConns
+	}
+	if opts.MaxIdleConns <= 0 {
+		opts.MaxIdleConns = defaults.maxIdleConns
+	}
+	if opts.MaxOpenConns > 0 {
+		connection.DB.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		connection.DB.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	// As opções efetivas, para comparar com as das próximas chamadas
+	connection.poolOptions = newPoolOptions(opts)
+	connection.poolOptions.ForceUTC = opts.ForceUTC.effective()
+	connection.poolOptions.BinaryParameters = opts.BinaryParameters.effective()
 
 	return connection, nil
 }
+
+// cachedConnection retorna o pool em cache; com erro quando ele foi criado
+// sem uma opção de sessão pedida em opts
+func (m *Manager) cachedConnection(tenant string, opts TenantConnectOptions) (Connection, bool, error) {
+	conn, found := m.cache.Get(prefixConnection + poolKey(tenant, opts.Pool))
+	if !found {
+		return Connection{}, false, nil
+	}
+
+	m.emit(EventCacheHit, tenant, "")
+	cached := conn.(Connection)
+	if err := cached.checkPoolOptions(opts); err != nil {
+		return Connection{}, true, err
+	}
+	return cached.withOptions(opts), true, nil
+}
+
+// createConnection abre o pool do tenant e valida a primeira conexão
+func (m *Manager) createConnection(ctx context.Context, tenant string, opts TenantConnectOptions) (*sql.DB, TenantConnectOptions, error) {
+	dbCon, opts, err := m.openConnection(ctx, tenant, opts)
+	if err != nil {
+		return nil, opts, err
+	}
+
+	if opts.PingTimeout > 0 {
+		if err := ping(ctx, dbCon, opts.PingTimeout); err != nil {
+			logError("Connection ping for tenant ", tenant, " failed: ", err)
+			m.emit(EventUnhealthy, tenant, err.Error())
+			dbCon.Close()
+			return nil, opts, err
+		}
+	}
+
+	logInfo("Connection create for tenant ", tenant, requestLogFields(ctx))
+	// Sem o ping, valida a primeira conexão, que já abre com o search_path do
+	// tenant
+	if opts.PingTimeout <= 0 {
+		err = dbCon.PingContext(ctx)
+	}
+	if err != nil {
+		logError("Connection create for error  ", err)
+		m.emit(EventUnhealthy, tenant, err.Error())
+		dbCon.Close()
+		return nil, opts, err
+	}
+
+	return dbCon, opts, nil
+}
+
+// openConnection abre o pool do tenant e retorna as opções efetivas, já
+// mescladas com os padrões do tenant no catálogo
+func (m *Manager) openConnection(ctx context.Context, tenant string, opts TenantConnectOptions) (*sql.DB, TenantConnectOptions, error) {
+	if dsn := dsnOverride(tenant, opts); dsn != "" {
+		logInfo("Using DSN override for tenant ", tenant)
+		connector, err := pq.NewConnector(dsn)
+		if err != nil {
+			return nil, opts, err
+		}
+		return sql.OpenDB(cancelConnector{searchPathConnector{Connector: connector, schema: tenant}}), opts, nil
+	}
+
+	var (
+		catalog *Catalog
+		err     error
+	)
+	if opts.Cache == CacheDisabled {
+		catalog, err = m.queryTenant(ctx, tenant)
+	} else {
+		catalog, err = m.GetTenant(ctx, tenant)
+	}
+	if err != nil {
+		return nil, opts, err
+	}
+	if catalog.Maintenance {
+		return nil, opts, ErrTenantInMaintenance
+	}
+
+	catalog, err = m.regionalCatalog(ctx, catalog, opts.PreferredRegion)
+	if err != nil {
+		return nil, opts, err
+	}
+
+	opts = m.tenantOverrides(tenant).apply(opts)
+	opts = catalog.Defaults.apply(opts)
+	opts.requireTLS = m.requireTLS
+	db, err := openTenantDB(catalog, opts)
+	return db, opts, err
+}
+
+// O pool em cache é compartilhado, então as opções de execução são aplicadas
+// na cópia devolvida a cada chamada.
+func (c Connection) withOptions(opts TenantConnectOptions) Connection {
+	c.queryTimeout = opts.DefaultQueryTimeout
+	if c.queryTimeout <= 0 {
+		c.queryTimeout = c.defaultQueryTimeout
+	}
+	c.slowQueryThreshold = opts.SlowQueryThreshold
+	c.retryReads = opts.RetryReads
+	c.guardStatements = opts.GuardStatements.On()
+	c.injectionCheck = opts.InjectionCheck
+	c.allowedQueries = opts.AllowedQueries
+	c.detectFailover = opts.DetectFailover.On()
+	c.dialErrorThreshold = opts.DialErrorThreshold
+	return c
+}
+
+func ping(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return db.PingContext(ctx)
+}
+
+func setupContext(ctx context.Context, opts TenantConnectOptions) (context.Context, context.CancelFunc) {
+	timeout := opts.SetupTimeout
+	if timeout <= 0 {
+		timeout = currentSettings().setupTimeout
+	}
+
+	if opts.IgnoreCallerDeadline.On() {
+		ctx = context.Background()
+	}
+
+	// context.WithTimeout mantém o deadline do pai quando ele é menor
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Os métodos abaixo satisfazem a interface DBTX gerada pelo sqlc e executam
+// as queries com o label "tenant" no pprof, identificando o tenant nos perfis.
+
+func (c Connection) ExecContext(ctx context.Context, query string, args ...interface{}) (_ sql.Result, err error) {
+	defer scrubPanic(&err)
+
+	if err := c.checkCanceled(ctx); err != nil {
+		return nil, err
+	}
+	if err := c.guardStatement(query); err != nil {
+		return nil, err
+	}
+	if err := c.checkInjection(query, args); err != nil {
+		return nil, err
+	}
+	if err := c.checkAllowed(query); err != nil {
+		return nil, err
+	}
+
+	var result sql.Result
+	c.touch()
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+
+	start := clock.Now()
+	pprof.Do(ctx, c.labels(), func(ctx context.Context) {
+		result, err = c.execContext(ctx, query, args...)
+		c.recordQuery(query, args, err)
+	})
+	c.logSlowQuery(ctx, start, query)
+	return result, c.queryError(start, err)
+}
+
+func (c Connection) PrepareContext(ctx context.Context, query string) (_ *sql.Stmt, err error) {
+	defer scrubPanic(&err)
+
+	if err := c.checkCanceled(ctx); err != nil {
+		return nil, err
+	}
+	if err := c.guardStatement(query); err != nil {
+		return nil, err
+	}
+	if err := c.checkInjection(query, nil); err != nil {
+		return nil, err
+	}
+	if err := c.checkAllowed(query); err != nil {
+		return nil, err
+	}
+
+	var stmt *sql.Stmt
+	c.touch()
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+
+	start := clock.Now()
+	pprof.Do(ctx, c.labels(), func(ctx context.Context) {
+		stmt, err = c.DB.PrepareContext(ctx, query)
+	})
+	return stmt, c.queryError(start, err)
+}
+
+func (c Connection) QueryContext(ctx context.Context, query string, args ...interface{}) (_ *sql.Rows, err error) {
+	defer scrubPanic(&err)
+
+	if err := c.checkCanceled(ctx); err != nil {
+		return nil, err
+	}
+	if err := c.checkInjection(query, args); err != nil {
+		return nil, err
+	}
+	if err := c.checkAllowed(query); err != nil {
+		return nil, err
+	}
+
+	var rows *sql.Rows
+	c.touch()
+	ctx, cancel := c.queryContext(ctx)
+	if cancelsOnClose(c.DB) {
+		ctx = withRowsCancel(ctx, cancel)
+	}
+
+	start := clock.Now()
+	pprof.Do(ctx, c.labels(), func(ctx context.Context) {
+		err = c.retryRead(ctx, query, func() error {
+			rows, err = c.queryRows(ctx, query, args...)
+			c.recordQuery(query, args, err)
+			return err
+		})
+	})
+	c.logSlowQuery(ctx, start, query)
+	if err != nil {
+		cancel()
+		return nil, c.queryError(start, err)
+	}
+	c.countDialErrors(nil)
+
+	// O contexto precisa continuar válido enquanto as linhas são lidas: nos
+	// pools do pacote o cancelamento é feito pelo rows.Close (ver
+	// cancelConnector); nos demais, pelo próprio timeout ao expirar.
+	return rows, nil
+}
+
+func (c Connection) QueryRowContext(ctx context.Context, query string, args ...interface{}) (row *sql.Row) {
+	defer scrubRowPanic(&row)
+
+	if err := c.checkCanceled(ctx); err != nil {
+		return errorRow(err)
+	}
+	// Sem como devolver o erro em um *sql.Row, a query suspeita é apenas
+	// registrada no log
+	_ = c.checkInjection(query, args)
+
+	if err := c.checkAllowed(query); err != nil {
+		return errorRow(err)
+	}
+
+	// Assim como em QueryContext, o cancelamento é feito ao fechar as linhas,
+	// pois o Scan acontece depois do retorno.
+	c.touch()
+	ctx, cancel := c.queryContext(ctx)
+	if cancelsOnClose(c.DB) {
+		ctx = withRowsCancel(ctx, cancel)
+	}
+
+	start := clock.Now()
+	pprof.Do(ctx, c.labels(), func(ctx context.Context) {
+		c.retryRead(ctx, query, func() error {
+			row = c.queryRow(ctx, query, args...)
+			c.recordQuery(query, args, row.Err())
+			return row.Err()
+		})
+	})
+	c.logSlowQuery(ctx, start, query)
+	if row.Err() != nil {
+		cancel()
+	}
+	return row
+}
+
+func (c Connection) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, hasDeadline := ctx.Deadline(); hasDeadline || c.queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.queryTimeout)
+}
+
+func (c Connection) labels() pprof.LabelSet {
+	return pprof.Labels("tenant", c.SearchPath)
+}
@@ -0,0 +1,78 @@
+package connection
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TenantDefaults são as opções de conexão padrão de um tenant, guardadas na
+// coluna connect_options (jsonb) do catálogo, permitindo ajustar cada tenant
+// sem uma nova versão da aplicação:
+//
+//	{"max_open_conns": 20, "force_utc": true, "statement_timeout": "30s"}
+//
+// As opções informadas pelo chamador têm precedência sobre as do catálogo.
+type TenantDefaults struct {
+	MaxOpenConns        int           `json:"max_open_conns,omitempty"`
+	MaxIdleConns        int           `json:"max_idle_conns,omitempty"`
+	ForceUTC            bool          `json:"force_utc,omitempty"`
+	StatementTimeout    time.Duration `json:"statement_timeout,omitempty"`
+	DefaultQueryTimeout time.Duration `json:"default_query_timeout,omitempty"`
+}
+
+// Formato da coluna, com as durações no formato do time.ParseDuration
+type catalogDefaults struct {
+	MaxOpenConns        int    `json:"max_open_conns"`
+	MaxIdleConns        int    `json:"max_idle_conns"`
+	ForceUTC            bool   `json:"force_utc"`
+	StatementTimeout    string `json:"statement_timeout"`
+	DefaultQueryTimeout string `json:"default_query_timeout"`
+}
+
+func parseTenantDefaults(data []byte) (TenantDefaults, error) {
+	var (
+		raw      catalogDefaults
+		defaults TenantDefaults
+		err      error
+	)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return defaults, fmt.Errorf("invalid catalog connect_options: %w", err)
+	}
+
+	defaults.MaxOpenConns = raw.MaxOpenConns
+	defaults.MaxIdleConns = raw.MaxIdleConns
+	defaults.ForceUTC = raw.ForceUTC
+	if raw.StatementTimeout != "" {
+		if defaults.StatementTimeout, err = time.ParseDuration(raw.StatementTimeout); err != nil {
+			return defaults, fmt.Errorf("invalid catalog connect_options statement_timeout: %w", err)
+		}
+	}
+	if raw.DefaultQueryTimeout != "" {
+		if defaults.DefaultQueryTimeout, err = time.ParseDuration(raw.DefaultQueryTimeout); err != nil {
+			return defaults, fmt.Errorf("invalid catalog connect_options default_query_timeout: %w", err)
+		}
+	}
+
+	return defaults, nil
+}
+
+// apply preenche as opções não informadas pelo chamador
+func (d TenantDefaults) apply(opts TenantConnectOptions) TenantConnectOptions {
+	if opts.MaxOpenConns == 0 {
+		opts.MaxOpenConns = d.MaxOpenConns
+	}
+	if opts.MaxIdleConns == 0 {
+		opts.MaxIdleConns = d.MaxIdleConns
+	}
+	if opts.StatementTimeout == 0 {
+		opts.StatementTimeout = d.StatementTimeout
+	}
+	if opts.DefaultQueryTimeout == 0 {
+		opts.DefaultQueryTimeout = d.DefaultQueryTimeout
+	}
+	if opts.ForceUTC == ToggleDefault && d.ForceUTC {
+		opts.ForceUTC = ToggleOn
+	}
+	return opts
+}
@@ -0,0 +1,46 @@
+package connection
+
+import (
+	"context"
+)
+
+// TenantRepo cria, a partir do tenant do contexto, o valor usado para acessar
+// os dados, como os Queries gerados pelo sqlc, sem que os handlers precisem
+// obter a conexão:
+//
+//	var queries = connection.NewTenantRepo(func(conn connection.Connection) *db.Queries {
+//		return db.New(conn)
+//	})
+//
+//	q, err := queries.Get(ctx)
+//
+// Em um contexto de ContextWithConnection, o valor é criado a cada chamada. Em
+// um contexto de WithRequestConnection, é criado no primeiro uso e reutilizado
+// até o fim do request.
+type TenantRepo[T any] struct {
+	factory func(conn Connection) T
+}
+
+func NewTenantRepo[T any](factory func(conn Connection) T) *TenantRepo[T] {
+	return &TenantRepo[T]{factory: factory}
+}
+
+// Get retorna o valor do tenant do contexto. Sem tenant no contexto, retorna
+// ErrMissingTenant.
+func (r *TenantRepo[T]) Get(ctx context.Context) (T, error) {
+	var zero T
+
+	if conn, ok := ctx.Value(connectionContextKey{}).(Connection); ok {
+		return r.factory(conn), nil
+	}
+
+	holder, ok := ctx.Value(requestConnectionKey{}).(*requestConnection)
+	if !ok {
+		return zero, ErrMissingTenant
+	}
+	value, err := holder.value(ctx, r, func(conn Connection) interface{} { return r.factory(conn) })
+	if err != nil {
+		return zero, err
+	}
+	return value.(T), nil
+}
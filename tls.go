@@ -0,0 +1,68 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+var ErrInsecureConnection = errors.New("insecure tenant connection")
+
+// Quando verdadeiro, BuildDSN e a abertura dos pools recusam tenants cujo
+// sslmode não exige TLS (disable, allow ou prefer). É habilitado por padrão em
+// produção, detectada pelas variáveis APP_ENV, GO_ENV ou ENV com o valor
+// "production" ou "prod". Conexões por socket Unix não passam pela rede e não
+// são verificadas.
+var RequireTLS = productionEnvironment()
+
+// InsecureConnectionError indica que a DSN do tenant seria montada sem TLS com
+// RequireTLS habilitado. errors.Is reconhece ErrInsecureConnection.
+type InsecureConnectionError struct {
+	Tenant  string
+	SSLMode string
+}
+
+func (e *InsecureConnectionError) Error() string {
+	return fmt.Sprintf("%v: tenant %s uses sslmode=%s", ErrInsecureConnection, e.Tenant, e.SSLMode)
+}
+
+func (e *InsecureConnectionError) Is(target error) bool {
+	return target == ErrInsecureConnection
+}
+
+func productionEnvironment() bool {
+	switch strings.ToLower(currentEnvironment()) {
+	case "production", "prod":
+		return true
+	}
+	return false
+}
+
+// Nome do ambiente, da primeira das variáveis APP_ENV, GO_ENV ou ENV definida
+func currentEnvironment() string {
+	for _, name := range []string{"APP_ENV", "GO_ENV", "ENV"} {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func checkTLS(catalog *Catalog, params url.Values, opts TenantConnectOptions) error {
+	required := currentSettings().requireTLS
+	if opts.requireTLS != nil {
+		required = *opts.requireTLS
+	}
+	if !required {
+		return nil
+	}
+
+	switch mode := params.Get("sslmode"); mode {
+	case "require", "verify-ca", "verify-full":
+		return nil
+	default:
+		return &InsecureConnectionError{Tenant: catalog.SchemaName, SSLMode: mode}
+	}
+}
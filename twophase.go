@@ -0,0 +1,297 @@
+package connection
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Prefixo dos identificadores (gid) das transações preparadas por
+// WithTwoPhaseCommit, usado na recuperação para ignorar as de outros sistemas
+const preparedTxPrefix = "tenant_connection_2pc_"
+
+// TwoPhaseParticipants indica os bancos que participam da transação
+type TwoPhaseParticipants struct {
+	Tenants []string
+	// Inclui o banco do catálogo na transação
+	Catalog bool
+}
+
+// DistributedTx dá acesso às conexões de cada participante durante a função
+// passada a WithTwoPhaseCommit. Cada conexão já está dentro de uma transação
+// e, nos tenants, com o search_path do tenant.
+type DistributedTx struct {
+	tenants map[string]*sql.Conn
+	catalog *sql.Conn
+}
+
+// Tenant retorna a conexão do tenant, ou nil quando ele não participa
+func (d *DistributedTx) Tenant(tenant string) *sql.Conn {
+	return d.tenants[tenant]
+}
+
+// Catalog retorna a conexão do catálogo, ou nil quando ele não participa
+func (d *DistributedTx) Catalog() *sql.Conn {
+	return d.catalog
+}
+
+type twoPhaseParticipant struct {
+	name     string
+	conn     *sql.Conn
+	gid      string
+	open     bool
+	prepared bool
+}
+
+// WithTwoPhaseCommit executa fn em transações abertas em todos os participantes
+// e as confirma de forma atômica com PREPARE TRANSACTION / COMMIT PREPARED.
+// A decisão de commit é registrada na tabela catalog_prepared_tx antes da
+// segunda fase, permitindo que RecoverPreparedTransactions conclua transações
+// que ficaram pendentes caso o processo caia no meio do commit.
+//
+// Os servidores precisam ter max_prepared_transactions maior que zero. Deve
+// ser usado apenas em operações raras, pois transações preparadas seguram
+// locks até serem concluídas.
+func WithTwoPhaseCommit(ctx context.Context, participants TwoPhaseParticipants, fn func(ctx context.Context, dtx *DistributedTx) error) error {
+	id, err := newPreparedTxID()
+	if err != nil {
+		return err
+	}
+
+	var parts []*twoPhaseParticipant
+	defer func() {
+		for _, p := range parts {
+			p.abort()
+		}
+	}()
+
+	dtx := &DistributedTx{tenants: make(map[string]*sql.Conn, len(participants.Tenants))}
+	for _, tenant := range participants.Tenants {
+		p, err := beginTenantParticipant(ctx, tenant)
+		if p != nil {
+			parts = append(parts, p)
+		}
+		if err != nil {
+			return fmt.Errorf("begin %s: %w", tenant, err)
+		}
+		dtx.tenants[tenant] = p.conn
+	}
+	if participants.Catalog {
+		p, err := beginParticipant(ctx, "catalog", defaultManager.catalog)
+		if p != nil {
+			parts = append(parts, p)
+		}
+		if err != nil {
+			return fmt.Errorf("begin catalog: %w", err)
+		}
+		dtx.catalog = p.conn
+	}
+
+	if err := fn(ctx, dtx); err != nil {
+		return err
+	}
+
+	// Primeira fase: se qualquer participante falhar, os já preparados são
+	// desfeitos pelo abort
+	for i, p := range parts {
+		p.gid = fmt.Sprintf("%s%s_%d", preparedTxPrefix, id, i)
+		_, err := p.conn.ExecContext(ctx, "PREPARE TRANSACTION "+pq.QuoteLiteral(p.gid))
+		// Mesmo quando falha, o PREPARE encerra a transação da sessão
+		p.open = false
+		if err != nil {
+			return fmt.Errorf("prepare %s: %w", p.name, err)
+		}
+		p.prepared = true
+	}
+
+	names := make([]string, len(parts))
+	for i, p := range parts {
+		names[i] = p.name
+	}
+	_, err = defaultManager.catalog.ExecContext(ctx, `INSERT INTO catalog_prepared_tx (id, participants) VALUES ($1, $2)`, id, pq.Array(names))
+	if err != nil {
+		return fmt.Errorf("record commit decision: %w", err)
+	}
+
+	// Segunda fase: a decisão já foi registrada, então falhas aqui não desfazem
+	// nada e ficam para RecoverPreparedTransactions
+	var failed bool
+	for _, p := range parts {
+		p.prepared = false
+		if _, err := p.conn.ExecContext(context.Background(), "COMMIT PREPARED "+pq.QuoteLiteral(p.gid)); err != nil {
+			logError("Commit prepared ", p.gid, " failed for ", p.name, ": ", err)
+			failed = true
+		}
+	}
+	if !failed {
+		_, err := defaultManager.catalog.ExecContext(context.Background(), `DELETE FROM catalog_prepared_tx WHERE id = $1`, id)
+		if err != nil {
+			logError("Prepared transaction log cleanup failed for ", id, ": ", err)
+		}
+	}
+
+	return nil
+}
+
+func beginTenantParticipant(ctx context.Context, tenant string) (*twoPhaseParticipant, error) {
+	tenantConn, err := GetTenantConnectionWithOptions(ctx, tenant, TenantConnectOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return beginParticipant(ctx, tenant, tenantConn.DB)
+}
+
+// A transação é aberta com BEGIN explícito em uma conexão dedicada, pois
+// *sql.Tx não permite encerrá-la com PREPARE TRANSACTION
+func beginParticipant(ctx context.Context, name string, db *sql.DB) (*twoPhaseParticipant, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &twoPhaseParticipant{name: name, conn: conn}
+	if _, err := conn.ExecContext(ctx, "BEGIN"); err != nil {
+		return p, err
+	}
+	p.open = true
+
+	return p, nil
+}
+
+func (p *twoPhaseParticipant) abort() {
+	ctx := context.Background()
+
+	var err error
+	switch {
+	case p.prepared:
+		_, err = p.conn.ExecContext(ctx, "ROLLBACK PREPARED "+pq.QuoteLiteral(p.gid))
+	case p.open:
+		_, err = p.conn.ExecContext(ctx, "ROLLBACK")
+	}
+	if err != nil {
+		logError("Two-phase rollback failed for ", p.name, ": ", err)
+		// Descarta a conexão para não devolver ao pool uma sessão em transação
+		p.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	}
+
+	p.conn.Close()
+}
+
+func newPreparedTxID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// RecoverPreparedTransactions conclui as transações preparadas por
+// WithTwoPhaseCommit que ficaram pendentes no catálogo e nos bancos dos
+// tenants: as com decisão registrada são confirmadas e as demais desfeitas.
+// Deve ser chamada no start da aplicação. Apenas transações preparadas há mais
+// de olderThan são consideradas, para não interferir nas que ainda estão em
+// andamento em outras instâncias.
+func RecoverPreparedTransactions(ctx context.Context, olderThan time.Duration) error {
+	committed, err := preparedTxDecisions(ctx)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{resolvePreparedTransactions(ctx, "catalog", defaultManager.catalog, committed, olderThan)}
+
+	catalogs, err := ListTenants(ctx)
+	if err != nil {
+		return err
+	}
+	for _, catalog := range catalogs {
+		conn, err := GetTenantConnectionWithOptions(ctx, catalog.SchemaName, TenantConnectOptions{})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", catalog.SchemaName, err))
+			continue
+		}
+		errs = append(errs, resolvePreparedTransactions(ctx, catalog.SchemaName, conn.DB, committed, olderThan))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	// Todos os bancos foram verificados, então as decisões antigas já foram
+	// aplicadas em todos os participantes
+	_, err = defaultManager.catalog.ExecContext(ctx, `DELETE FROM catalog_prepared_tx WHERE created_at < now() - make_interval(secs => $1)`, olderThan.Seconds())
+	return err
+}
+
+func preparedTxDecisions(ctx context.Context) (map[string]bool, error) {
+	rows, err := defaultManager.catalog.QueryContext(ctx, `SELECT id FROM catalog_prepared_tx`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	committed := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		committed[id] = true
+	}
+
+	return committed, rows.Err()
+}
+
+func resolvePreparedTransactions(ctx context.Context, name string, db *sql.DB, committed map[string]bool, olderThan time.Duration) error {
+	rows, err := db.QueryContext(ctx, `
+        SELECT gid FROM pg_prepared_xacts
+        WHERE database = current_database()
+          AND left(gid, length($1)) = $1
+          AND prepared < now() - make_interval(secs => $2)`, preparedTxPrefix, olderThan.Seconds())
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	var gids []string
+	for rows.Next() {
+		var gid string
+		if err := rows.Scan(&gid); err != nil {
+			rows.Close()
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		gids = append(gids, gid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	var errs []error
+	for _, gid := range gids {
+		// gid = prefixo + id + "_" + posição do participante
+		id := strings.TrimPrefix(gid, preparedTxPrefix)
+		if i := strings.LastIndex(id, "_"); i >= 0 {
+			id = id[:i]
+		}
+
+		action := "ROLLBACK PREPARED "
+		if committed[id] {
+			action = "COMMIT PREPARED "
+		}
+
+		logInfo("Recovering prepared transaction ", gid, " on ", name, ": ", strings.TrimSpace(action))
+		if _, err := db.ExecContext(ctx, action+pq.QuoteLiteral(gid)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", name, gid, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,117 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"runtime/pprof"
+)
+
+// Tx é a transação recebida pela função passada a WithTransaction. Possui os
+// mesmos métodos de *sql.Tx, então também satisfaz a interface DBTX do sqlc.
+type Tx struct {
+	*sql.Tx
+
+	db       *sql.DB
+	tenant   string
+	onCommit []func()
+
+	// Nível de savepoints aninhados; zero na transação externa
+	depth int
+}
+
+type txContextKey struct{}
+
+// OnCommit registra uma função executada somente depois que a transação for
+// confirmada com sucesso, como a invalidação de caches da aplicação. As
+// funções não são executadas em caso de rollback.
+func (tx *Tx) OnCommit(fn func()) {
+	tx.onCommit = append(tx.onCommit, fn)
+}
+
+// WithTransaction executa fn em uma transação no banco do tenant. A transação
+// é confirmada quando fn retorna nil e desfeita quando retorna erro ou entra
+// em pânico.
+//
+// Quando o contexto já carrega uma transação do mesmo tenant, aberta por outro
+// WithTransaction, é criado um savepoint nela: um erro desfaz apenas o que foi
+// feito por fn, e os hooks de OnCommit registrados dentro do savepoint só
+// rodam se ele for mantido e a transação externa for confirmada.
+func (c Connection) WithTransaction(ctx context.Context, fn func(ctx context.Context, tx *Tx) error) error {
+	if err := c.checkCanceled(ctx); err != nil {
+		return err
+	}
+	c.touch()
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+
+	var err error
+	pprof.Do(ctx, c.labels(), func(ctx context.Context) {
+		err = c.withTransaction(ctx, fn)
+	})
+	return err
+}
+
+func (c Connection) withTransaction(ctx context.Context, fn func(ctx context.Context, tx *Tx) error) error {
+	if outer, ok := ctx.Value(txContextKey{}).(*Tx); ok && outer.db == c.DB {
+		return outer.withSavepoint(ctx, fn)
+	}
+
+	start := clock.Now()
+	sqlTx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return c.queryError(start, err)
+	}
+	defer sqlTx.Rollback()
+
+	tx := &Tx{Tx: sqlTx, db: c.DB, tenant: c.SearchPath}
+	if err := fn(context.WithValue(ctx, txContextKey{}, tx), tx); err != nil {
+		return err
+	}
+
+	if err := sqlTx.Commit(); err != nil {
+		return err
+	}
+
+	tx.runOnCommit()
+	return nil
+}
+
+func (tx *Tx) withSavepoint(ctx context.Context, fn func(ctx context.Context, tx *Tx) error) error {
+	nested := &Tx{Tx: tx.Tx, db: tx.db, tenant: tx.tenant, depth: tx.depth + 1}
+	savepoint := fmt.Sprintf("tenant_connection_sp%d", nested.depth)
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		return err
+	}
+
+	if err := fn(context.WithValue(ctx, txContextKey{}, nested), nested); err != nil {
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint); rbErr != nil {
+			return fmt.Errorf("%w (rollback to savepoint: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint); err != nil {
+		return err
+	}
+
+	// Os hooks do savepoint passam a depender do commit da transação externa
+	tx.onCommit = append(tx.onCommit, nested.onCommit...)
+	return nil
+}
+
+func (tx *Tx) runOnCommit() {
+	for _, fn := range tx.onCommit {
+		// A transação já foi confirmada, então um pânico no hook não pode
+		// chegar ao chamador como se a operação tivesse falhado
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logError("OnCommit hook panicked for tenant ", tx.tenant, ": ", scrubSecrets(fmt.Sprint(r)))
+				}
+			}()
+			fn()
+		}()
+	}
+}
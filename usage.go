@@ -0,0 +1,212 @@
+package connection
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// poolUsage acompanha o uso de um pool, compartilhado entre as cópias da
+// Connection devolvidas para o mesmo tenant
+type poolUsage struct {
+	// Horário do último uso, em nanossegundos desde a época Unix
+	lastUsed atomic.Int64
+	// Contadores do tenant, que sobrevivem à recriação do pool
+	counters *usageCounters
+}
+
+type usageCounters struct {
+	queries   atomic.Uint64
+	errors    atomic.Uint64
+	canceled  atomic.Uint64
+	bytesSent atomic.Uint64
+	// Início da contagem, em nanossegundos desde a época Unix
+	since atomic.Int64
+}
+
+// TenantUsage são os contadores de uso de um tenant desde Since, para
+// cobrança por uso e identificação de tenants barulhentos. BytesSent soma o
+// tamanho das queries e dos argumentos texto/binário enviados ao banco.
+// Queries interrompidas pelo contexto do chamador contam em Canceled, e não
+// em Errors.
+type TenantUsage struct {
+	Tenant           string    `json:"tenant"`
+	Queries          uint64    `json:"queries"`
+	Errors           uint64    `json:"errors"`
+	Canceled         uint64    `json:"canceled"`
+	BytesSent        uint64    `json:"bytes_sent"`
+	Since            time.Time `json:"since"`
+	QueriesPerSecond float64   `json:"queries_per_second"`
+}
+
+func (m *Manager) newPoolUsage(tenant string) *poolUsage {
+	m.usageMutex.Lock()
+	counters, found := m.usage[tenant]
+	if !found {
+		counters = &usageCounters{}
+		counters.since.Store(clock.Now().UnixNano())
+		if m.usage == nil {
+			m.usage = make(map[string]*usageCounters)
+		}
+		m.usage[tenant] = counters
+	}
+	m.usageMutex.Unlock()
+
+	pool := &poolUsage{counters: counters}
+	pool.lastUsed.Store(clock.Now().UnixNano())
+	return pool
+}
+
+// recordQuery contabiliza uma query executada pelos métodos da Connection
+func (c Connection) recordQuery(query string, args []interface{}, err error) {
+	if c.usage == nil {
+		return
+	}
+
+	bytes := len(query)
+	for _, arg := range args {
+		switch value := arg.(type) {
+		case string:
+			bytes += len(value)
+		case []byte:
+			bytes += len(value)
+		}
+	}
+
+	counters := c.usage.counters
+	counters.queries.Add(1)
+	counters.bytesSent.Add(uint64(bytes))
+	switch {
+	case err == nil:
+	case isCancellation(err):
+		counters.canceled.Add(1)
+	default:
+		counters.errors.Add(1)
+	}
+}
+
+// UsageSnapshot retorna os contadores de uso de cada tenant, ordenados pelo
+// nome do tenant.
+func UsageSnapshot() []TenantUsage {
+	return defaultManager.UsageSnapshot()
+}
+
+// UsageSnapshot retorna os contadores de uso dos tenants deste Manager.
+func (m *Manager) UsageSnapshot() []TenantUsage {
+	return m.collectUsage(clock.Now(), false)
+}
+
+func (m *Manager) collectUsage(now time.Time, reset bool) []TenantUsage {
+	m.usageMutex.Lock()
+	defer m.usageMutex.Unlock()
+
+	snapshot := make([]TenantUsage, 0, len(m.usage))
+	for tenant, counters := range m.usage {
+		item := TenantUsage{Tenant: tenant}
+		if reset {
+			item.Since = time.Unix(0, counters.since.Swap(now.UnixNano()))
+			item.Queries = counters.queries.Swap(0)
+			item.Errors = counters.errors.Swap(0)
+			item.Canceled = counters.canceled.Swap(0)
+			item.BytesSent = counters.bytesSent.Swap(0)
+		} else {
+			item.Since = time.Unix(0, counters.since.Load())
+			item.Queries = counters.queries.Load()
+			item.Errors = counters.errors.Load()
+			item.Canceled = counters.canceled.Load()
+			item.BytesSent = counters.bytesSent.Load()
+		}
+		if elapsed := now.Sub(item.Since).Seconds(); elapsed > 0 {
+			item.QueriesPerSecond = float64(item.Queries) / elapsed
+		}
+		snapshot = append(snapshot, item)
+	}
+
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].Tenant < snapshot[j].Tenant })
+	return snapshot
+}
+
+// FlushUsage grava periodicamente os contadores de uso do Manager padrão.
+func FlushUsage(ctx context.Context, every time.Duration) {
+	defaultManager.FlushUsage(ctx, every)
+}
+
+// FlushUsage grava periodicamente os contadores de uso na tabela
+// catalog_usage do catálogo, zerando-os a cada gravação, até o contexto ser
+// cancelado. Cada linha cobre o período entre period_start e period_end.
+func (m *Manager) FlushUsage(ctx context.Context, every time.Duration) {
+	ticker := clock.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Grava o último período antes de encerrar
+			m.flushUsage(context.Background())
+			return
+		case <-ticker.C():
+			m.flushUsage(ctx)
+		}
+	}
+}
+
+func (m *Manager) flushUsage(ctx context.Context) {
+	end := clock.Now()
+	for _, item := range m.collectUsage(end, true) {
+		if item.Queries == 0 && item.Canceled == 0 {
+			continue
+		}
+
+		_, err := m.catalog.ExecContext(ctx, `
+            INSERT INTO catalog_usage (schema_name, period_start, period_end, queries, errors, canceled, bytes_sent)
+            VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			item.Tenant, item.Since, end, int64(item.Queries), int64(item.Errors), int64(item.Canceled), int64(item.BytesSent))
+		if err != nil {
+			logError("Usage flush failed for tenant ", item.Tenant, ": ", err)
+		}
+	}
+}
+
+// touch registra o uso do pool; conexões criadas fora do pacote, como as dos
+// fakes de teste, não possuem usage
+func (c Connection) touch() {
+	if c.usage != nil {
+		c.usage.lastUsed.Store(clock.Now().UnixNano())
+	}
+}
+
+// LastUsedAt retorna o horário da última query executada pelo pool.
+func (c Connection) LastUsedAt() time.Time {
+	if c.usage == nil {
+		return time.Time{}
+	}
+	return time.Unix(0, c.usage.lastUsed.Load())
+}
+
+// IdleTenants retorna, em ordem alfabética, os tenants com pool aberto que
+// não executam queries há pelo menos olderThan, em nenhum dos seus pools.
+func IdleTenants(olderThan time.Duration) []string {
+	return defaultManager.IdleTenants(olderThan)
+}
+
+// IdleTenants retorna os tenants ociosos entre os pools deste Manager.
+func (m *Manager) IdleTenants(olderThan time.Duration) []string {
+	limit := clock.Now().Add(-olderThan)
+
+	active := make(map[string]bool)
+	for _, conn := range m.openConnections() {
+		if conn.usage != nil {
+			active[conn.SearchPath] = active[conn.SearchPath] || conn.LastUsedAt().After(limit)
+		}
+	}
+
+	var idle []string
+	for tenant, used := range active {
+		if !used {
+			idle = append(idle, tenant)
+		}
+	}
+	sort.Strings(idle)
+	return idle
+}
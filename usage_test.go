@@ -0,0 +1,73 @@
+package connection
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIdleTenants(t *testing.T) {
+	fake := &manualClock{now: time.Unix(1000, 0)}
+	SetClock(fake)
+	defer SetClock(nil)
+
+	m := newTestManager()
+	// Há quanto tempo cada pool executou sua última query
+	pools := []struct {
+		tenant, pool string
+		idle         time.Duration
+	}{
+		{"acme", "", 10 * time.Minute},
+		{"acme", "batch", time.Minute},
+		{"globex", "", 20 * time.Minute},
+		{"initech", "", 6 * time.Minute},
+		{"initech", "reports", 30 * time.Minute},
+		{"umbrella", "", 0},
+	}
+	for _, p := range pools {
+		conn := m.addTestPool(Connection{SearchPath: p.tenant, pool: p.pool, usage: m.newPoolUsage(p.tenant)})
+		conn.usage.lastUsed.Store(fake.Now().Add(-p.idle).UnixNano())
+	}
+	// Pools sem usage, como os dos fakes, nunca são considerados ociosos
+	m.addTestPool(Connection{SearchPath: "fake"})
+
+	tests := []struct {
+		olderThan time.Duration
+		want      []string
+	}{
+		{time.Hour, nil},
+		{15 * time.Minute, []string{"globex"}},
+		{5 * time.Minute, []string{"globex", "initech"}},
+		{30 * time.Second, []string{"acme", "globex", "initech"}},
+		{0, []string{"acme", "globex", "initech", "umbrella"}},
+	}
+	for _, tt := range tests {
+		if got := m.IdleTenants(tt.olderThan); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("IdleTenants(%v) = %v, want %v", tt.olderThan, got, tt.want)
+		}
+	}
+}
+
+func TestTouchUpdatesLastUsed(t *testing.T) {
+	fake := &manualClock{now: time.Unix(1000, 0)}
+	SetClock(fake)
+	defer SetClock(nil)
+
+	m := newTestManager()
+	conn := m.addTestPool(Connection{SearchPath: "acme", usage: m.newPoolUsage("acme")})
+
+	fake.mu.Lock()
+	fake.now = fake.now.Add(time.Hour)
+	fake.mu.Unlock()
+	if got := m.IdleTenants(30 * time.Minute); !reflect.DeepEqual(got, []string{"acme"}) {
+		t.Fatalf("IdleTenants before touch = %v", got)
+	}
+
+	conn.touch()
+	if got := conn.LastUsedAt(); !got.Equal(fake.Now()) {
+		t.Fatalf("LastUsedAt = %v, want %v", got, fake.Now())
+	}
+	if got := m.IdleTenants(30 * time.Minute); got != nil {
+		t.Fatalf("IdleTenants after touch = %v, want none", got)
+	}
+}
@@ -0,0 +1,93 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ValidationResult struct {
+	Tenant       string
+	CatalogFound bool
+	Connected    bool
+	SchemaExists bool
+	PingLatency  time.Duration
+	// Versão da tabela schema_migrations (golang-migrate) do schema, quando existir
+	HasMigrations    bool
+	MigrationVersion int64
+	MigrationDirty   bool
+	// Descrição dos problemas encontrados; vazio quando o tenant está válido
+	Problems []string
+}
+
+func (r *ValidationResult) Valid() bool {
+	return len(r.Problems) == 0
+}
+
+// ValidateTenant verifica o cadastro do tenant no catálogo, a conexão com o
+// seu servidor, a existência do schema e a versão das migrations, sem usar
+// nem preencher nenhum cache. O erro é retornado apenas quando a validação
+// não pôde ser executada; problemas do tenant ficam em Problems.
+func ValidateTenant(ctx context.Context, tenant string) (*ValidationResult, error) {
+	result := &ValidationResult{Tenant: tenant}
+
+	catalog, err := queryTenant(ctx, tenant)
+	if err != nil {
+		if errors.Is(err, ErrRecordNotFound) {
+			result.Problems = append(result.Problems, "tenant not found in catalog")
+			return result, nil
+		}
+		return nil, err
+	}
+	result.CatalogFound = true
+
+	db, err := openTenantDB(catalog, TenantConnectOptions{})
+	if err != nil {
+		result.Problems = append(result.Problems, "invalid connection settings: "+err.Error())
+		return result, nil
+	}
+	defer db.Close()
+
+	start := clock.Now()
+	if err := db.PingContext(ctx); err != nil {
+		result.Problems = append(result.Problems, "connection failed: "+err.Error())
+		return result, nil
+	}
+	result.PingLatency = clock.Now().Sub(start)
+	result.Connected = true
+
+	err = db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM information_schema.schemata WHERE schema_name = $1)`, tenant).Scan(&result.SchemaExists)
+	if err != nil {
+		return nil, err
+	}
+	if !result.SchemaExists {
+		result.Problems = append(result.Problems, "schema does not exist")
+		return result, nil
+	}
+
+	migrations := pq.QuoteIdentifier(tenant) + ".schema_migrations"
+
+	var table sql.NullString
+	if err := db.QueryRowContext(ctx, `SELECT to_regclass($1)::text`, migrations).Scan(&table); err != nil {
+		return nil, err
+	}
+	if !table.Valid {
+		return result, nil
+	}
+
+	result.HasMigrations = true
+	err = db.QueryRowContext(ctx, "SELECT version, dirty FROM "+migrations+" LIMIT 1").Scan(&result.MigrationVersion, &result.MigrationDirty)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		result.Problems = append(result.Problems, "no migrations applied")
+	case err != nil:
+		return nil, err
+	case result.MigrationDirty:
+		result.Problems = append(result.Problems, "migration is dirty")
+	}
+
+	return result, nil
+}
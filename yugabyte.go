@@ -0,0 +1,251 @@
+package connection
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Valor da coluna driver do catálogo para tenants no YugabyteDB
+const yugabyteDriver = "yugabytedb"
+
+// Intervalo padrão de atualização da lista de servidores do cluster
+// (yb_servers), o mesmo do smart driver
+var YugabyteServersRefresh = 5 * time.Minute
+
+// Parâmetros do smart driver do YugabyteDB, que o lib/pq não entende
+var yugabyteParams = []string{"load_balance", "topology_keys", "yb_servers_refresh_interval"}
+
+// yugabyteConnector reproduz o balanceamento do smart driver do YugabyteDB,
+// que o lib/pq não tem: cada conexão vai para o servidor do cluster com menos
+// conexões abertas pelo pool, entre os que atendem topology_keys. A lista de
+// servidores é atualizada com yb_servers() em uma das conexões abertas.
+type yugabyteConnector struct {
+	connector *pq.Connector
+	balancer  *yugabyteBalancer
+}
+
+func newYugabyteConnector(dsn string, dialer failoverDialer, params url.Values) (*yugabyteConnector, error) {
+	refresh := YugabyteServersRefresh
+	if seconds, err := strconv.Atoi(params.Get("yb_servers_refresh_interval")); err == nil && seconds > 0 {
+		refresh = time.Duration(seconds) * time.Second
+	}
+
+	balancer := &yugabyteBalancer{
+		dialer:   dialer,
+		servers:  dialer.addresses,
+		conns:    make(map[string]int),
+		topology: parseTopologyKeys(params.Get("topology_keys")),
+		refresh:  refresh,
+	}
+
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	connector.Dialer(balancer)
+
+	return &yugabyteConnector{connector: connector, balancer: balancer}, nil
+}
+
+func (c *yugabyteConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.balancer.refreshDue() {
+		if err := c.balancer.loadServers(ctx, conn); err != nil {
+			logError("YugabyteDB servers refresh failed: ", err)
+		}
+	}
+	return conn, nil
+}
+
+func (c *yugabyteConnector) Driver() driver.Driver {
+	return c.connector.Driver()
+}
+
+type yugabyteBalancer struct {
+	// Dialer do tenant, usado para conectar a um endereço por vez
+	dialer   failoverDialer
+	topology []string
+	refresh  time.Duration
+
+	mu        sync.Mutex
+	servers   []string
+	conns     map[string]int
+	refreshed time.Time
+	loading   bool
+}
+
+func (b *yugabyteBalancer) Dial(network, address string) (net.Conn, error) {
+	return b.DialContext(context.Background(), network, address)
+}
+
+func (b *yugabyteBalancer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return b.DialContext(ctx, network, address)
+}
+
+// DialContext tenta os servidores do menos para o mais carregado
+func (b *yugabyteBalancer) DialContext(ctx context.Context, network, _ string) (net.Conn, error) {
+	var errs []error
+	for _, address := range b.candidates() {
+		host := b.dialer
+		host.addresses = []string{address}
+
+		conn, err := host.DialContext(ctx, network, "")
+		if err == nil {
+			b.mu.Lock()
+			b.conns[address]++
+			b.mu.Unlock()
+			return &balancedConn{Conn: conn, release: func() { b.release(address) }}, nil
+		}
+		errs = append(errs, err)
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// candidates devolve os servidores ordenados pela quantidade de conexões
+// abertas, mantendo a ordem da lista nos empates
+func (b *yugabyteBalancer) candidates() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	servers := append([]string(nil), b.servers...)
+	for i := 1; i < len(servers); i++ {
+		for j := i; j > 0 && b.conns[servers[j]] < b.conns[servers[j-1]]; j-- {
+			servers[j], servers[j-1] = servers[j-1], servers[j]
+		}
+	}
+	return servers
+}
+
+func (b *yugabyteBalancer) release(address string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.conns[address]--; b.conns[address] <= 0 {
+		delete(b.conns, address)
+	}
+}
+
+// refreshDue indica se a lista de servidores deve ser atualizada, marcando a
+// atualização como em andamento para que apenas uma conexão a faça
+func (b *yugabyteBalancer) refreshDue() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.loading || (!b.refreshed.IsZero() && clock.Now().Sub(b.refreshed) < b.refresh) {
+		return false
+	}
+	b.loading = true
+	return true
+}
+
+// loadServers atualiza a lista com os servidores de yb_servers() que atendem
+// topology_keys; sem nenhum, usa todos os servidores do cluster
+func (b *yugabyteBalancer) loadServers(ctx context.Context, conn driver.Conn) error {
+	defer func() {
+		b.mu.Lock()
+		b.loading = false
+		b.refreshed = clock.Now()
+		b.mu.Unlock()
+	}()
+
+	queryer, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return errors.New("driver does not support queries")
+	}
+	rows, err := queryer.QueryContext(ctx, "SELECT host, port::text, cloud || '.' || region || '.' || zone FROM yb_servers()", nil)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var all, matching []string
+	values := make([]driver.Value, 3)
+	for {
+		if err := rows.Next(values); err != nil {
+			break
+		}
+		host, _ := values[0].(string)
+		port, _ := values[1].(string)
+		placement, _ := values[2].(string)
+
+		address := net.JoinHostPort(host, port)
+		all = append(all, address)
+		if matchesTopology(b.topology, placement) {
+			matching = append(matching, address)
+		}
+	}
+	if len(all) == 0 {
+		return errors.New("yb_servers returned no servers")
+	}
+	if len(b.topology) == 0 || len(matching) == 0 {
+		matching = all
+	}
+
+	b.mu.Lock()
+	b.servers = matching
+	b.mu.Unlock()
+	return nil
+}
+
+// parseTopologyKeys interpreta topology_keys (cloud.region.zone, separados
+// por vírgula, com * na zona). A preferência (:1, :2) é ignorada.
+func parseTopologyKeys(value string) []string {
+	var keys []string
+	for _, key := range strings.Split(value, ",") {
+		if i := strings.IndexByte(key, ':'); i >= 0 {
+			key = key[:i]
+		}
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func matchesTopology(keys []string, placement string) bool {
+	for _, key := range keys {
+		if key == placement || (strings.HasSuffix(key, ".*") && strings.HasPrefix(placement, key[:len(key)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// balancedConn libera a vaga do servidor no balanceamento ao ser fechada
+type balancedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *balancedConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
+
+// isReadRestart indica o erro de leitura do YugabyteDB que pode ser repetido
+// com segurança, como após ler de um seguidor desatualizado
+func isReadRestart(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "40001" && strings.Contains(pqErr.Message, "Restart read required")
+}